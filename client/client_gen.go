@@ -0,0 +1,926 @@
+// Code generated by client/internal/gen from openapi.json. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const basePath = "/api"
+
+type AccountIdentity struct {
+	CreatedAt  time.Time `json:"created_at"`
+	Email      string    `json:"email"`
+	IdentityID int       `json:"identity_id"`
+	Provider   string    `json:"provider"`
+	Subject    string    `json:"subject"`
+	UserID     int       `json:"user_id"`
+}
+
+type AdminAccount struct {
+	CreatedAt     time.Time  `json:"created_at"`
+	DeletedAt     *time.Time `json:"deleted_at"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	FirstName     string     `json:"first_name"`
+	LastName      string     `json:"last_name"`
+	Role          string     `json:"role"`
+	UserID        int        `json:"user_id"`
+	Username      string     `json:"username"`
+}
+
+type AdminAccountPage struct {
+	Items      []AdminAccount `json:"items"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+type ChangePasswordRequest struct {
+	NewPassword string `json:"new_password"`
+	OldPassword string `json:"old_password"`
+}
+
+type CreateAccountRequest struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	Password  string `json:"password"`
+	Username  string `json:"username"`
+}
+
+type CreateProblemRequest struct {
+	Difficulty            int                     `json:"difficulty"`
+	FunctionName          string                  `json:"function_name"`
+	ProblemName           string                  `json:"problem_name"`
+	Prompt                string                  `json:"prompt"`
+	RevealHiddenOnFailure bool                    `json:"reveal_hidden_on_failure,omitempty"`
+	Slug                  string                  `json:"slug,omitempty"`
+	StarterCode           string                  `json:"starter_code"`
+	Tags                  []string                `json:"tags,omitempty"`
+	TestCases             []CreateTestCaseRequest `json:"test_cases,omitempty"`
+}
+
+type CreateSubmissionRequest struct {
+	Language   int    `json:"language"`
+	ProblemID  int    `json:"problem_id"`
+	SourceCode string `json:"source_code,omitempty"`
+}
+
+type CreateTagRequest struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type CreateTestCaseRequest struct {
+	IO        IOInput `json:"io"`
+	Kind      string  `json:"kind,omitempty"`
+	ProblemID int     `json:"problem_id,omitempty"`
+}
+
+type CreateTestCasesResult struct {
+	Created    int `json:"created"`
+	Duplicates int `json:"duplicates"`
+	Replaced   int `json:"replaced"`
+}
+
+type ExecBatchReq struct {
+	Submissions []ExecReq `json:"submissions"`
+}
+
+type ExecReq struct {
+	IsSanityCheck bool   `json:"is_sanity_check,omitempty"`
+	LanguageID    int    `json:"language_id"`
+	ProblemID     int    `json:"problem_id"`
+	SourceCode    string `json:"source_code,omitempty"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type IO struct {
+	Input  map[string]interface{} `json:"input"`
+	Output interface{}            `json:"output"`
+}
+
+type IOInput struct {
+	Input  map[string]interface{} `json:"input,omitempty"`
+	Output interface{}            `json:"output,omitempty"`
+}
+
+type ImportProblemsRequest struct {
+	Packages []ProblemPackage `json:"packages"`
+}
+
+type ImportResult struct {
+	Changes   []string `json:"changes"`
+	ProblemID int      `json:"problem_id"`
+	Slug      string   `json:"slug"`
+}
+
+type LoginRequest struct {
+	Password string `json:"password"`
+	Username string `json:"username"`
+}
+
+type OAuthCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type OAuthStartResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type PackageTestCase struct {
+	IO   IOInput `json:"io"`
+	Kind string  `json:"kind,omitempty"`
+}
+
+type Problem struct {
+	AuthorID              *int       `json:"author_id"`
+	Difficulty            int        `json:"difficulty"`
+	FunctionName          string     `json:"function_name"`
+	ProblemID             int        `json:"problem_id"`
+	ProblemName           string     `json:"problem_name"`
+	Prompt                string     `json:"prompt"`
+	PublishedAt           *time.Time `json:"published_at"`
+	RevealHiddenOnFailure bool       `json:"reveal_hidden_on_failure"`
+	Revision              int        `json:"revision"`
+	Slug                  string     `json:"slug"`
+	StarterCode           string     `json:"starter_code"`
+	Status                string     `json:"status"`
+	Tags                  []string   `json:"tags"`
+}
+
+type ProblemPackage struct {
+	Difficulty            int               `json:"difficulty"`
+	Format                int               `json:"format"`
+	FunctionName          string            `json:"function_name"`
+	ProblemName           string            `json:"problem_name"`
+	Prompt                string            `json:"prompt"`
+	ReferenceSolutions    map[string]string `json:"reference_solutions,omitempty"`
+	RevealHiddenOnFailure bool              `json:"reveal_hidden_on_failure,omitempty"`
+	Slug                  string            `json:"slug"`
+	StarterCode           string            `json:"starter_code"`
+	Tags                  []string          `json:"tags,omitempty"`
+	TestCases             []PackageTestCase `json:"test_cases"`
+}
+
+type ProblemPage struct {
+	Items      []Problem `json:"items"`
+	NextCursor *string   `json:"next_cursor"`
+}
+
+type ProblemRevision struct {
+	CreatedAt time.Time `json:"created_at"`
+	EditedBy  *int      `json:"edited_by"`
+	Problem   Problem   `json:"problem"`
+	Revision  int       `json:"revision"`
+}
+
+type ProblemSearchResult struct {
+	AuthorID              *int       `json:"author_id"`
+	Difficulty            int        `json:"difficulty"`
+	FunctionName          string     `json:"function_name"`
+	ProblemID             int        `json:"problem_id"`
+	ProblemName           string     `json:"problem_name"`
+	Prompt                string     `json:"prompt"`
+	PublishedAt           *time.Time `json:"published_at"`
+	Rank                  float64    `json:"rank"`
+	RevealHiddenOnFailure bool       `json:"reveal_hidden_on_failure"`
+	Revision              int        `json:"revision"`
+	Slug                  string     `json:"slug"`
+	StarterCode           string     `json:"starter_code"`
+	Status                string     `json:"status"`
+	Tags                  []string   `json:"tags"`
+}
+
+type PublicAccount struct {
+	CreatedAt time.Time `json:"created_at"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+}
+
+type ReferenceSolution struct {
+	LanguageID int       `json:"language_id"`
+	ProblemID  int       `json:"problem_id"`
+	SourceCode string    `json:"source_code"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	UpdatedBy  *int      `json:"updated_by"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ResetPasswordRequest struct {
+	NewPassword string `json:"new_password"`
+	Token       string `json:"token"`
+}
+
+type Result struct {
+	Passed bool         `json:"passed"`
+	Result []TestResult `json:"result"`
+}
+
+type SelfAccount struct {
+	CreatedAt     time.Time `json:"created_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	Role          string    `json:"role"`
+	UserID        int       `json:"user_id"`
+	Username      string    `json:"username"`
+}
+
+type Session struct {
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	IP         string    `json:"ip"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	SessionID  string    `json:"session_id"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+type SetProblemTagsRequest struct {
+	Tags []string `json:"tags,omitempty"`
+}
+
+type SetReferenceSolutionRequest struct {
+	SourceCode string `json:"source_code,omitempty"`
+}
+
+type Submission struct {
+	Language     int       `json:"language"`
+	MemUsageKb   int       `json:"mem_usage_kb"`
+	ProblemID    int       `json:"problem_id"`
+	RuntimeMs    int       `json:"runtime_ms"`
+	SourceCode   string    `json:"source_code"`
+	SubmissionID int       `json:"submission_id"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+	UserID       int       `json:"user_id"`
+	Verdict      string    `json:"verdict"`
+}
+
+type SubmissionPage struct {
+	Items      []Submission `json:"items"`
+	NextCursor *string      `json:"next_cursor"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type TestCase struct {
+	Flag       string `json:"flag,omitempty"`
+	IO         IO     `json:"io"`
+	Kind       string `json:"kind"`
+	ProblemID  int    `json:"problem_id"`
+	TestCaseID int    `json:"test_case_id"`
+}
+
+type TestCasePage struct {
+	Items      []TestCase `json:"items"`
+	NextCursor *string    `json:"next_cursor"`
+}
+
+type TestResult struct {
+	Error    string `json:"error,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Hidden   bool   `json:"hidden"`
+	Input    string `json:"input,omitempty"`
+	Kind     string `json:"kind"`
+	Output   string `json:"output,omitempty"`
+	Passed   bool   `json:"passed"`
+	Stdout   string `json:"stdout,omitempty"`
+}
+
+type TokenResponse struct {
+	AccessToken  string    `json:"access_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+}
+
+type UpdateAccountRequest struct {
+	Email     *string `json:"email,omitempty"`
+	FirstName *string `json:"first_name,omitempty"`
+	LastName  *string `json:"last_name,omitempty"`
+	Username  *string `json:"username,omitempty"`
+}
+
+type UpdateProblemRequest struct {
+	Difficulty            int      `json:"difficulty"`
+	FunctionName          string   `json:"function_name"`
+	ProblemName           string   `json:"problem_name"`
+	Prompt                string   `json:"prompt"`
+	RevealHiddenOnFailure bool     `json:"reveal_hidden_on_failure,omitempty"`
+	Revision              int      `json:"revision"`
+	Slug                  string   `json:"slug,omitempty"`
+	StarterCode           string   `json:"starter_code"`
+	Tags                  []string `json:"tags,omitempty"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ChangePassword is POST /accounts/{id}/password. Change your password
+func (c *Client) ChangePassword(ctx context.Context, id int, body *ChangePasswordRequest) error {
+	return c.do(ctx, "POST", fmt.Sprintf("/accounts/%d/password", id), body, nil)
+}
+
+// CompleteOAuth is POST /auth/oauth/{provider}/callback. Complete an OAuth flow with the code and state from the provider
+func (c *Client) CompleteOAuth(ctx context.Context, provider string, body *OAuthCallbackRequest) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "POST", fmt.Sprintf("/auth/oauth/%s/callback", url.PathEscape(provider)), body, &out)
+	return out, err
+}
+
+// CreateAccount is POST /accounts. Sign up
+func (c *Client) CreateAccount(ctx context.Context, body *CreateAccountRequest) (*SelfAccount, error) {
+	out := new(SelfAccount)
+	if err := c.do(ctx, "POST", "/accounts", body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateProblem is POST /problems. Create a draft problem, optionally with its test cases
+func (c *Client) CreateProblem(ctx context.Context, body *CreateProblemRequest) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "POST", "/problems", body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateSubmission is POST /submissions. Submit a solution
+func (c *Client) CreateSubmission(ctx context.Context, body *CreateSubmissionRequest) (*Submission, error) {
+	out := new(Submission)
+	if err := c.do(ctx, "POST", "/submissions", body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateTag is POST /tags. Create a tag
+func (c *Client) CreateTag(ctx context.Context, body *CreateTagRequest) (*Tag, error) {
+	out := new(Tag)
+	if err := c.do(ctx, "POST", "/tags", body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateTestCase is POST /testcases. Add a test case to a problem
+func (c *Client) CreateTestCase(ctx context.Context, body *CreateTestCaseRequest) (*TestCase, error) {
+	out := new(TestCase)
+	if err := c.do(ctx, "POST", "/testcases", body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateTestCasesParams are the query parameters of CreateTestCases, zero values are left out.
+type CreateTestCasesParams struct {
+	Replace *bool
+}
+
+func (p *CreateTestCasesParams) encode() string {
+	if p == nil {
+		return ""
+	}
+	query := url.Values{}
+	if p.Replace != nil {
+		query.Set("replace", strconv.FormatBool(*p.Replace))
+	}
+	return query.Encode()
+}
+
+// CreateTestCases is POST /problems/{id}/testcases:bulk. Add up to 1000 test cases to a problem, or replace all of its test cases, at once
+func (c *Client) CreateTestCases(ctx context.Context, id int, body []CreateTestCaseRequest, params *CreateTestCasesParams) (*CreateTestCasesResult, error) {
+	out := new(CreateTestCasesResult)
+	if err := c.do(ctx, "POST", withQuery(fmt.Sprintf("/problems/%d/testcases:bulk", id), params.encode()), body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteAccount is DELETE /accounts/{id}. Delete an account
+func (c *Client) DeleteAccount(ctx context.Context, id int) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/accounts/%d", id), nil, nil)
+}
+
+// DeleteReferenceSolution is DELETE /problems/{id}/solutions/{language_id}. Remove the reference solution in a language
+func (c *Client) DeleteReferenceSolution(ctx context.Context, id int, languageID int) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/problems/%d/solutions/%d", id, languageID), nil, nil)
+}
+
+// ForgotPassword is POST /auth/forgot-password. Email a password reset link
+func (c *Client) ForgotPassword(ctx context.Context, body *ForgotPasswordRequest) error {
+	return c.do(ctx, "POST", "/auth/forgot-password", body, nil)
+}
+
+// GetAccount is GET /accounts/{id}. Get an account
+func (c *Client) GetAccount(ctx context.Context, id int) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "GET", fmt.Sprintf("/accounts/%d", id), nil, &out)
+	return out, err
+}
+
+// GetOpenAPI is GET /openapi.json. This document
+func (c *Client) GetOpenAPI(ctx context.Context) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := c.do(ctx, "GET", "/openapi.json", nil, &out)
+	return out, err
+}
+
+// GetProblem is GET /problems/{id}. Get a problem
+func (c *Client) GetProblem(ctx context.Context, id int) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "GET", fmt.Sprintf("/problems/%d", id), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetProblemByName is GET /problems/name/{name}. Get a problem by name
+func (c *Client) GetProblemByName(ctx context.Context, name string) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "GET", fmt.Sprintf("/problems/name/%s", url.PathEscape(name)), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetProblemBySlug is GET /problems/slug/{slug}. Get a problem by its slug
+func (c *Client) GetProblemBySlug(ctx context.Context, slug string) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "GET", fmt.Sprintf("/problems/slug/%s", url.PathEscape(slug)), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetSubmission is GET /submissions/{id}. Get one of your submissions
+func (c *Client) GetSubmission(ctx context.Context, id int) (*Submission, error) {
+	out := new(Submission)
+	if err := c.do(ctx, "GET", fmt.Sprintf("/submissions/%d", id), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ImportProblems is POST /admin/problems/import. Create or update problems from problem packages, all of them or none
+func (c *Client) ImportProblems(ctx context.Context, body *ImportProblemsRequest) ([]ImportResult, error) {
+	var out []ImportResult
+	err := c.do(ctx, "POST", "/admin/problems/import", body, &out)
+	return out, err
+}
+
+// ListAccountsParams are the query parameters of ListAccounts, zero values are left out.
+type ListAccountsParams struct {
+	Limit  int
+	Cursor string
+	Order  string
+	Sort   string
+}
+
+func (p *ListAccountsParams) encode() string {
+	if p == nil {
+		return ""
+	}
+	query := url.Values{}
+	if p.Limit != 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		query.Set("cursor", p.Cursor)
+	}
+	if p.Order != "" {
+		query.Set("order", p.Order)
+	}
+	if p.Sort != "" {
+		query.Set("sort", p.Sort)
+	}
+	return query.Encode()
+}
+
+// ListAccounts is GET /accounts. List accounts
+func (c *Client) ListAccounts(ctx context.Context, params *ListAccountsParams) (*AdminAccountPage, error) {
+	out := new(AdminAccountPage)
+	if err := c.do(ctx, "GET", withQuery("/accounts", params.encode()), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListDraftsParams are the query parameters of ListDrafts, zero values are left out.
+type ListDraftsParams struct {
+	Limit  int
+	Cursor string
+	Order  string
+	Sort   string
+	Status string
+}
+
+func (p *ListDraftsParams) encode() string {
+	if p == nil {
+		return ""
+	}
+	query := url.Values{}
+	if p.Limit != 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		query.Set("cursor", p.Cursor)
+	}
+	if p.Order != "" {
+		query.Set("order", p.Order)
+	}
+	if p.Sort != "" {
+		query.Set("sort", p.Sort)
+	}
+	if p.Status != "" {
+		query.Set("status", p.Status)
+	}
+	return query.Encode()
+}
+
+// ListDrafts is GET /problems/drafts. List your drafts and problems in review, or everyone's for admins
+func (c *Client) ListDrafts(ctx context.Context, params *ListDraftsParams) (*ProblemPage, error) {
+	out := new(ProblemPage)
+	if err := c.do(ctx, "GET", withQuery("/problems/drafts", params.encode()), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListIdentities is GET /accounts/{id}/identities. List linked OAuth identities
+func (c *Client) ListIdentities(ctx context.Context, id int) ([]AccountIdentity, error) {
+	var out []AccountIdentity
+	err := c.do(ctx, "GET", fmt.Sprintf("/accounts/%d/identities", id), nil, &out)
+	return out, err
+}
+
+// ListProblemRevisions is GET /problems/{id}/revisions. List a problem's revisions, newest first
+func (c *Client) ListProblemRevisions(ctx context.Context, id int) ([]ProblemRevision, error) {
+	var out []ProblemRevision
+	err := c.do(ctx, "GET", fmt.Sprintf("/problems/%d/revisions", id), nil, &out)
+	return out, err
+}
+
+// ListProblemsParams are the query parameters of ListProblems, zero values are left out.
+type ListProblemsParams struct {
+	Limit      int
+	Cursor     string
+	Order      string
+	Sort       string
+	Difficulty int
+	Tag        string
+	Solved     *bool
+}
+
+func (p *ListProblemsParams) encode() string {
+	if p == nil {
+		return ""
+	}
+	query := url.Values{}
+	if p.Limit != 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		query.Set("cursor", p.Cursor)
+	}
+	if p.Order != "" {
+		query.Set("order", p.Order)
+	}
+	if p.Sort != "" {
+		query.Set("sort", p.Sort)
+	}
+	if p.Difficulty != 0 {
+		query.Set("difficulty", strconv.Itoa(p.Difficulty))
+	}
+	if p.Tag != "" {
+		query.Set("tag", p.Tag)
+	}
+	if p.Solved != nil {
+		query.Set("solved", strconv.FormatBool(*p.Solved))
+	}
+	return query.Encode()
+}
+
+// ListProblems is GET /problems. List published problems
+func (c *Client) ListProblems(ctx context.Context, params *ListProblemsParams) (*ProblemPage, error) {
+	out := new(ProblemPage)
+	if err := c.do(ctx, "GET", withQuery("/problems", params.encode()), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListReferenceSolutions is GET /problems/{id}/solutions. List a problem's reference solutions
+func (c *Client) ListReferenceSolutions(ctx context.Context, id int) ([]ReferenceSolution, error) {
+	var out []ReferenceSolution
+	err := c.do(ctx, "GET", fmt.Sprintf("/problems/%d/solutions", id), nil, &out)
+	return out, err
+}
+
+// ListSanityChecks is GET /testcases/sanity/{id}. List a problem's sanity checks
+func (c *Client) ListSanityChecks(ctx context.Context, id int) ([]TestCase, error) {
+	var out []TestCase
+	err := c.do(ctx, "GET", fmt.Sprintf("/testcases/sanity/%d", id), nil, &out)
+	return out, err
+}
+
+// ListSessions is GET /accounts/{id}/sessions. List active sessions
+func (c *Client) ListSessions(ctx context.Context, id int) ([]Session, error) {
+	var out []Session
+	err := c.do(ctx, "GET", fmt.Sprintf("/accounts/%d/sessions", id), nil, &out)
+	return out, err
+}
+
+// ListSubmissionsParams are the query parameters of ListSubmissions, zero values are left out.
+type ListSubmissionsParams struct {
+	Limit     int
+	Cursor    string
+	Order     string
+	Sort      string
+	UserID    int
+	ProblemID int
+	Language  int
+	Verdict   string
+	From      time.Time
+	To        time.Time
+}
+
+func (p *ListSubmissionsParams) encode() string {
+	if p == nil {
+		return ""
+	}
+	query := url.Values{}
+	if p.Limit != 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		query.Set("cursor", p.Cursor)
+	}
+	if p.Order != "" {
+		query.Set("order", p.Order)
+	}
+	if p.Sort != "" {
+		query.Set("sort", p.Sort)
+	}
+	if p.UserID != 0 {
+		query.Set("user_id", strconv.Itoa(p.UserID))
+	}
+	if p.ProblemID != 0 {
+		query.Set("problem_id", strconv.Itoa(p.ProblemID))
+	}
+	if p.Language != 0 {
+		query.Set("language", strconv.Itoa(p.Language))
+	}
+	if p.Verdict != "" {
+		query.Set("verdict", p.Verdict)
+	}
+	if !p.From.IsZero() {
+		query.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		query.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	return query.Encode()
+}
+
+// ListSubmissions is GET /submissions. List your submissions, or every submission for admins
+func (c *Client) ListSubmissions(ctx context.Context, params *ListSubmissionsParams) (*SubmissionPage, error) {
+	out := new(SubmissionPage)
+	if err := c.do(ctx, "GET", withQuery("/submissions", params.encode()), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListTags is GET /tags. List the tags problems can have
+func (c *Client) ListTags(ctx context.Context) ([]Tag, error) {
+	var out []Tag
+	err := c.do(ctx, "GET", "/tags", nil, &out)
+	return out, err
+}
+
+// ListTestCasesParams are the query parameters of ListTestCases, zero values are left out.
+type ListTestCasesParams struct {
+	Limit  int
+	Cursor string
+	Order  string
+}
+
+func (p *ListTestCasesParams) encode() string {
+	if p == nil {
+		return ""
+	}
+	query := url.Values{}
+	if p.Limit != 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		query.Set("cursor", p.Cursor)
+	}
+	if p.Order != "" {
+		query.Set("order", p.Order)
+	}
+	return query.Encode()
+}
+
+// ListTestCases is GET /testcases/{id}. List a problem's test cases, hidden ones for admins only
+func (c *Client) ListTestCases(ctx context.Context, id int, params *ListTestCasesParams) (*TestCasePage, error) {
+	out := new(TestCasePage)
+	if err := c.do(ctx, "GET", withQuery(fmt.Sprintf("/testcases/%d", id), params.encode()), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Login is POST /auth/login. Sign in with a username and password
+func (c *Client) Login(ctx context.Context, body *LoginRequest) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	if err := c.do(ctx, "POST", "/auth/login", body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Logout is POST /auth/logout. Revoke the session a refresh token belongs to
+func (c *Client) Logout(ctx context.Context, body *RefreshRequest) error {
+	return c.do(ctx, "POST", "/auth/logout", body, nil)
+}
+
+// PublishProblem is POST /problems/{id}/publish. Publish a problem once its reference solutions agree with all of its test cases
+func (c *Client) PublishProblem(ctx context.Context, id int) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "POST", fmt.Sprintf("/problems/%d/publish", id), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RefreshTokens is POST /auth/refresh. Exchange a refresh token for new tokens
+func (c *Client) RefreshTokens(ctx context.Context, body *RefreshRequest) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	if err := c.do(ctx, "POST", "/auth/refresh", body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResetPassword is POST /auth/reset-password. Set a new password with an emailed token
+func (c *Client) ResetPassword(ctx context.Context, body *ResetPasswordRequest) error {
+	return c.do(ctx, "POST", "/auth/reset-password", body, nil)
+}
+
+// RetireProblem is POST /problems/{id}/retire. Stop taking submissions for a published problem
+func (c *Client) RetireProblem(ctx context.Context, id int) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "POST", fmt.Sprintf("/problems/%d/retire", id), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RevokeSession is DELETE /accounts/{id}/sessions/{session_id}. Sign out a session
+func (c *Client) RevokeSession(ctx context.Context, id int, sessionID string) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/accounts/%d/sessions/%s", id, url.PathEscape(sessionID)), nil, nil)
+}
+
+// RunCode is POST /run. Run code against a problem's test cases
+func (c *Client) RunCode(ctx context.Context, body *ExecReq) (*Result, error) {
+	out := new(Result)
+	if err := c.do(ctx, "POST", "/run", body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RunCodeBatch is POST /run/batch. Run several programs in one request
+func (c *Client) RunCodeBatch(ctx context.Context, body *ExecBatchReq) ([]Result, error) {
+	var out []Result
+	err := c.do(ctx, "POST", "/run/batch", body, &out)
+	return out, err
+}
+
+// SearchProblemsParams are the query parameters of SearchProblems, zero values are left out.
+type SearchProblemsParams struct {
+	Q     string
+	Limit int
+}
+
+func (p *SearchProblemsParams) encode() string {
+	if p == nil {
+		return ""
+	}
+	query := url.Values{}
+	if p.Q != "" {
+		query.Set("q", p.Q)
+	}
+	if p.Limit != 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return query.Encode()
+}
+
+// SearchProblems is GET /problems/search. Search the names and prompts of published problems, best matches first
+func (c *Client) SearchProblems(ctx context.Context, params *SearchProblemsParams) ([]ProblemSearchResult, error) {
+	var out []ProblemSearchResult
+	err := c.do(ctx, "GET", withQuery("/problems/search", params.encode()), nil, &out)
+	return out, err
+}
+
+// SetProblemTags is PUT /problems/{id}/tags. Replace a problem's tags
+func (c *Client) SetProblemTags(ctx context.Context, id int, body *SetProblemTagsRequest) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "PUT", fmt.Sprintf("/problems/%d/tags", id), body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetReferenceSolution is PUT /problems/{id}/solutions/{language_id}. Add or replace the reference solution in a language, and check the test cases against it
+func (c *Client) SetReferenceSolution(ctx context.Context, id int, languageID int, body *SetReferenceSolutionRequest) (*ReferenceSolution, error) {
+	out := new(ReferenceSolution)
+	if err := c.do(ctx, "PUT", fmt.Sprintf("/problems/%d/solutions/%d", id, languageID), body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StartOAuth is GET /auth/oauth/{provider}/start. Start signing in or linking with an OAuth provider
+func (c *Client) StartOAuth(ctx context.Context, provider string) (*OAuthStartResponse, error) {
+	out := new(OAuthStartResponse)
+	if err := c.do(ctx, "GET", fmt.Sprintf("/auth/oauth/%s/start", url.PathEscape(provider)), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SubmitProblemForReview is POST /problems/{id}/review. Submit a draft for review
+func (c *Client) SubmitProblemForReview(ctx context.Context, id int) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "POST", fmt.Sprintf("/problems/%d/review", id), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UnlinkIdentity is DELETE /accounts/{id}/identities/{provider}. Unlink an OAuth identity
+func (c *Client) UnlinkIdentity(ctx context.Context, id int, provider string) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/accounts/%d/identities/%s", id, url.PathEscape(provider)), nil, nil)
+}
+
+// UpdateAccount is PATCH /accounts/{id}. Update an account's profile
+func (c *Client) UpdateAccount(ctx context.Context, id int, body *UpdateAccountRequest) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, "PATCH", fmt.Sprintf("/accounts/%d", id), body, &out)
+	return out, err
+}
+
+// UpdateProblem is PUT /problems/{id}. Edit a problem, making a new revision
+func (c *Client) UpdateProblem(ctx context.Context, id int, body *UpdateProblemRequest) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "PUT", fmt.Sprintf("/problems/%d", id), body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateRole is PUT /accounts/{id}/role. Change an account's role
+func (c *Client) UpdateRole(ctx context.Context, id int, body *UpdateRoleRequest) (map[string]string, error) {
+	var out map[string]string
+	err := c.do(ctx, "PUT", fmt.Sprintf("/accounts/%d/role", id), body, &out)
+	return out, err
+}
+
+// VerifyEmail is POST /auth/verify. Verify an email address with an emailed token
+func (c *Client) VerifyEmail(ctx context.Context, body *VerifyEmailRequest) error {
+	return c.do(ctx, "POST", "/auth/verify", body, nil)
+}
+
+// WithdrawProblem is POST /problems/{id}/withdraw. Take a problem out of review and back to draft
+func (c *Client) WithdrawProblem(ctx context.Context, id int) (*Problem, error) {
+	out := new(Problem)
+	if err := c.do(ctx, "POST", fmt.Sprintf("/problems/%d/withdraw", id), nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
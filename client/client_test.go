@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientSendsAndDecodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/accounts/7/sessions" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("expected the access token, got %q", got)
+		}
+		json.NewEncoder(w).Encode([]Session{{SessionID: "abc", IP: "127.0.0.1"}})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.Token = "token"
+
+	sessions, err := c.ListSessions(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].SessionID != "abc" {
+		t.Errorf("unexpected sessions %+v", sessions)
+	}
+}
+
+func TestClientReturnsAPIErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"validation failed","code":"validation_failed","fields":{"email":"is not a valid email address"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).CreateAccount(context.Background(), &CreateAccountRequest{Email: "nope"})
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	if apiErr.Status != http.StatusBadRequest || apiErr.Code != "validation_failed" || apiErr.Fields["email"] == "" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+}
+
+func TestClientEncodesQueryParameters(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.RawQuery, "cursor=abc&difficulty=2&solved=false"; got != want {
+			t.Errorf("expected query %q, got %q", want, got)
+		}
+		w.Write([]byte(`{"items":[{"problem_id":1}],"next_cursor":null}`))
+	}))
+	defer srv.Close()
+
+	solved := false
+	page, err := New(srv.URL).ListProblems(context.Background(), &ListProblemsParams{Cursor: "abc", Difficulty: 2, Solved: &solved})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != nil {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
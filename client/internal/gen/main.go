@@ -0,0 +1,355 @@
+/*
+ * Generates client_gen.go from the OpenAPI document the server serves.
+ *
+ *	go run ./internal/gen <openapi.json> <output.go>
+ *
+ * Every component schema but Error becomes a struct, and every operation a method on Client
+ * named after its operationId. It understands the subset of OpenAPI the server produces, see
+ * src/openapi.go, and nothing more.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type document struct {
+	Servers    []struct{ URL string } `json:"servers"`
+	Paths      map[string]map[string]*operation
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string `json:"operationId"`
+	Summary     string `json:"summary"`
+	Parameters  []struct {
+		Name   string  `json:"name"`
+		In     string  `json:"in"`
+		Schema *schema `json:"schema"`
+	} `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct{ Schema *schema } `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct{ Schema *schema } `json:"content"`
+	} `json:"responses"`
+
+	method, path string
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AnyOf                []*schema          `json:"anyOf"`
+}
+
+/* Hand written in client.go */
+var skipSchemas = map[string]bool{"Error": true}
+
+func main() {
+	if len(os.Args) != 3 {
+		log.Fatal("usage: gen <openapi.json> <output.go>")
+	}
+
+	spec, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := generate(spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(os.Args[2], src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(spec []byte) ([]byte, error) {
+	doc := new(document)
+	if err := json.Unmarshal(spec, doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Servers) != 1 {
+		return nil, fmt.Errorf("expected exactly one server, got %d", len(doc.Servers))
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "const basePath = %q\n", doc.Servers[0].URL)
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		if !skipSchemas[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := doc.Components.Schemas[name]
+		if s.Type != "object" || s.Properties == nil {
+			return nil, fmt.Errorf("schema %s: only objects with properties are supported", name)
+		}
+		fmt.Fprintf(&b, "\ntype %s %s\n", name, structType(s))
+	}
+
+	operations := []*operation{}
+	for path, byMethod := range doc.Paths {
+		for method, op := range byMethod {
+			op.method, op.path = strings.ToUpper(method), path
+			operations = append(operations, op)
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool { return operations[i].OperationID < operations[j].OperationID })
+
+	for _, op := range operations {
+		if err := writeMethod(&b, op); err != nil {
+			return nil, fmt.Errorf("%s: %w", op.OperationID, err)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintln(&out, "// Code generated by client/internal/gen from openapi.json. DO NOT EDIT.")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "package client")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "import (")
+	for _, pkg := range []string{"context", "encoding/json", "fmt", "net/url", "strconv", "time"} {
+		if bytes.Contains(b.Bytes(), []byte(pkg[strings.LastIndex(pkg, "/")+1:]+".")) {
+			fmt.Fprintf(&out, "\t%q\n", pkg)
+		}
+	}
+	fmt.Fprintln(&out, ")")
+	fmt.Fprintln(&out)
+	out.Write(b.Bytes())
+
+	return format.Source(out.Bytes())
+}
+
+func writeMethod(b *bytes.Buffer, op *operation) error {
+	method := exported(op.OperationID)
+	params := []string{"ctx context.Context"}
+	path := op.path
+	args := []string{}
+	query := []string{}
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "query":
+			query = append(query, queryField(p.Name, p.Schema))
+			continue
+		case "path":
+		default:
+			return fmt.Errorf("unsupported parameter location %s", p.In)
+		}
+		name := lowerCamel(p.Name)
+		placeholder := "{" + p.Name + "}"
+		if p.Schema.Type == "integer" {
+			params = append(params, name+" int")
+			path = strings.Replace(path, placeholder, "%d", 1)
+			args = append(args, name)
+		} else {
+			params = append(params, name+" string")
+			path = strings.Replace(path, placeholder, "%s", 1)
+			args = append(args, "url.PathEscape("+name+")")
+		}
+	}
+
+	body := "nil"
+	if op.RequestBody != nil {
+		params = append(params, "body "+pointerTo(goType(op.RequestBody.Content["application/json"].Schema)))
+		body = "body"
+	}
+
+	pathExpr := strconv.Quote(path)
+	if len(args) > 0 {
+		pathExpr = fmt.Sprintf("fmt.Sprintf(%s, %s)", pathExpr, strings.Join(args, ", "))
+	}
+
+	if len(query) > 0 {
+		paramsType := method + "Params"
+		fmt.Fprintf(b, "\n// %s are the query parameters of %s, zero values are left out.\n", paramsType, method)
+		fmt.Fprintf(b, "type %s struct {\n", paramsType)
+		for _, field := range query {
+			fmt.Fprintln(b, strings.SplitN(field, "\n", 2)[0])
+		}
+		fmt.Fprintf(b, "}\n\n")
+		fmt.Fprintf(b, "func (p *%s) encode() string {\n\tif p == nil {\n\t\treturn \"\"\n\t}\n\tquery := url.Values{}\n", paramsType)
+		for _, field := range query {
+			fmt.Fprintln(b, strings.SplitN(field, "\n", 2)[1])
+		}
+		fmt.Fprintf(b, "\treturn query.Encode()\n}\n")
+
+		params = append(params, "params *"+paramsType)
+		pathExpr = fmt.Sprintf("withQuery(%s, params.encode())", pathExpr)
+	}
+
+	fmt.Fprintf(b, "\n// %s is %s %s. %s\n", method, op.method, op.path, op.Summary)
+
+	result := resultType(op)
+	if result == "" {
+		fmt.Fprintf(b, "func (c *Client) %s(%s) error {\n", method, strings.Join(params, ", "))
+		fmt.Fprintf(b, "\treturn c.do(ctx, %q, %s, %s, nil)\n}\n", op.method, pathExpr, body)
+		return nil
+	}
+
+	fmt.Fprintf(b, "func (c *Client) %s(%s) (%s, error) {\n", method, strings.Join(params, ", "), pointerTo(result))
+	if isPointer(result) {
+		fmt.Fprintf(b, "\tout := new(%s)\n", result)
+		fmt.Fprintf(b, "\tif err := c.do(ctx, %q, %s, %s, out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn out, nil\n}\n", op.method, pathExpr, body)
+	} else {
+		fmt.Fprintf(b, "\tvar out %s\n", result)
+		fmt.Fprintf(b, "\terr := c.do(ctx, %q, %s, %s, &out)\n\treturn out, err\n}\n", op.method, pathExpr, body)
+	}
+	return nil
+}
+
+/*
+ * A field of a Params struct, then the line of its encode method that adds it to `query` when it
+ * is set, separated by a newline. Booleans are pointers so that false can be asked for.
+ */
+func queryField(name string, s *schema) string {
+	field := exported(name)
+	switch {
+	case s.Type == "string" && s.Format == "date-time":
+		return fmt.Sprintf("%s time.Time\nif !p.%s.IsZero() {\nquery.Set(%q, p.%s.Format(time.RFC3339Nano))\n}", field, field, name, field)
+	case s.Type == "integer":
+		return fmt.Sprintf("%s int\nif p.%s != 0 {\nquery.Set(%q, strconv.Itoa(p.%s))\n}", field, field, name, field)
+	case s.Type == "boolean":
+		return fmt.Sprintf("%s *bool\nif p.%s != nil {\nquery.Set(%q, strconv.FormatBool(*p.%s))\n}", field, field, name, field)
+	}
+	return fmt.Sprintf("%s string\nif p.%s != \"\" {\nquery.Set(%q, p.%s)\n}", field, field, name, field)
+}
+
+/*
+ * The Go type of the successful response body, "" when there is none. When the 2xx responses
+ * disagree the raw JSON is returned and the caller decodes it.
+ */
+func resultType(op *operation) string {
+	types := map[string]bool{}
+	for status, response := range op.Responses {
+		if !strings.HasPrefix(status, "2") || response.Content == nil {
+			continue
+		}
+		types[goType(response.Content["application/json"].Schema)] = true
+	}
+
+	switch len(types) {
+	case 0:
+		return ""
+	case 1:
+		for t := range types {
+			return t
+		}
+	}
+	return "json.RawMessage"
+}
+
+func goType(s *schema) string {
+	var t string
+	switch {
+	case s.Ref != "":
+		return s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+	case len(s.AnyOf) > 0:
+		return "json.RawMessage"
+	case s.Type == "string" && s.Format == "date-time":
+		t = "time.Time"
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer":
+		t = "int"
+	case s.Type == "number":
+		t = "float64"
+	case s.Type == "boolean":
+		t = "bool"
+	case s.Type == "array":
+		return "[]" + goType(s.Items)
+	case s.Type == "object" && s.Properties != nil:
+		t = structType(s)
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		return "map[string]" + goType(s.AdditionalProperties)
+	default:
+		return "interface{}"
+	}
+
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func structType(s *schema) string {
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, name := range names {
+		tag := name
+		if !required[name] {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&b, "\t%s %s `json:%q`\n", exported(name), goType(s.Properties[name]), tag)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+/* Named structs are returned and taken by pointer, everything else by value */
+func isPointer(t string) bool {
+	return !strings.ContainsAny(t, "[.{") && t != "string" && t != "int" && t != "float64" && t != "bool"
+}
+
+func pointerTo(t string) string {
+	if isPointer(t) {
+		return "*" + t
+	}
+	return t
+}
+
+var initialisms = map[string]string{"id": "ID", "io": "IO", "ip": "IP", "url": "URL", "api": "API", "json": "JSON", "http": "HTTP"}
+
+/* snake_case or camelCase to an exported Go name, user_id -> UserID */
+func exported(name string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(name, "_") {
+		if upper, ok := initialisms[strings.ToLower(word)]; ok {
+			b.WriteString(upper)
+			continue
+		}
+		if word != "" {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+/* snake_case to an unexported Go name, session_id -> sessionID */
+func lowerCamel(name string) string {
+	first, rest, _ := strings.Cut(name, "_")
+	return strings.ToLower(first[:1]) + first[1:] + exported(rest)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+/* client_gen.go must be what the generator makes of the committed openapi.json */
+func TestGeneratedClientUpToDate(t *testing.T) {
+	spec, err := os.ReadFile("../../../openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := generate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile("../../client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("client_gen.go is out of date, run `make client`")
+	}
+}
+
+func TestNames(t *testing.T) {
+	cases := []struct{ in, exported, unexported string }{
+		{"user_id", "UserID", "userID"},
+		{"session_id", "SessionID", "sessionID"},
+		{"id", "ID", "id"},
+		{"provider", "Provider", "provider"},
+		{"getOpenAPI", "GetOpenAPI", "getOpenAPI"},
+	}
+
+	for _, c := range cases {
+		if got := exported(c.in); got != c.exported {
+			t.Errorf("exported(%q) = %q, expected %q", c.in, got, c.exported)
+		}
+		if got := lowerCamel(c.in); got != c.unexported {
+			t.Errorf("lowerCamel(%q) = %q, expected %q", c.in, got, c.unexported)
+		}
+	}
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/rs/cors"
+	"io"
 	"log"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -14,45 +18,64 @@ import (
 type APIServer struct {
 	listenAddr string
 	store      Storage
+	auth       *TokenAuthority
+	mailer     Mailer
+	oauth      map[string]*OAuthProvider
+	limits     *rateBudgets
+	executor   Executor
 }
 
 func NewAPIServer(listenAddr string, store Storage) *APIServer {
 	return &APIServer{
 		listenAddr: listenAddr,
 		store:      store,
+		auth:       NewTokenAuthorityFromEnv(),
+		mailer:     NewMailerFromEnv(),
+		oauth:      NewOAuthProvidersFromEnv(),
+		limits:     newRateBudgets(),
+		executor:   judge0Executor{},
 	}
 }
 
 func (s *APIServer) Run() {
-	router := mux.NewRouter()
-	handler := cors.Default().Handler(router)
-	router.Use(commonMiddleware)
-	apiRoute := "/api"
+	handler := cors.New(cors.Options{
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+	}).Handler(s.newRouter())
 
-	/* Run code */
-	router.HandleFunc(apiRoute+"/run", makeHTTPHandlerFunc(s.handleRun))
-	router.HandleFunc(apiRoute+"/run/batch", makeHTTPHandlerFunc(s.handleRunBatch))
+	log.Println("- API server running on port", s.listenAddr[1:])
+	http.ListenAndServe(s.listenAddr, handler)
+}
 
-	/* Accounts */
-	router.HandleFunc(apiRoute+"/accounts", makeHTTPHandlerFunc(s.handleAccount))
-	router.HandleFunc(apiRoute+"/accounts/{id}", makeHTTPHandlerFunc(s.handleAccountByID))
+const apiRoute = "/api"
 
-	/* Problems */
-	router.HandleFunc(apiRoute+"/problems", makeHTTPHandlerFunc(s.handleProblem))
-	router.HandleFunc(apiRoute+"/problems/{id}", makeHTTPHandlerFunc(s.handleProblemByID))
-	router.HandleFunc(apiRoute+"/problems/name/{name}", makeHTTPHandlerFunc(s.handleProblemByName))
+/*
+ * Registers every route in routes() under its method only. Each path also gets a catch-all,
+ * registered after all of them, which answers the methods it doesn't have with a 405 and an
+ * Allow header.
+ */
+func (s *APIServer) newRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(commonMiddleware)
+	router.Use(s.authMiddleware)
 
-	/* Test Cases */
-	router.HandleFunc(apiRoute+"/testcases", makeHTTPHandlerFunc(s.handleTestCase))
-	router.HandleFunc(apiRoute+"/testcases/{id}", makeHTTPHandlerFunc(s.handleTestCaseByProblemID))
-	router.HandleFunc(apiRoute+"/testcases/sanity/{id}", makeHTTPHandlerFunc(s.handleTestCaseSanity))
+	allowed := map[string][]string{}
+	paths := []string{}
+	for _, rt := range s.routes() {
+		if _, ok := allowed[rt.path]; !ok {
+			paths = append(paths, rt.path)
+		}
+		allowed[rt.path] = append(allowed[rt.path], rt.method)
 
-	/* Submissions */
-	router.HandleFunc(apiRoute+"/submissions", makeHTTPHandlerFunc(s.handleCreateSubmission))
-	router.HandleFunc(apiRoute+"/submissions/{id}", makeHTTPHandlerFunc(s.handleGetSubmissionByID))
+		router.HandleFunc(apiRoute+rt.path, makeHTTPHandlerFunc(s.routeHandler(rt))).Methods(rt.method).Name(rt.name)
+	}
 
-	log.Println("- API server running on port", s.listenAddr[1:])
-	http.ListenAndServe(s.listenAddr, handler)
+	for _, path := range paths {
+		router.HandleFunc(apiRoute+path, makeHTTPHandlerFunc(methodNotAllowed(allowed[path])))
+	}
+	router.NotFoundHandler = commonMiddleware(makeHTTPHandlerFunc(notFound))
+
+	return router
 }
 
 // Pass WriteJSON a pointer to a struct as param `v`, not sure what other types would work, if any ?
@@ -61,16 +84,178 @@ func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
+/* Request types that tidy their fields, e.g. trim whitespace, before they are validated */
+type normalizer interface {
+	normalize()
+}
+
+/* Request types with rules that don't fit in `validate` tags */
+type validator interface {
+	Validate() error
+}
+
+/*
+ * Decodes the JSON body of `r` into `v`, a pointer to a request struct, and validates it.
+ * Bodies over maxRequestBodyBytes, unknown fields and trailing data are rejected. Field
+ * problems are reported together as ValidationErrors: the `validate` tags first, then
+ * v.Validate() if it has one.
+ */
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return PayloadTooLarge(tooLarge.Limit)
+		}
+		return BadRequest("request body must be a single JSON object")
+	}
+
+	return checkRequest(v)
+}
+
+/*
+ * Reads the query string of `r` into `v`, a pointer to a struct whose fields are named by their
+ * JSON tags, and validates it like decodeJSON does a body. Fields may be ints, strings,
+ * booleans, times in RFC 3339 or pointers to one of those. Unknown and repeated parameters are
+ * rejected.
+ */
+func decodeQuery(r *http.Request, v interface{}) error {
+	fields := map[string]reflect.Value{}
+	queryFields(fields, reflect.ValueOf(v).Elem())
+
+	errs := ValidationErrors{}
+	for name, values := range r.URL.Query() {
+		field, ok := fields[name]
+		switch {
+		case !ok:
+			errs.add(name, "is not a known parameter")
+		case len(values) > 1:
+			errs.add(name, "may only be given once")
+		default:
+			errs.add(name, setQueryField(field, values[0]))
+		}
+	}
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	return checkRequest(v)
+}
+
+/* Settable fields of the struct `v` by JSON name, including those of embedded structs */
+func queryFields(fields map[string]reflect.Value, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			queryFields(fields, v.Field(i))
+			continue
+		}
+		if name := jsonFieldName(f); f.IsExported() && name != "-" {
+			fields[name] = v.Field(i)
+		}
+	}
+}
+
+/* Parses `s` into the field, returning what is wrong with it if it can't */
+func setQueryField(field reflect.Value, s string) string {
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+
+	switch {
+	case field.Type() == timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "must be a time in RFC 3339 format"
+		}
+		field.Set(reflect.ValueOf(t))
+	case field.CanInt():
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return "must be an integer"
+		}
+		field.SetInt(n)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return "must be a boolean"
+		}
+		field.SetBool(b)
+	default:
+		field.SetString(s)
+	}
+	return ""
+}
+
+/* The checks decodeJSON and decodeQuery share once the request has been read */
+func checkRequest(v interface{}) error {
+	if n, ok := v.(normalizer); ok {
+		n.normalize()
+	}
+
+	errs := validateTags(v)
+	if val, ok := v.(validator); ok {
+		if err := errs.merge(val.Validate()); err != nil {
+			return err
+		}
+	}
+	return errs.err()
+}
+
+func decodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		return PayloadTooLarge(tooLarge.Limit)
+	case errors.Is(err, io.EOF):
+		return BadRequest("request body is required")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return ValidationErrors{typeErr.Field: "must be " + jsonTypeName(typeErr.Type)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return ValidationErrors{field: "is not a known field"}
+	}
+	return InvalidBody(err)
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch indirectType(t).Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return "an integer"
+}
+
 type apiFunc func(http.ResponseWriter, *http.Request) error
 
 type ApiError struct {
-	Error string `json:"error"`
+	Error  string            `json:"error"`
+	Code   string            `json:"code"`
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
 func makeHTTPHandlerFunc(f apiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			WriteJSON(w, http.StatusBadRequest, ApiError{Error: err.Error()})
+			writeError(w, r, err)
 		}
 	}
 }
@@ -80,7 +265,7 @@ func getID(r *http.Request, idType string) (int, error) {
 	id, err := strconv.Atoi(idStr)
 
 	if err != nil {
-		return id, fmt.Errorf("Invalid %s", idType)
+		return id, BadRequest("Invalid %s", idType)
 	}
 
 	return id, nil
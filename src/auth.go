@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	accessTokenTTL        = 15 * time.Minute
+	refreshTokenTTL       = 30 * 24 * time.Hour
+	verifyEmailTokenTTL   = 48 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+/* Purposes of single-use account tokens sent by email */
+const (
+	tokenPurposeVerifyEmail   = "verify-email"
+	tokenPurposeResetPassword = "reset-password"
+)
+
+var (
+	ErrInvalidCredentials  = Unauthorized("invalid username or password")
+	ErrInvalidToken        = BadRequest("invalid or expired token")
+	ErrRefreshTokenReused  = Unauthorized("refresh token has already been used")
+	ErrRefreshTokenInvalid = Unauthorized("invalid or expired refresh token")
+)
+
+type contextKey string
+
+const accountContextKey contextKey = "account"
+
+type LoginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+type TokenResponse struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+/*
+ * A refresh token row. Only the sha256 of the token is stored; the token itself is
+ * handed to the client once. Every token issued from the same login shares a
+ * FamilyID so that reuse of a rotated token can revoke the whole chain.
+ */
+type RefreshToken struct {
+	TokenID   int        `json:"token_id"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"user_agent"`
+}
+
+/*
+ * A single-use token emailed to the account owner. Like refresh tokens, only the hash is stored.
+ * It only works while the account still has the email it was sent to.
+ */
+type AccountToken struct {
+	TokenID   int
+	UserID    int
+	Email     string
+	Purpose   string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type accessClaims struct {
+	Subject   int   `json:"sub"`
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+/* Signs and verifies HS256 JWT access tokens */
+type TokenAuthority struct {
+	secret []byte
+}
+
+func NewTokenAuthority(secret string) *TokenAuthority {
+	if secret == "" {
+		log.Println("- JWT_SECRET is not set, using a random secret. Tokens will not survive a restart")
+		secret = randomToken(32)
+	}
+
+	return &TokenAuthority{secret: []byte(secret)}
+}
+
+func NewTokenAuthorityFromEnv() *TokenAuthority {
+	return NewTokenAuthority(os.Getenv("JWT_SECRET"))
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+func (t *TokenAuthority) SignAccessToken(userID int, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(accessTokenTTL)
+	claims, err := json.Marshal(accessClaims{
+		Subject:   userID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return unsigned + "." + t.sign(unsigned), expiresAt, nil
+}
+
+/* Returns the user id the token was issued for */
+func (t *TokenAuthority) ParseAccessToken(token string, now time.Time) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return 0, ErrInvalidToken
+	}
+
+	expected := t.sign(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return 0, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	var claims accessClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	if now.Unix() >= claims.ExpiresAt {
+		return 0, ErrInvalidToken
+	}
+
+	return claims.Subject, nil
+}
+
+func (t *TokenAuthority) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+/* Issues an access token plus a new refresh token in `familyID` for the client making `r` */
+func (s *APIServer) issueTokens(r *http.Request, userID int, familyID string) (*TokenResponse, error) {
+	now := time.Now().UTC()
+	access, expiresAt, err := s.auth.SignAccessToken(userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := randomToken(32)
+	if err := s.store.CreateRefreshToken(r.Context(), &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refresh),
+		CreatedAt: now,
+		ExpiresAt: now.Add(refreshTokenTTL),
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresAt:    expiresAt,
+		RefreshToken: refresh,
+	}, nil
+}
+
+/*
+ * Populates the current account into the request context when a bearer token is
+ * present. Requests without an Authorization header pass through anonymously.
+ */
+func (s *APIServer) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			writeError(w, r, Unauthorized("%s", ErrInvalidToken.Message))
+			return
+		}
+
+		userID, err := s.auth.ParseAccessToken(token, time.Now())
+		if err != nil {
+			writeError(w, r, Unauthorized("%s", err))
+			return
+		}
+
+		account, err := s.store.GetAccountByID(r.Context(), userID)
+		if err != nil {
+			if hasCode(err, CodeNotFound) {
+				err = Unauthorized("%s", ErrInvalidToken.Message)
+			}
+			writeError(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), accountContextKey, account)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/* Returns the authenticated account, or nil for anonymous requests */
+func currentAccount(r *http.Request) *Account {
+	account, _ := r.Context().Value(accountContextKey).(*Account)
+	return account
+}
+
+// POST api/auth/login
+func (s *APIServer) handleLoginRequest(w http.ResponseWriter, r *http.Request) error {
+	req := new(LoginRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	stats, err := s.store.GetLoginFailureStats(r.Context(), req.Username, clientIP(r), now.Add(-loginFailureWindow))
+	if err != nil {
+		return err
+	}
+
+	if wait := loginRetryAfter(stats, now); wait > 0 {
+		return errLoginLocked(wait)
+	}
+
+	attempt := &LoginAttempt{
+		Username:    strings.ToLower(req.Username),
+		IP:          clientIP(r),
+		UserAgent:   r.UserAgent(),
+		AttemptedAt: now,
+	}
+
+	account, err := s.store.VerifyAccountPassword(r.Context(), req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			// Not cancellable, otherwise hanging up before the write would dodge the backoff
+			if err := s.store.RecordLoginAttempt(context.WithoutCancel(r.Context()), attempt); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	attempt.UserID = &account.UserID
+	attempt.Success = true
+	if err := s.store.RecordLoginAttempt(r.Context(), attempt); err != nil {
+		return err
+	}
+
+	tokens, err := s.issueTokens(r, account.UserID, randomToken(16))
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, tokens)
+}
+
+// POST api/auth/refresh
+func (s *APIServer) handleRefreshRequest(w http.ResponseWriter, r *http.Request) error {
+	req := new(RefreshRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	old, err := s.store.ConsumeRefreshToken(r.Context(), hashToken(req.RefreshToken))
+	if err != nil {
+		return err
+	}
+
+	tokens, err := s.issueTokens(r, old.UserID, old.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, tokens)
+}
+
+// POST api/auth/logout
+func (s *APIServer) handleLogoutRequest(w http.ResponseWriter, r *http.Request) error {
+	req := new(RefreshRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	if err := s.store.RevokeRefreshToken(r.Context(), hashToken(req.RefreshToken)); err != nil {
+		return fmt.Errorf("logout failed: %w", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+/*
+ * Stores a new single-use token for `account` and emails a link containing it. Each address only
+ * gets a few emails a minute, so the routes that send them can't flood someone's inbox.
+ */
+func (s *APIServer) sendAccountTokenEmail(ctx context.Context, account *Account, purpose string, ttl time.Duration, email *Email, path string) error {
+	now := time.Now().UTC()
+	if ok, _ := s.limits.mailTo.Allow(strings.ToLower(account.Email), now); !ok {
+		return fmt.Errorf("too many emails to %s, not sending another", account.Email)
+	}
+
+	token := randomToken(32)
+	if err := s.store.CreateAccountToken(ctx, &AccountToken{
+		UserID:    account.UserID,
+		Email:     account.Email,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}); err != nil {
+		return err
+	}
+
+	email.To = account.Email
+	email.Body = fmt.Sprintf(email.Body, account.Username, appURL()+path+"?token="+token)
+	return s.mailer.Send(email)
+}
+
+func (s *APIServer) sendVerificationEmail(ctx context.Context, account *Account) error {
+	return s.sendAccountTokenEmail(ctx, account, tokenPurposeVerifyEmail, verifyEmailTokenTTL, &Email{
+		Subject: "Verify your AlgoDuels email",
+		Body:    "Hi %s,\n\nConfirm your email address by opening this link:\n%s\n\nThe link expires in 48 hours.",
+	}, "/verify-email")
+}
+
+func (s *APIServer) sendPasswordResetEmail(ctx context.Context, account *Account) error {
+	return s.sendAccountTokenEmail(ctx, account, tokenPurposeResetPassword, resetPasswordTokenTTL, &Email{
+		Subject: "Reset your AlgoDuels password",
+		Body:    "Hi %s,\n\nSomeone asked to reset your password. If it was you, open this link:\n%s\n\nThe link expires in 1 hour. If you didn't ask for this, you can ignore this email.",
+	}, "/reset-password")
+}
+
+/* Base URL of the frontend, used for links in emails */
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// POST api/auth/verify
+func (s *APIServer) handleVerifyEmailRequest(w http.ResponseWriter, r *http.Request) error {
+	req := new(VerifyEmailRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	token, err := s.store.ConsumeAccountToken(r.Context(), tokenPurposeVerifyEmail, hashToken(req.Token))
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.MarkEmailVerified(r.Context(), token.UserID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// POST api/auth/forgot-password
+// Always answers 202 so the endpoint can't be used to find out which emails are registered
+func (s *APIServer) handleForgotPasswordRequest(w http.ResponseWriter, r *http.Request) error {
+	req := new(ForgotPasswordRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByEmail(r.Context(), strings.TrimSpace(req.Email))
+	if err == nil {
+		if err := s.sendPasswordResetEmail(r.Context(), account); err != nil {
+			log.Println("- Failed to send password reset email:", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// POST api/auth/reset-password
+func (s *APIServer) handleResetPasswordRequest(w http.ResponseWriter, r *http.Request) error {
+	req := new(ResetPasswordRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	token, err := s.store.ConsumeAccountToken(r.Context(), tokenPurposeResetPassword, hashToken(req.Token))
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.ResetAccountPassword(r.Context(), token.UserID, req.NewPassword); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
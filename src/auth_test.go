@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+var emailedToken = regexp.MustCompile(`\?token=(\S+)`)
+
+/* The token in the last email sent to `to` */
+func lastEmailedToken(t *testing.T, mailer *LogMailer, to string) string {
+	t.Helper()
+	for i := len(mailer.Sent) - 1; i >= 0; i-- {
+		if e := mailer.Sent[i]; e.To == to {
+			if match := emailedToken.FindStringSubmatch(e.Body); match != nil {
+				return match[1]
+			}
+		}
+	}
+	t.Fatalf("no token was emailed to %s", to)
+	return ""
+}
+
+func TestEmailedTokensStopWorkingWhenTheEmailChanges(t *testing.T) {
+	c := newSpecClient(t)
+	mailer := NewLogMailer("")
+	c.server.mailer = mailer
+
+	account := c.call("POST", "/accounts", "", CreateAccountRequest{Username: "alice", Email: "alice@example.com", Password: testPassword}, http.StatusCreated)
+	path := fmt.Sprintf("/accounts/%d", int(account["user_id"].(float64)))
+	tokens := c.call("POST", "/auth/login", "", LoginRequest{Username: "alice", Password: testPassword}, http.StatusOK)
+	alice := tokens["access_token"].(string)
+
+	oldVerify := lastEmailedToken(t, mailer, "alice@example.com")
+	c.call("POST", "/auth/forgot-password", "", ForgotPasswordRequest{Email: "alice@example.com"}, http.StatusAccepted)
+	oldReset := lastEmailedToken(t, mailer, "alice@example.com")
+
+	newEmail := "alice@example.org"
+	c.call("PATCH", path, alice, UpdateAccountRequest{Email: &newEmail}, http.StatusOK)
+
+	c.call("POST", "/auth/verify", "", VerifyEmailRequest{Token: oldVerify}, http.StatusBadRequest)
+	c.call("POST", "/auth/reset-password", "", ResetPasswordRequest{Token: oldReset, NewPassword: testPassword + "!"}, http.StatusBadRequest)
+	if got := c.call("GET", path, alice, nil, http.StatusOK); got["email_verified"] != false {
+		t.Errorf("expected the new email to be unverified, got %v", got["email_verified"])
+	}
+
+	c.call("POST", "/auth/verify", "", VerifyEmailRequest{Token: lastEmailedToken(t, mailer, newEmail)}, http.StatusNoContent)
+	if got := c.call("GET", path, alice, nil, http.StatusOK); got["email_verified"] != true {
+		t.Errorf("expected the new email to be verified, got %v", got["email_verified"])
+	}
+}
+
+/* The log mailer is the default outside production, so what it keeps has to stay bounded */
+func TestLogMailerKeepsTheLastEmails(t *testing.T) {
+	mailer := NewLogMailer(t.TempDir() + "/mail.log")
+	for i := 0; i < 2*logMailerKeep; i++ {
+		if err := mailer.Send(&Email{To: fmt.Sprintf("user%d@example.com", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(mailer.Sent) != logMailerKeep || mailer.Sent[logMailerKeep-1].To != fmt.Sprintf("user%d@example.com", 2*logMailerKeep-1) {
+		t.Errorf("expected the last %d emails, got %d", logMailerKeep, len(mailer.Sent))
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/* Stable, machine readable error codes. Clients should switch on these, not on messages */
+const (
+	CodeBadRequest          = "bad_request"
+	CodeValidationFailed    = "validation_failed"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeConflict            = "conflict"
+	CodePayloadTooLarge     = "payload_too_large"
+	CodeRateLimited         = "rate_limited"
+	CodeUpstreamFailed      = "upstream_failed"
+	CodeExecutorUnavailable = "executor_unavailable"
+	CodeInternal            = "internal_error"
+)
+
+var errorStatus = map[string]int{
+	CodeBadRequest:          http.StatusBadRequest,
+	CodeValidationFailed:    http.StatusBadRequest,
+	CodeUnauthorized:        http.StatusUnauthorized,
+	CodeForbidden:           http.StatusForbidden,
+	CodeNotFound:            http.StatusNotFound,
+	CodeMethodNotAllowed:    http.StatusMethodNotAllowed,
+	CodeConflict:            http.StatusConflict,
+	CodePayloadTooLarge:     http.StatusRequestEntityTooLarge,
+	CodeRateLimited:         http.StatusTooManyRequests,
+	CodeUpstreamFailed:      http.StatusBadGateway,
+	CodeExecutorUnavailable: http.StatusServiceUnavailable,
+	CodeInternal:            http.StatusInternalServerError,
+}
+
+/*
+ * An error that is safe to show to the client. Message is sent as is, Err is the underlying
+ * cause and is only ever logged. Handlers return these and makeHTTPHandlerFunc picks the
+ * status from Code. Anything that isn't an AppError (or ValidationErrors) is treated as an
+ * internal error: logged, and answered with a generic 500.
+ */
+type AppError struct {
+	Code       string
+	Message    string
+	Fields     map[string]string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AppError) Status() int {
+	if status, ok := errorStatus[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func newAppError(code, format string, args ...interface{}) *AppError {
+	return &AppError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func BadRequest(format string, args ...interface{}) *AppError {
+	return newAppError(CodeBadRequest, format, args...)
+}
+
+/* A request body that isn't valid JSON for the target type. The decoder's message is safe to show */
+func InvalidBody(err error) *AppError {
+	return BadRequest("invalid request body: %s", err)
+}
+
+func Unauthorized(format string, args ...interface{}) *AppError {
+	return newAppError(CodeUnauthorized, format, args...)
+}
+
+func Forbidden(format string, args ...interface{}) *AppError {
+	return newAppError(CodeForbidden, format, args...)
+}
+
+func NotFound(format string, args ...interface{}) *AppError {
+	return newAppError(CodeNotFound, format, args...)
+}
+
+func MethodNotAllowed(format string, args ...interface{}) *AppError {
+	return newAppError(CodeMethodNotAllowed, format, args...)
+}
+
+func Conflict(format string, args ...interface{}) *AppError {
+	return newAppError(CodeConflict, format, args...)
+}
+
+func PayloadTooLarge(limit int64) *AppError {
+	return newAppError(CodePayloadTooLarge, "request body must be at most %d bytes", limit)
+}
+
+func RateLimited(retryAfter time.Duration, message string) *AppError {
+	return &AppError{Code: CodeRateLimited, Message: message, RetryAfter: retryAfter}
+}
+
+/* A third party (an OAuth provider) failed. `err` is logged, `message` is what the client sees */
+func UpstreamFailed(err error, format string, args ...interface{}) *AppError {
+	e := newAppError(CodeUpstreamFailed, format, args...)
+	e.Err = err
+	return e
+}
+
+/* The code runner (judge0) could not be reached or did not finish in time */
+func ExecutorUnavailable(err error) *AppError {
+	return &AppError{Code: CodeExecutorUnavailable, Message: "code execution is unavailable, try again later", Err: err}
+}
+
+/* Whether `err` is, or wraps, an AppError with `code` */
+func hasCode(err error, code string) bool {
+	var appErr *AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
+
+/* Writes `err` as an ApiError, logging whatever the client doesn't get to see */
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var validation ValidationErrors
+	if errors.As(err, &validation) {
+		WriteJSON(w, http.StatusBadRequest, ApiError{Error: "validation failed", Code: CodeValidationFailed, Fields: validation})
+		return
+	}
+
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
+		log.Printf("- %s %s: %v", r.Method, r.URL.Path, err)
+		WriteJSON(w, http.StatusInternalServerError, ApiError{Error: "internal server error", Code: CodeInternal})
+		return
+	}
+
+	if appErr.Err != nil {
+		log.Printf("- %s %s: %v", r.Method, r.URL.Path, appErr)
+	}
+	if appErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+
+	WriteJSON(w, appErr.Status(), ApiError{Error: appErr.Message, Code: appErr.Code, Fields: appErr.Fields})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"not found", NotFound("Account %d not found", 5), http.StatusNotFound, CodeNotFound, "Account 5 not found"},
+		{"wrapped sentinel", fmt.Errorf("update failed: %w", ErrUsernameTaken), http.StatusConflict, CodeConflict, ErrUsernameTaken.Message},
+		{"validation", ValidationErrors{"username": "is reserved"}, http.StatusBadRequest, CodeValidationFailed, "validation failed"},
+		{"unauthenticated", errUnauthenticated, http.StatusUnauthorized, CodeUnauthorized, errUnauthenticated.Message},
+		{"forbidden", errForbidden, http.StatusForbidden, CodeForbidden, errForbidden.Message},
+		{"rate limited", RateLimited(1500*time.Millisecond, "slow down"), http.StatusTooManyRequests, CodeRateLimited, "slow down"},
+		{"upstream", UpstreamFailed(errors.New("token endpoint returned 500"), "could not sign in"), http.StatusBadGateway, CodeUpstreamFailed, "could not sign in"},
+		{"executor", ExecutorUnavailable(errors.New("connection refused")), http.StatusServiceUnavailable, CodeExecutorUnavailable, "code execution is unavailable, try again later"},
+		{"internal", errors.New(`pq: relation "account" does not exist`), http.StatusInternalServerError, CodeInternal, "internal server error"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, httptest.NewRequest(http.MethodGet, "/api/test", nil), c.err)
+
+			if w.Code != c.status {
+				t.Errorf("expected status %d, got %d", c.status, w.Code)
+			}
+
+			var body ApiError
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != c.code || body.Error != c.message {
+				t.Errorf("expected %s %q, got %s %q", c.code, c.message, body.Code, body.Error)
+			}
+		})
+	}
+}
+
+func TestWriteErrorRetryAfter(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest(http.MethodPost, "/api/run", nil), RateLimited(1500*time.Millisecond, "slow down"))
+
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After to round up to 2, got %q", got)
+	}
+}
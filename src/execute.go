@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,6 +16,15 @@ const (
 	judge0Url       = "http://localhost:2358/submissions" // judge0 url
 	judge0UrlParams = "?&fields=stdout,time,memory,stderr,compile_output,message,status"
 	apiUrl          = "http://localhost:4000/api"
+
+	judge0StatusInQueue    = 1
+	judge0StatusProcessing = 2
+	judge0StatusAccepted   = 3
+	judge0StatusTimeLimit  = 5
+	judge0StatusCompile    = 6
+
+	judge0PollInterval = time.Second
+	judge0Timeout      = 20 * time.Second
 )
 
 var languageIDs = map[string]int{
@@ -28,10 +38,17 @@ type RunRes struct {
 }
 
 type ExecReq struct {
-	ProblemID     int    `json:"problem_id"`
-	LanguageID    int    `json:"language_id"`
+	ProblemID     int    `json:"problem_id" validate:"required"`
+	LanguageID    int    `json:"language_id" validate:"required"`
 	SourceCode    string `json:"source_code"`
-	IsSanityCheck bool   `json:"is_sanity_check"`
+	IsSanityCheck bool   `json:"is_sanity_check,omitempty"`
+}
+
+/* Body of a judge0 create submission request */
+type Judge0Submission struct {
+	SourceCode string `json:"source_code"`
+	LanguageID int    `json:"language_id"`
+	Stdin      string `json:"stdin"`
 }
 
 type Result struct {
@@ -39,15 +56,51 @@ type Result struct {
 	TestResults []TestResult `json:"result"`
 }
 
+/* The verdict of a submission that got this result, which is decided by its first failure */
+func (r *Result) verdict() string {
+	if r.Passed || len(r.TestResults) == 0 {
+		return VerdictAccepted
+	}
+	return r.TestResults[len(r.TestResults)-1].verdict
+}
+
 type CrSubRes struct {
 	Token string `json:"token"`
 }
 
 type TestResult struct {
-	Input    string `json:"input"`
-	Output   string `json:"output"`
-	Expected string `json:"expected"`
+	Kind     string `json:"kind"`
+	Hidden   bool   `json:"hidden"`
+	Input    string `json:"input,omitempty"`
+	Output   string `json:"output,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Error    string `json:"error,omitempty"`
 	Passed   bool   `json:"passed"`
+
+	status  string // what went wrong without the program's own output, see conceal
+	verdict string // what a submission failing here is judged as
+}
+
+/* Why the test case failed, for people */
+func (tr *TestResult) failure() string {
+	if tr.Error != "" {
+		return "failed with: " + tr.Error
+	}
+	return fmt.Sprintf("returned %s, expected %s", tr.Output, tr.Expected)
+}
+
+/*
+ * Strips everything that would reveal a hidden test case's data. Error is stderr or compiler
+ * output, which a program can fill with its input, so only judge0's status is left of it.
+ */
+func (tr *TestResult) conceal() {
+	tr.Hidden = true
+	tr.Input = ""
+	tr.Output = ""
+	tr.Expected = ""
+	tr.Stdout = ""
+	tr.Error = tr.status
 }
 
 type ExecResult struct {
@@ -63,38 +116,67 @@ type ExecResult struct {
 	} `json:"status"`
 }
 
+/* The most useful error text judge0 gave us for a run that wasn't accepted */
+func (e *ExecResult) errorOutput() string {
+	switch {
+	case e.CompileOutput != "":
+		return e.CompileOutput
+	case e.Stderr != nil && *e.Stderr != "":
+		return *e.Stderr
+	case e.Message != "":
+		return e.Message
+	}
+	return e.Status.Description
+}
+
+/*
+ * Runs programs. The server uses judge0, tests substitute their own. Execute gives up when ctx
+ * is done, so a run nobody waits for anymore doesn't keep polling.
+ */
+type Executor interface {
+	Execute(ctx context.Context, req *Judge0Submission) (*ExecResult, error)
+}
+
+type judge0Executor struct{}
+
+func (judge0Executor) Execute(ctx context.Context, req *Judge0Submission) (*ExecResult, error) {
+	return execute(ctx, req)
+}
+
 /* Executes some code and returns result of execution */
-func execute(req *ExecReq) (*ExecResult, error) {
-	fmt.Println("executing...")
+func execute(ctx context.Context, req *Judge0Submission) (*ExecResult, error) {
 	jsonReq, err := json.Marshal(req) // marshalled (JSONified) judge0 req body, we convert to raw byte slice for sending
 	if err != nil {
-		fmt.Println("error marshalling json")
 		return nil, err
 	}
 
-	fmt.Println("successfully marshalled json: ", jsonReq)
-
 	/* Create judge0 code submission */
-	res, err := http.Post(judge0Url+judge0UrlParams, "application/json", bytes.NewReader(jsonReq)) // http.Post takes io.Reader for the request body
+	createReq, err := http.NewRequestWithContext(ctx, http.MethodPost, judge0Url+judge0UrlParams, bytes.NewReader(jsonReq))
 	if err != nil {
-		fmt.Println("Error sending code to judge0")
 		return nil, err
 	}
+	createReq.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(createReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ExecutorUnavailable(err)
+	}
 	defer res.Body.Close()
 
 	/* Parse judge0 create submission response */
 	var crSubRes CrSubRes
 	err = json.NewDecoder(res.Body).Decode(&crSubRes)
 	if err != nil {
-		return nil, err
+		return nil, ExecutorUnavailable(err)
 	}
 
 	/* Extract token */
 	token := crSubRes.Token
-	fmt.Println(token)
 
 	/* Poll judge0 until code has finished executing and output is ready */
-	execResult, err := pollJudge0Submission(token)
+	execResult, err := pollJudge0Submission(ctx, token)
 	if err != nil {
 		return nil, err
 	}
@@ -102,96 +184,44 @@ func execute(req *ExecReq) (*ExecResult, error) {
 	return execResult, nil
 }
 
-/**
- * Gets sanity check test cases for a problem id, calls test(), returns Result
- */
-func run(s *APIServer, req *ExecReq) (*ExecResult, error) {
-	result := new(ExecResult)
-
-	fmt.Println(req.ProblemID)
-	if req.IsSanityCheck {
-		// fetch sanity tests
-		tests, err := s.store.GetTestCaseSanityChecks(req.ProblemID)
-		if err != nil {
-			fmt.Println("ERROR!")
-			return nil, err
+/* Polls judge0 to retreive the results of the submission associated with `token` */
+func pollJudge0Submission(ctx context.Context, token string) (*ExecResult, error) {
+	timeout := time.NewTimer(judge0Timeout)
+	defer timeout.Stop()
+	tick := time.NewTicker(judge0PollInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return nil, ExecutorUnavailable(errors.New("judge0 did not finish the submission in time"))
+		case <-tick.C:
 		}
 
-		fmt.Println(tests)
-	} else {
-		// fetch all tests
-		tests, err := s.store.GetTestCasesByProblemID(req.ProblemID)
+		getReq, err := http.NewRequestWithContext(ctx, http.MethodGet, judge0Url+"/"+token, nil)
 		if err != nil {
 			return nil, err
 		}
-
-		for i := 0; i < len(tests); i++ {
-			var inputValues string
-			for _, val := range tests[i].IO.Input {
-				inputValues += fmt.Sprintf("%v,", val)
-			}
-			inputValues = inputValues[:len(inputValues)-1] // remove last comma
-			fmt.Println(tests[i])
-		}
-	}
-
-	fmt.Println(req)
-	fmt.Println(req.SourceCode)
-
-	return result, nil
-}
-
-/**
- * executes code and checks if the results are correct
- */
-func test(execReq *ExecReq, testCases *[]TestCase) *TestResult {
-
-	result := new(TestResult)
-	return result
-}
-
-/* Polls judge0 to retreive the results of the submission associated with `token` */
-func pollJudge0Submission(token string) (*ExecResult, error) {
-	timeout := 20 * time.Second
-	startTime := time.Now()
-	time.Sleep(time.Second)
-	ran := 0
-
-	for time.Since(startTime) < timeout {
-		fmt.Println("Giving submission time to process...")
-		time.Sleep(time.Second * 1)
-		fmt.Println("Sending GET request...")
-		outputResp, err := http.Get(judge0Url + "/" + token)
-		ran++
+		outputResp, err := http.DefaultClient.Do(getReq)
 		if err != nil {
-			fmt.Println("Error during GET request, retrying... ")
-			continue
+			continue // judge0 may not have the submission yet, try again
 		}
 
 		/* Parse get submission response */
 		outputRespStruct := new(ExecResult)
 		outputErr := json.NewDecoder(outputResp.Body).Decode(&outputRespStruct)
+		outputResp.Body.Close()
 		if outputErr != nil {
-			fmt.Println("Error decoding outputResp body", outputErr)
-			fmt.Printf("ran %d times", ran)
-			return nil, outputErr
+			return nil, ExecutorUnavailable(outputErr)
 		}
-		defer outputResp.Body.Close()
-
-		if outputRespStruct.Status.ID == 3 {
-			fmt.Println("Response: ", outputRespStruct)
-			return outputRespStruct, nil
-		} else {
-			if outputRespStruct.Status.ID == 2 {
-				continue
-			}
-
-			fmt.Println(outputRespStruct)
-			fmt.Println("status.id: ", outputRespStruct.Status.ID)
-			fmt.Println("Status.Description: ", outputRespStruct.Status.Description)
-			return nil, errors.New("Error description: " + outputRespStruct.Status.Description)
+
+		if id := outputRespStruct.Status.ID; id == judge0StatusInQueue || id == judge0StatusProcessing {
+			continue
 		}
-	}
 
-	return nil, errors.New("Time limit exceeded")
+		// Compile errors, runtime errors and time limits are results too, the caller reports them
+		return outputRespStruct, nil
+	}
 }
@@ -1,15 +1,18 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
 
 type ExecBatchReq struct {
-	Submissions []ExecReq `json:"submissions"`
+	Submissions []ExecReq `json:"submissions" validate:"required,max=20"`
 }
 
 // GET api/users/{id}
@@ -19,41 +22,57 @@ func (s *APIServer) handleGetAccountByID(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
-	account, err := s.store.GetAccountByID(id)
+	account, err := s.store.GetAccountByID(r.Context(), id)
 	if err != nil {
 		return err
 	}
 
-	return WriteJSON(w, http.StatusOK, account)
+	return WriteJSON(w, http.StatusOK, account.ViewFor(currentAccount(r)))
 }
 
 // GET api/users
 func (s *APIServer) handleGetAccount(w http.ResponseWriter, r *http.Request) error {
-	accounts, err := s.store.GetAccounts()
+	query := new(ListAccountsQuery)
+	if err := decodeQuery(r, query); err != nil {
+		return err
+	}
+	page, err := query.page(query.Sort, accountOrder)
+	if err != nil {
+		return err
+	}
 
+	accounts, err := s.store.GetAccounts(r.Context(), page)
 	if err != nil {
 		return err
 	}
 
-	return WriteJSON(w, http.StatusOK, accounts)
+	views := &Page[interface{}]{Items: make([]interface{}, len(accounts.Items)), NextCursor: accounts.NextCursor}
+	for i, account := range accounts.Items {
+		views.Items[i] = account.ViewFor(currentAccount(r))
+	}
+
+	return WriteJSON(w, http.StatusOK, views)
 }
 
 // POST api/users
 func (s *APIServer) handleCreateAccount(w http.ResponseWriter, r *http.Request) error {
 	var acc CreateAccountRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&acc); err != nil {
+	if err := decodeJSON(w, r, &acc); err != nil {
 		return err
 	}
-	defer r.Body.Close()
 
 	accountReq := NewAccountRequest(acc.Username, acc.FirstName, acc.LastName, acc.Email, acc.Password)
-	accountRes, err := s.store.CreateAccount(accountReq)
+	account, err := s.store.CreateAccount(r.Context(), accountReq)
 	if err != nil {
 		return err
 	}
 
-	return WriteJSON(w, http.StatusCreated, accountRes)
+	if err := s.sendVerificationEmail(r.Context(), account); err != nil {
+		log.Println("- Failed to send verification email:", err)
+	}
+
+	return WriteJSON(w, http.StatusCreated, account.Self())
 }
 
 // DELETE api/users
@@ -63,21 +82,104 @@ func (s *APIServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
-	if err := s.store.DeleteAccount(id); err != nil {
+	if err := s.store.DeleteAccount(r.Context(), id); err != nil {
 		return err
 	}
 
-	return WriteJSON(w, http.StatusNoContent, map[string]int{"deleted": id})
+	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
 
-// GET api/problems/{id}
-func (s *APIServer) handleGetProblemByID(w http.ResponseWriter, r *http.Request) error {
-	id, err := getID(r, "problem_id")
+// PATCH api/accounts/{id}
+func (s *APIServer) handleUpdateAccount(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r, "user_id")
+	if err != nil {
+		return err
+	}
+
+	req := new(UpdateAccountRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	account, err := s.store.GetAccountByID(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	if req.FirstName != nil {
+		account.FirstName = *req.FirstName
+	}
+	if req.LastName != nil {
+		account.LastName = *req.LastName
+	}
+	if req.Username != nil {
+		account.Username = *req.Username
+	}
+	emailChanged := req.Email != nil && !strings.EqualFold(*req.Email, account.Email)
+	if req.Email != nil {
+		account.Email = *req.Email
+	}
+
+	if err := s.store.UpdateAccount(r.Context(), account); err != nil {
+		return err
+	}
+
+	if emailChanged {
+		account.EmailVerified = false
+		if err := s.sendVerificationEmail(r.Context(), account); err != nil {
+			log.Println("- Failed to send verification email:", err)
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, account.ViewFor(currentAccount(r)))
+}
+
+// POST api/accounts/{id}/password
+func (s *APIServer) handleChangePassword(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r, "user_id")
+	if err != nil {
+		return err
+	}
+
+	req := new(ChangePasswordRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	if err := s.store.ChangeAccountPassword(r.Context(), id, req.OldPassword, req.NewPassword); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			return Forbidden("old password is incorrect")
+		}
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// PUT api/accounts/{id}/role
+func (s *APIServer) handleUpdateAccountRole(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r, "user_id")
 	if err != nil {
 		return err
 	}
 
-	p, err := s.store.GetProblemByID(id)
+	req := new(UpdateRoleRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	if err := s.store.UpdateAccountRole(r.Context(), id, req.Role); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"role": req.Role})
+}
+
+// GET api/problems/{id}
+func (s *APIServer) handleGetProblemByID(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.visibleProblem(r)
 	if err != nil {
 		return err
 	}
@@ -85,9 +187,49 @@ func (s *APIServer) handleGetProblemByID(w http.ResponseWriter, r *http.Request)
 	return WriteJSON(w, http.StatusOK, p)
 }
 
+/* The problem in the route's {id}, not found unless the caller may see it */
+func (s *APIServer) visibleProblem(r *http.Request) (*Problem, error) {
+	id, err := getID(r, "problem_id")
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.store.GetProblemByID(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.checkVisibleTo(currentAccount(r)); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 // GET api/problems
 func (s *APIServer) handleGetProblems(w http.ResponseWriter, r *http.Request) error {
-	problems, err := s.store.GetProblems()
+	query := new(ListProblemsQuery)
+	if err := decodeQuery(r, query); err != nil {
+		return err
+	}
+	page, err := query.page(query.Sort, problemOrder)
+	if err != nil {
+		return err
+	}
+
+	filter := ProblemFilter{Difficulty: query.Difficulty, Tag: query.Tag, Statuses: []string{ProblemPublished}, PageRequest: page}
+	if query.Solved != nil {
+		account := currentAccount(r)
+		if account == nil {
+			return errUnauthenticated
+		}
+		if *query.Solved {
+			filter.SolvedBy = account.UserID
+		} else {
+			filter.UnsolvedBy = account.UserID
+		}
+	}
+
+	problems, err := s.store.GetProblems(r.Context(), filter)
 	if err != nil {
 		return err
 	}
@@ -97,55 +239,460 @@ func (s *APIServer) handleGetProblems(w http.ResponseWriter, r *http.Request) er
 
 func (s *APIServer) handleGetProblemByName(w http.ResponseWriter, r *http.Request) error {
 	name := mux.Vars(r)["name"]
-	fmt.Println(name)
-	problem, err := s.store.GetProblemByName(name)
+	problem, err := s.store.GetProblemByName(r.Context(), name)
 	if err != nil {
 		return err
 	}
 
+	if err := problem.checkVisibleTo(currentAccount(r)); err != nil {
+		return err
+	}
+
 	return WriteJSON(w, http.StatusOK, problem)
 }
 
 // POST api/problems
+// Problems start out as drafts of the caller's, see workflow.go
 func (s *APIServer) handleCreateProblem(w http.ResponseWriter, r *http.Request) error {
 	req := new(CreateProblemRequest)
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+	if err := decodeJSON(w, r, req); err != nil {
 		return err
 	}
-	defer r.Body.Close()
+
 	problem := NewProblem(req.ProblemName, req.Prompt, req.StarterCode, req.FunctionName, uint8(req.Difficulty))
-	problemID, err := s.store.CreateProblem(problem)
+	problem.RevealHiddenOnFailure = req.RevealHiddenOnFailure
+	problem.Slug = req.Slug
+	problem.Tags = req.Tags
+	problem.AuthorID = &currentAccount(r).UserID
+
+	for i := range req.TestCases {
+		if req.TestCases[i].Kind == "" {
+			req.TestCases[i].Kind = TestCaseHidden
+		}
+	}
+
+	// All or nothing, a problem without its test cases can't be judged
+	err := s.store.WithTx(r.Context(), func(tx Storage) error {
+		problemID, err := tx.CreateProblem(r.Context(), problem)
+		if err != nil {
+			return err
+		}
+		problem.ProblemID = problemID
+
+		for _, tc := range req.TestCases {
+			if _, err := tx.CreateTestCase(r.Context(), NewTestCase(problemID, tc.IO.Input, tc.IO.Output, tc.Kind)); err != nil {
+				return err
+			}
+		}
+
+		// Read back for the tags as stored, sorted and without duplicates
+		problem, err = tx.GetProblemByID(r.Context(), problemID)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	problem.ProblemID = problemID
-
 	return WriteJSON(w, http.StatusCreated, problem)
 }
 
+// GET api/problems/slug/{slug}
+func (s *APIServer) handleGetProblemBySlug(w http.ResponseWriter, r *http.Request) error {
+	problem, err := s.store.GetProblemBySlug(r.Context(), mux.Vars(r)["slug"])
+	if err != nil {
+		return err
+	}
+
+	if err := problem.checkVisibleTo(currentAccount(r)); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, problem)
+}
+
+// GET api/problems/drafts
+func (s *APIServer) handleGetDrafts(w http.ResponseWriter, r *http.Request) error {
+	query := new(ListDraftsQuery)
+	if err := decodeQuery(r, query); err != nil {
+		return err
+	}
+	page, err := query.page(query.Sort, problemOrder)
+	if err != nil {
+		return err
+	}
+
+	filter := ProblemFilter{Statuses: []string{ProblemDraft, ProblemInReview}, PageRequest: page}
+	if query.Status != "" {
+		filter.Statuses = []string{query.Status}
+	}
+	if account := currentAccount(r); !account.HasRole(RoleAdmin) {
+		filter.AuthorID = account.UserID
+	}
+
+	problems, err := s.store.GetProblems(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, problems)
+}
+
+// PUT api/problems/{id}
+func (s *APIServer) handleUpdateProblem(w http.ResponseWriter, r *http.Request) error {
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+
+	account := currentAccount(r)
+	if err := problem.checkEditableBy(account); err != nil {
+		return err
+	}
+
+	req := new(UpdateProblemRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	problem.Revision = req.Revision
+	problem.ProblemName = req.ProblemName
+	problem.Prompt = req.Prompt
+	problem.StarterCode = req.StarterCode
+	problem.Difficulty = uint8(req.Difficulty)
+	problem.FunctionName = req.FunctionName
+	problem.RevealHiddenOnFailure = req.RevealHiddenOnFailure
+	problem.Tags = req.Tags
+	if req.Slug != "" {
+		problem.Slug = req.Slug
+	}
+
+	if err := s.store.UpdateProblem(r.Context(), problem, &account.UserID); err != nil {
+		return err
+	}
+
+	problem, err = s.store.GetProblemByID(r.Context(), problem.ProblemID)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, problem)
+}
+
+// GET api/problems/{id}/revisions
+func (s *APIServer) handleGetProblemRevisions(w http.ResponseWriter, r *http.Request) error {
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+
+	if account := currentAccount(r); !problem.authoredBy(account) && !account.HasRole(RoleAdmin) {
+		return errForbidden
+	}
+
+	revisions, err := s.store.GetProblemRevisions(r.Context(), problem.ProblemID)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, revisions)
+}
+
+// POST api/problems/{id}/review
+func (s *APIServer) handleSubmitProblemForReview(w http.ResponseWriter, r *http.Request) error {
+	return s.moveProblem(w, r, ProblemInReview, nil)
+}
+
+// POST api/problems/{id}/withdraw
+func (s *APIServer) handleWithdrawProblem(w http.ResponseWriter, r *http.Request) error {
+	return s.moveProblem(w, r, ProblemDraft, nil)
+}
+
+// POST api/problems/{id}/publish
+func (s *APIServer) handlePublishProblem(w http.ResponseWriter, r *http.Request) error {
+	return s.moveProblem(w, r, ProblemPublished, func(p *Problem) error {
+		return s.checkPublishable(r.Context(), p)
+	})
+}
+
+// POST api/problems/{id}/retire
+func (s *APIServer) handleRetireProblem(w http.ResponseWriter, r *http.Request) error {
+	return s.moveProblem(w, r, ProblemRetired, nil)
+}
+
+/*
+ * Moves the route's problem to `to` once `check`, if any, passes. Only its author and admins
+ * may, the routes decide which of the moves need an admin.
+ */
+func (s *APIServer) moveProblem(w http.ResponseWriter, r *http.Request, to string, check func(*Problem) error) error {
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+
+	if account := currentAccount(r); !problem.authoredBy(account) && !account.HasRole(RoleAdmin) {
+		return errForbidden
+	}
+	if err := problem.canMoveTo(to); err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(problem); err != nil {
+			return err
+		}
+	}
+
+	if err := s.store.SetProblemStatus(r.Context(), problem.ProblemID, problem.Status, to); err != nil {
+		return err
+	}
+
+	problem, err = s.store.GetProblemByID(r.Context(), problem.ProblemID)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, problem)
+}
+
+// POST api/admin/problems/import
+func (s *APIServer) handleImportProblems(w http.ResponseWriter, r *http.Request) error {
+	req := new(ImportProblemsRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	results, err := s.importPackages(r.Context(), req.Packages, &currentAccount(r).UserID)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, results)
+}
+
+// GET api/problems/search?q=
+func (s *APIServer) handleSearchProblems(w http.ResponseWriter, r *http.Request) error {
+	query := new(SearchProblemsQuery)
+	if err := decodeQuery(r, query); err != nil {
+		return err
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+
+	results, err := s.store.SearchProblems(r.Context(), query.Q, limit)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, results)
+}
+
+// PUT api/problems/{id}/tags
+// Filing a problem under other tags is not an edit, it doesn't need a review or a revision
+func (s *APIServer) handleSetProblemTags(w http.ResponseWriter, r *http.Request) error {
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+
+	if account := currentAccount(r); !problem.authoredBy(account) && !account.HasRole(RoleAdmin) {
+		return errForbidden
+	}
+
+	req := new(SetProblemTagsRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	if err := s.store.SetProblemTags(r.Context(), problem.ProblemID, req.Tags); err != nil {
+		return err
+	}
+
+	problem, err = s.store.GetProblemByID(r.Context(), problem.ProblemID)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, problem)
+}
+
+// GET api/problems/{id}/solutions
+func (s *APIServer) handleGetReferenceSolutions(w http.ResponseWriter, r *http.Request) error {
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+
+	if account := currentAccount(r); !problem.authoredBy(account) && !account.HasRole(RoleAdmin) {
+		return errForbidden
+	}
+
+	solutions, err := s.store.GetReferenceSolutions(r.Context(), problem.ProblemID)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, solutions)
+}
+
+// PUT api/problems/{id}/solutions/{language_id}
+// Drafts take any solution and get the test cases it disagrees with flagged, published problems
+// only take one that agrees with all of them
+func (s *APIServer) handleSetReferenceSolution(w http.ResponseWriter, r *http.Request) error {
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+
+	account := currentAccount(r)
+	if err := problem.checkEditableBy(account); err != nil {
+		return err
+	}
+
+	req := new(SetReferenceSolutionRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	languageID, _ := strconv.Atoi(mux.Vars(r)["language_id"])
+	if _, ok := harnesses[languageID]; !ok {
+		return NotFound("language %d is not supported", languageID)
+	}
+	solution := &ReferenceSolution{ProblemID: problem.ProblemID, LanguageID: languageID, SourceCode: req.SourceCode, UpdatedBy: &account.UserID}
+
+	solutions, err := s.store.GetReferenceSolutions(r.Context(), problem.ProblemID)
+	if err != nil {
+		return err
+	}
+	solutions = slices.DeleteFunc(solutions, func(other *ReferenceSolution) bool { return other.LanguageID == languageID })
+
+	flags, err := s.verifySuite(r.Context(), problem, append(solutions, solution))
+	if err != nil {
+		return err
+	}
+	if problem.Status == ProblemPublished && len(flags) > 0 {
+		return ValidationErrors{"source_code": "disagrees with " + firstFlag(flags)}
+	}
+
+	err = s.store.WithTx(r.Context(), func(tx Storage) error {
+		if err := tx.SetReferenceSolution(r.Context(), solution); err != nil {
+			return err
+		}
+		return tx.SetTestCaseFlags(r.Context(), problem.ProblemID, flags)
+	})
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, solution)
+}
+
+// DELETE api/problems/{id}/solutions/{language_id}
+func (s *APIServer) handleDeleteReferenceSolution(w http.ResponseWriter, r *http.Request) error {
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+
+	if err := problem.checkEditableBy(currentAccount(r)); err != nil {
+		return err
+	}
+
+	languageID, _ := strconv.Atoi(mux.Vars(r)["language_id"])
+	solutions, err := s.store.GetReferenceSolutions(r.Context(), problem.ProblemID)
+	if err != nil {
+		return err
+	}
+	remaining := slices.DeleteFunc(slices.Clone(solutions), func(other *ReferenceSolution) bool { return other.LanguageID == languageID })
+	if len(remaining) == len(solutions) {
+		return NotFound("problem %d has no %s reference solution", problem.ProblemID, languageName(languageID))
+	}
+	if problem.Status == ProblemPublished && len(remaining) == 0 {
+		return Conflict("problem %d is published and needs a reference solution", problem.ProblemID)
+	}
+
+	flags, err := s.verifySuite(r.Context(), problem, remaining)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.WithTx(r.Context(), func(tx Storage) error {
+		if err := tx.DeleteReferenceSolution(r.Context(), problem.ProblemID, languageID); err != nil {
+			return err
+		}
+		return tx.SetTestCaseFlags(r.Context(), problem.ProblemID, flags)
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// GET api/tags
+func (s *APIServer) handleGetTags(w http.ResponseWriter, r *http.Request) error {
+	tags, err := s.store.GetTags(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, tags)
+}
+
+// POST api/tags
+func (s *APIServer) handleCreateTag(w http.ResponseWriter, r *http.Request) error {
+	req := new(CreateTagRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	tag := &Tag{Slug: req.Slug, Name: req.Name}
+	if err := s.store.CreateTag(r.Context(), tag); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusCreated, tag)
+}
+
 // GET api/testcases/{id} *** id here is a PROBLEM id ***
+// Only admins and the author get the hidden suite, everyone else gets the public examples and sanity checks
 func (s *APIServer) handleGetTestCasesByProblemID(w http.ResponseWriter, r *http.Request) error {
-	id, err := getID(r, "problem_id")
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+
+	query := new(ListTestCasesQuery)
+	if err := decodeQuery(r, query); err != nil {
+		return err
+	}
+	page, err := query.page("", testCaseOrder)
 	if err != nil {
 		return err
 	}
 
-	testCase, err := s.store.GetTestCasesByProblemID(id)
+	filter := TestCaseFilter{ProblemID: problem.ProblemID, Kinds: publicTestCaseKinds, PageRequest: page}
+	if account := currentAccount(r); problem.authoredBy(account) || account != nil && account.HasRole(RoleAdmin) {
+		filter.Kinds = nil
+	}
+
+	testCases, err := s.store.GetTestCases(r.Context(), filter)
 	if err != nil {
 		return err
 	}
 
-	return WriteJSON(w, http.StatusOK, testCase)
+	return WriteJSON(w, http.StatusOK, testCases)
 }
 
 func (s *APIServer) handleGetTestCaseSanityChecks(w http.ResponseWriter, r *http.Request) error {
-	id, err := getID(r, "problem_id")
+	problem, err := s.visibleProblem(r)
 	if err != nil {
 		return err
 	}
 
-	testCase, err := s.store.GetTestCaseSanityChecks(id)
+	testCase, err := s.store.GetTestCaseSanityChecks(r.Context(), problem.ProblemID)
 	if err != nil {
 		return err
 	}
@@ -157,14 +704,31 @@ func (s *APIServer) handleGetTestCaseSanityChecks(w http.ResponseWriter, r *http
 func (s *APIServer) handleCreateTestCase(w http.ResponseWriter, r *http.Request) error {
 	req := new(CreateTestCaseRequest)
 
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	if req.Kind == "" {
+		req.Kind = TestCaseHidden
+	}
+
+	problem, err := s.store.GetProblemByID(r.Context(), req.ProblemID)
+	if err != nil {
+		return err
+	}
+	if err := problem.checkVisibleTo(currentAccount(r)); err != nil {
+		return err
+	}
+	if err := problem.checkEditableBy(currentAccount(r)); err != nil {
 		return err
 	}
-	defer r.Body.Close()
 
-	testCase := NewTestCase(req.ProblemID, req.IO.Input, req.IO.Output, req.IsSanityCheck)
+	testCase := NewTestCase(req.ProblemID, req.IO.Input, req.IO.Output, req.Kind)
+	if err := s.checkNewTestCase(r.Context(), problem, testCase); err != nil {
+		return err
+	}
 
-	id, err := s.store.CreateTestCase(testCase)
+	id, err := s.store.CreateTestCase(r.Context(), testCase)
 	if err != nil {
 		return err
 	}
@@ -172,13 +736,69 @@ func (s *APIServer) handleCreateTestCase(w http.ResponseWriter, r *http.Request)
 	return WriteJSON(w, http.StatusCreated, testCase)
 }
 
+// POST api/problems/{id}/testcases:bulk?replace=
+func (s *APIServer) handleCreateTestCases(w http.ResponseWriter, r *http.Request) error {
+	problem, err := s.visibleProblem(r)
+	if err != nil {
+		return err
+	}
+	if err := problem.checkEditableBy(currentAccount(r)); err != nil {
+		return err
+	}
+
+	query := new(CreateTestCasesQuery)
+	if err := decodeQuery(r, query); err != nil {
+		return err
+	}
+	reqs, err := decodeTestCases(w, r)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.createTestCases(r.Context(), problem, reqs, query.Replace)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, result)
+}
+
 func (s *APIServer) handleCreateSubmission(w http.ResponseWriter, r *http.Request) error {
-	req := new(Submission)
+	req := new(CreateSubmissionRequest)
+
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+	problem, err := s.store.GetProblemByID(r.Context(), req.ProblemID)
+	if err != nil {
+		return err
+	}
+	if err := problem.checkVisibleTo(currentAccount(r)); err != nil {
 		return err
 	}
-	sub, err := s.store.CreateSubmission(req)
+	if problem.Status != ProblemPublished {
+		return Conflict("problem %d is %s and not taking submissions", problem.ProblemID, problem.Status)
+	}
+
+	// Judged against every test case, hidden ones included, before it's stored
+	result, err := run(r.Context(), s, currentAccount(r), &ExecReq{
+		ProblemID:  req.ProblemID,
+		LanguageID: req.Language,
+		SourceCode: req.SourceCode,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Submissions are always made as the caller
+	sub, err := s.store.CreateSubmission(r.Context(), &Submission{
+		UserID:     currentAccount(r).UserID,
+		ProblemID:  req.ProblemID,
+		SourceCode: req.SourceCode,
+		Language:   req.Language,
+		Verdict:    result.verdict(),
+	})
 	if err != nil {
 		return err
 	}
@@ -192,34 +812,62 @@ func (s *APIServer) handleGetSubmissionByID(w http.ResponseWriter, r *http.Reque
 		return err
 	}
 
-	testCase, err := s.store.GetSubmissionByID(id)
+	sub, err := s.store.GetSubmissionByID(r.Context(), id)
 	if err != nil {
 		return err
 	}
 
-	return WriteJSON(w, http.StatusOK, testCase)
+	if account := currentAccount(r); sub.UserID != account.UserID && !account.HasRole(RoleAdmin) {
+		return errForbidden
+	}
+
+	return WriteJSON(w, http.StatusOK, sub)
 }
 
 func (s *APIServer) handleGetSubmissions(w http.ResponseWriter, r *http.Request) error {
-	return nil
-}
+	query := new(ListSubmissionsQuery)
+	if err := decodeQuery(r, query); err != nil {
+		return err
+	}
+	page, err := query.page(query.Sort, submissionOrder)
+	if err != nil {
+		return err
+	}
 
-// POST api/submit
-func (s *APIServer) handleSubmitCode(w http.ResponseWriter, r *http.Request) error {
-	// req := new(ExecReq)
+	filter := SubmissionFilter{
+		UserID:      query.UserID,
+		ProblemID:   query.ProblemID,
+		Language:    query.Language,
+		Verdict:     query.Verdict,
+		From:        query.From,
+		To:          query.To,
+		PageRequest: page,
+	}
 
-	return nil
+	// Everyone but admins only ever sees their own
+	if account := currentAccount(r); !account.HasRole(RoleAdmin) {
+		if filter.UserID != 0 && filter.UserID != account.UserID {
+			return errForbidden
+		}
+		filter.UserID = account.UserID
+	}
+
+	subs, err := s.store.GetSubmissions(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, subs)
 }
 
 // POST api/run
 func (s *APIServer) handleRunCode(w http.ResponseWriter, r *http.Request) error {
-	fmt.Println("handling run code request...")
 	req := new(ExecReq)
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(w, r, req); err != nil {
 		return err
 	}
 
-	result, err := run(s, req)
+	result, err := run(r.Context(), s, currentAccount(r), req)
 	if err != nil {
 		return err
 	}
@@ -230,17 +878,14 @@ func (s *APIServer) handleRunCode(w http.ResponseWriter, r *http.Request) error
 // POST api/run/batch
 func (s *APIServer) handleRunBatchCode(w http.ResponseWriter, r *http.Request) error {
 	req := new(ExecBatchReq)
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		fmt.Println("ErroR!!")
+	if err := decodeJSON(w, r, req); err != nil {
 		return err
 	}
 
-	fmt.Println(req)
-
-	var res []*ExecResult
+	var res []*Result
 
 	for i := 0; i < len(req.Submissions); i++ {
-		result, err := run(s, &req.Submissions[i])
+		result, err := run(r.Context(), s, currentAccount(r), &req.Submissions[i])
 		if err != nil {
 			return err
 		}
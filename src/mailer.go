@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(*Email) error
+}
+
+/* Sends mail through an SMTP relay using PLAIN auth */
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr: host + ":" + port,
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *SMTPMailer) Send(e *Email) error {
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + e.To,
+		"Subject: " + e.Subject,
+		"Date: " + time.Now().UTC().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		e.Body,
+	}, "\r\n")
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{e.To}, []byte(msg))
+}
+
+/* How many of the emails a LogMailer wrote it keeps in Sent */
+const logMailerKeep = 20
+
+/*
+ * Writes emails to a file (or the log when no path is given) instead of sending them.
+ * Meant for local development; the last logMailerKeep emails are also kept in memory for tests.
+ */
+type LogMailer struct {
+	path string
+
+	mu   sync.Mutex
+	Sent []*Email
+}
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+func (m *LogMailer) Send(e *Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Sent = append(m.Sent, e)
+	if len(m.Sent) > logMailerKeep {
+		m.Sent = slices.Clone(m.Sent[len(m.Sent)-logMailerKeep:])
+	}
+	entry := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n-----\n", e.To, e.Subject, e.Body)
+
+	if m.path == "" {
+		log.Print("- Mail\n" + entry)
+		return nil
+	}
+
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(entry)
+	return err
+}
+
+/*
+ * MAILER=smtp uses the SMTP_* variables, anything else logs emails to MAIL_LOG_FILE
+ * (or stdout when it is unset).
+ */
+func NewMailerFromEnv() Mailer {
+	if os.Getenv("MAILER") == "smtp" {
+		return NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USER"),
+			os.Getenv("SMTP_PASS"),
+			os.Getenv("MAIL_FROM"),
+		)
+	}
+
+	log.Println("- MAILER isn't smtp, emails will be logged and not sent")
+	return NewLogMailer(os.Getenv("MAIL_LOG_FILE"))
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 func main() {
@@ -13,6 +17,14 @@ func main() {
 	}
 
 	fmt.Println("Booting up...")
+	port := os.Getenv("PORT")
+
+	if os.Getenv("STORE") == "memory" {
+		fmt.Println("Using the in-memory store, nothing will be persisted")
+		NewAPIServer(":"+port, NewMemoryStore()).Run()
+		return
+	}
+
 	store, err := NewPostgresStore()
 
 	if err != nil {
@@ -20,13 +32,147 @@ func main() {
 	}
 	fmt.Println("Postgres Store Created...")
 
-	if err := store.Init(); err != nil {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(store, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "import":
+			if err := runImport(NewAPIServer("", store), os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "export":
+			if err := runExport(store, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		default:
+			log.Fatalf("Unknown command %s", os.Args[1])
+		}
+	}
+
+	if err := store.CheckSchemaVersion(); err != nil {
 		log.Fatal(err)
 	}
 
 	fmt.Println("Store initialized...")
-	port := os.Getenv("PORT")
 	server := NewAPIServer(":"+port, store)
 	server.Run()
 
 }
+
+/* main migrate up | down [steps] | status */
+func runMigrate(store *PostgresStore, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: main migrate up | down [steps] | status")
+	}
+
+	switch args[0] {
+	case "up":
+		return store.MigrateUp()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("Invalid number of steps %s", args[1])
+			}
+			steps = n
+		}
+		return store.MigrateDown(steps)
+	case "status":
+		lines, err := store.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Unknown migrate command %s", args[0])
+}
+
+/* main import <package dir> ... */
+func runImport(s *APIServer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: main import <package dir> ...")
+	}
+
+	pkgs := make([]ProblemPackage, len(args))
+	for i, dir := range args {
+		pkg, err := readPackage(dir)
+		if err != nil {
+			return err
+		}
+		if err := checkRequest(pkg); err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		pkgs[i] = *pkg
+	}
+	if err := checkRequest(&ImportProblemsRequest{Packages: pkgs}); err != nil {
+		return err
+	}
+
+	results, err := s.importPackages(context.Background(), pkgs, nil)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		changes := strings.Join(result.Changes, ", ")
+		if changes == "" {
+			changes = "unchanged"
+		}
+		fmt.Printf("%s (problem %d): %s\n", result.Slug, result.ProblemID, changes)
+	}
+	return nil
+}
+
+/* main export <dir> [slug ...]. Writes each problem, or every problem, to <dir>/<slug> */
+func runExport(store Storage, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: main export <dir> [slug ...]")
+	}
+	ctx := context.Background()
+
+	var problems []*Problem
+	for _, slug := range args[1:] {
+		problem, err := store.GetProblemBySlug(ctx, slug)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, problem)
+	}
+	if len(args) == 1 {
+		filter := ProblemFilter{PageRequest: PageRequest{Limit: maxPageLimit}}
+		for {
+			page, err := store.GetProblems(ctx, filter)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, page.Items...)
+			if page.NextCursor == nil {
+				break
+			}
+			if filter.After, err = decodeCursor(*page.NextCursor); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, problem := range problems {
+		pkg, err := exportPackage(ctx, store, problem)
+		if err != nil {
+			return err
+		}
+		if err := writePackage(filepath.Join(args[0], problem.Slug), pkg); err != nil {
+			return err
+		}
+		fmt.Println("Exported", problem.Slug)
+	}
+	return nil
+}
@@ -0,0 +1,1168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+/*
+ * Storage kept in maps, for tests and for running the API locally without Postgres
+ * (STORE=memory). It follows the same rules as PostgresStore: IDs count up from 1 per table,
+ * the same not-found and conflict errors, case-insensitive usernames and emails and soft
+ * deleted accounts. The conformance suite in storage_test.go runs against both.
+ */
+type MemoryStore struct {
+	mu   sync.Mutex
+	data *memoryData
+	tx   bool // handed out by WithTx, the parent store is locked while it is in use
+}
+
+type memAccount struct {
+	Account
+	encryptedPassword string
+}
+
+type memRevision struct {
+	ProblemRevision
+	problemID int
+}
+
+type memoryData struct {
+	accounts      map[int]memAccount
+	refreshTokens map[int]RefreshToken
+	loginAttempts map[int]LoginAttempt
+	accountTokens map[int]AccountToken
+	oauthStates   map[string]OAuthState
+	identities    map[int]AccountIdentity
+	problems      map[int]Problem
+	revisions     map[int]memRevision
+	solutions     map[[2]int]ReferenceSolution // by problem and language
+	tags          map[string]Tag
+	testCases     map[int]TestCase
+	submissions   map[int]Submission
+
+	sequences map[string]int // last ID handed out, per table
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: &memoryData{
+			accounts:      map[int]memAccount{},
+			refreshTokens: map[int]RefreshToken{},
+			loginAttempts: map[int]LoginAttempt{},
+			accountTokens: map[int]AccountToken{},
+			oauthStates:   map[string]OAuthState{},
+			identities:    map[int]AccountIdentity{},
+			problems:      map[int]Problem{},
+			revisions:     map[int]memRevision{},
+			solutions:     map[[2]int]ReferenceSolution{},
+			tags:          map[string]Tag{},
+			testCases:     map[int]TestCase{},
+			submissions:   map[int]Submission{},
+			sequences:     map[string]int{},
+		},
+	}
+}
+
+func (d *memoryData) nextID(table string) int {
+	d.sequences[table]++
+	return d.sequences[table]
+}
+
+/* Rows are stored by value, so copying the maps is enough to snapshot everything */
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		accounts:      cloneMap(d.accounts),
+		refreshTokens: cloneMap(d.refreshTokens),
+		loginAttempts: cloneMap(d.loginAttempts),
+		accountTokens: cloneMap(d.accountTokens),
+		oauthStates:   cloneMap(d.oauthStates),
+		identities:    cloneMap(d.identities),
+		problems:      cloneMap(d.problems),
+		revisions:     cloneMap(d.revisions),
+		solutions:     cloneMap(d.solutions),
+		tags:          cloneMap(d.tags),
+		testCases:     cloneMap(d.testCases),
+		submissions:   cloneMap(d.submissions),
+		sequences:     cloneMap(d.sequences),
+	}
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	out := make(map[K]V, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+/* Map keys in ascending order, i.e. the order rows were inserted in */
+func sortedIDs[V any](m map[int]V) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+/*
+ * fn gets a store working on a copy of the data, which replaces the original only if fn
+ * succeeds. The store stays locked meanwhile, so fn must only use the store it is given.
+ */
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(Storage) error) error {
+	if s.tx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &MemoryStore{data: s.data.clone(), tx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.data = tx.data
+	return nil
+}
+
+// -- Accounts --
+func (s *MemoryStore) CreateAccount(ctx context.Context, acc *CreateAccountRequest) (*Account, error) {
+	// Accounts created through an OAuth provider have no password until they set one
+	var safePass []byte
+	if acc.Password != "" {
+		var err error
+		safePass, err = bcrypt.GenerateFromPassword([]byte(acc.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkAccountUnique(0, acc.Username, acc.Email); err != nil {
+		return nil, err
+	}
+
+	account := memAccount{
+		Account: Account{
+			UserID:    s.data.nextID("account"),
+			FirstName: acc.FirstName,
+			LastName:  acc.LastName,
+			Username:  acc.Username,
+			Email:     acc.Email,
+			CreatedAt: time.Now().UTC(),
+			Role:      RolePlayer,
+		},
+		encryptedPassword: string(safePass),
+	}
+	s.data.accounts[account.UserID] = account
+
+	return &account.Account, nil
+}
+
+/* Same rules as the account_username_key and account_email_key indexes */
+func (s *MemoryStore) checkAccountUnique(userID int, username, email string) error {
+	for _, a := range s.data.accounts {
+		if a.UserID == userID || a.DeletedAt != nil {
+			continue
+		}
+		if strings.EqualFold(a.Username, username) {
+			return ErrUsernameTaken
+		}
+		if strings.EqualFold(a.Email, email) {
+			return ErrEmailTaken
+		}
+	}
+	return nil
+}
+
+/* First live account matching `match`, the caller holds the lock */
+func (s *MemoryStore) findAccount(match func(*memAccount) bool) (*memAccount, bool) {
+	for _, id := range sortedIDs(s.data.accounts) {
+		a := s.data.accounts[id]
+		if a.DeletedAt == nil && match(&a) {
+			return &a, true
+		}
+	}
+	return nil, false
+}
+
+func (s *MemoryStore) GetAccountByID(ctx context.Context, id int) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.data.accounts[id]
+	if !ok || a.DeletedAt != nil {
+		return nil, NotFound("Account %d not found", id)
+	}
+	return &a.Account, nil
+}
+
+func (s *MemoryStore) GetAccountByEmail(ctx context.Context, email string) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.findAccount(func(a *memAccount) bool { return strings.EqualFold(a.Email, email) })
+	if !ok {
+		return nil, NotFound("Account with email %s not found", email)
+	}
+	return &a.Account, nil
+}
+
+func (s *MemoryStore) GetAccountByUsername(ctx context.Context, username string) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.findAccount(func(a *memAccount) bool { return strings.EqualFold(a.Username, username) })
+	if !ok {
+		return nil, NotFound("Account %s not found", username)
+	}
+	return &a.Account, nil
+}
+
+func (s *MemoryStore) GetAccounts(ctx context.Context, page PageRequest) (*Page[*Account], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := []*Account{}
+	for _, id := range sortedIDs(s.data.accounts) {
+		if a := s.data.accounts[id]; a.DeletedAt == nil {
+			accounts = append(accounts, &a.Account)
+		}
+	}
+	return accountOrder.paginate(accounts, page)
+}
+
+func (s *MemoryStore) UpdateAccount(ctx context.Context, acc *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.data.accounts[acc.UserID]
+	if !ok || a.DeletedAt != nil {
+		return NotFound("Account %d not found", acc.UserID)
+	}
+
+	if err := s.checkAccountUnique(a.UserID, acc.Username, acc.Email); err != nil {
+		return err
+	}
+
+	a.EmailVerified = a.EmailVerified && strings.EqualFold(a.Email, acc.Email)
+	a.FirstName, a.LastName, a.Username, a.Email = acc.FirstName, acc.LastName, acc.Username, acc.Email
+	s.data.accounts[a.UserID] = a
+
+	// Links emailed to the old address mustn't verify or reset the new one
+	for id, t := range s.data.accountTokens {
+		if t.UserID == a.UserID && t.UsedAt == nil && !strings.EqualFold(t.Email, a.Email) {
+			delete(s.data.accountTokens, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ChangeAccountPassword(ctx context.Context, id int, oldPassword, newPassword string) error {
+	s.mu.Lock()
+	a, ok := s.data.accounts[id]
+	s.mu.Unlock()
+
+	if !ok || a.DeletedAt != nil {
+		return NotFound("Account %d not found", id)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(a.encryptedPassword), []byte(oldPassword)); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	return s.ResetAccountPassword(ctx, id, newPassword)
+}
+
+func (s *MemoryStore) ResetAccountPassword(ctx context.Context, id int, newPassword string) error {
+	safePass, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.data.accounts[id]; ok {
+		a.encryptedPassword = string(safePass)
+		s.data.accounts[id] = a
+	}
+	s.revokeRefreshTokens(func(t *RefreshToken) bool { return t.UserID == id })
+	return nil
+}
+
+func (s *MemoryStore) UpdateAccountRole(ctx context.Context, id int, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.data.accounts[id]
+	if !ok {
+		return NotFound("Account %d not found", id)
+	}
+
+	a.Role = role
+	s.data.accounts[id] = a
+	return nil
+}
+
+func (s *MemoryStore) MarkEmailVerified(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.data.accounts[id]; ok {
+		a.EmailVerified = true
+		s.data.accounts[id] = a
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteAccount(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.data.accounts[id]
+	if !ok || a.DeletedAt != nil {
+		return NotFound("Account %d not found", id)
+	}
+
+	now := time.Now().UTC()
+	a.FirstName, a.LastName, a.Email, a.encryptedPassword = "", "", "", ""
+	a.Username = fmt.Sprintf("deleted-%d", id)
+	a.DeletedAt = &now
+	s.data.accounts[id] = a
+
+	for identityID, identity := range s.data.identities {
+		if identity.UserID == id {
+			delete(s.data.identities, identityID)
+		}
+	}
+	s.revokeRefreshTokens(func(t *RefreshToken) bool { return t.UserID == id })
+	return nil
+}
+
+func (s *MemoryStore) VerifyAccountPassword(ctx context.Context, username, password string) (*Account, error) {
+	s.mu.Lock()
+	a, ok := s.findAccount(func(a *memAccount) bool { return strings.EqualFold(a.Username, username) })
+	s.mu.Unlock()
+
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(a.encryptedPassword), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &a.Account, nil
+}
+
+// -- Refresh tokens --
+func (s *MemoryStore) CreateRefreshToken(ctx context.Context, t *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.TokenID = s.data.nextID("refresh_token")
+	stored := *t
+	stored.UserAgent = truncate(t.UserAgent, 255)
+	s.data.refreshTokens[t.TokenID] = stored
+	return nil
+}
+
+func (s *MemoryStore) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, t := range s.data.refreshTokens {
+		if t.TokenHash != tokenHash {
+			continue
+		}
+
+		if t.RevokedAt == nil && t.ExpiresAt.After(now) {
+			t.RevokedAt = &now
+			s.data.refreshTokens[id] = t
+			return &t, nil
+		}
+
+		if t.RevokedAt == nil {
+			// Expired but never used
+			return nil, ErrRefreshTokenInvalid
+		}
+
+		s.revokeRefreshTokens(func(other *RefreshToken) bool { return other.FamilyID == t.FamilyID })
+		return nil, ErrRefreshTokenReused
+	}
+
+	return nil, ErrRefreshTokenInvalid
+}
+
+func (s *MemoryStore) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.data.refreshTokens {
+		if t.TokenHash == tokenHash {
+			familyID := t.FamilyID
+			s.revokeRefreshTokens(func(other *RefreshToken) bool { return other.FamilyID == familyID })
+			break
+		}
+	}
+	return nil
+}
+
+/* Revokes every live token matching `match` and returns how many there were */
+func (s *MemoryStore) revokeRefreshTokens(match func(*RefreshToken) bool) int {
+	now := time.Now().UTC()
+	n := 0
+	for id, t := range s.data.refreshTokens {
+		if t.RevokedAt == nil && match(&t) {
+			t.RevokedAt = &now
+			s.data.refreshTokens[id] = t
+			n++
+		}
+	}
+	return n
+}
+
+func (s *MemoryStore) GetSessions(ctx context.Context, userID int) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	families := map[string]*Session{}
+	active := map[string]bool{}
+
+	// Ascending IDs, so the last token seen in a family carries its latest IP and user agent
+	for _, id := range sortedIDs(s.data.refreshTokens) {
+		t := s.data.refreshTokens[id]
+		if t.UserID != userID {
+			continue
+		}
+
+		sess, ok := families[t.FamilyID]
+		if !ok {
+			sess = &Session{SessionID: t.FamilyID, CreatedAt: t.CreatedAt, LastUsedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+			families[t.FamilyID] = sess
+		}
+
+		sess.IP, sess.UserAgent = t.IP, t.UserAgent
+		if t.CreatedAt.Before(sess.CreatedAt) {
+			sess.CreatedAt = t.CreatedAt
+		}
+		if t.CreatedAt.After(sess.LastUsedAt) {
+			sess.LastUsedAt = t.CreatedAt
+		}
+		if t.ExpiresAt.After(sess.ExpiresAt) {
+			sess.ExpiresAt = t.ExpiresAt
+		}
+		if t.RevokedAt == nil && t.ExpiresAt.After(now) {
+			active[t.FamilyID] = true
+		}
+	}
+
+	sessions := []*Session{}
+	for familyID, sess := range families {
+		if active[familyID] {
+			sessions = append(sessions, sess)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+
+	return sessions, nil
+}
+
+func (s *MemoryStore) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.revokeRefreshTokens(func(t *RefreshToken) bool { return t.UserID == userID && t.FamilyID == sessionID })
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// -- Login audit log --
+func (s *MemoryStore) RecordLoginAttempt(ctx context.Context, a *LoginAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a.AttemptID = s.data.nextID("login_attempt")
+	stored := *a
+	stored.Username = truncate(a.Username, 50)
+	stored.UserAgent = truncate(a.UserAgent, 255)
+	s.data.loginAttempts[a.AttemptID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetLoginFailureStats(ctx context.Context, username, ip string, since time.Time) (*LoginFailureStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	username = strings.ToLower(username)
+
+	// Account failures only count after the last successful login
+	accountSince := since
+	for _, a := range s.data.loginAttempts {
+		if a.Success && a.Username == username && a.AttemptedAt.After(accountSince) {
+			accountSince = a.AttemptedAt
+		}
+	}
+
+	stats := &LoginFailureStats{AccountLastFailure: since, IPLastFailure: since}
+	for _, a := range s.data.loginAttempts {
+		if a.Success || !a.AttemptedAt.After(since) {
+			continue
+		}
+
+		if a.Username == username {
+			if a.AttemptedAt.After(accountSince) {
+				stats.AccountFailures++
+			}
+			if a.AttemptedAt.After(stats.AccountLastFailure) {
+				stats.AccountLastFailure = a.AttemptedAt
+			}
+		}
+		if a.IP == ip {
+			stats.IPFailures++
+			if a.AttemptedAt.After(stats.IPLastFailure) {
+				stats.IPLastFailure = a.AttemptedAt
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// -- Single-use emailed tokens --
+func (s *MemoryStore) CreateAccountToken(ctx context.Context, t *AccountToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.TokenID = s.data.nextID("account_token")
+	s.data.accountTokens[t.TokenID] = *t
+	return nil
+}
+
+func (s *MemoryStore) ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (*AccountToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, t := range s.data.accountTokens {
+		a, ok := s.data.accounts[t.UserID]
+		if !ok || a.DeletedAt != nil || !strings.EqualFold(a.Email, t.Email) {
+			continue
+		}
+		if t.TokenHash == tokenHash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			s.data.accountTokens[id] = t
+			return &t, nil
+		}
+	}
+
+	return nil, ErrInvalidToken
+}
+
+// -- OAuth --
+func (s *MemoryStore) CreateOAuthState(ctx context.Context, st *OAuthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.oauthStates[st.State]; ok {
+		return fmt.Errorf("OAuth state %s already exists", st.State)
+	}
+	s.data.oauthStates[st.State] = *st
+	return nil
+}
+
+func (s *MemoryStore) ConsumeOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.data.oauthStates[state]
+	if !ok || !st.ExpiresAt.After(time.Now().UTC()) {
+		return nil, ErrInvalidToken
+	}
+
+	delete(s.data.oauthStates, state)
+	return &st, nil
+}
+
+func (s *MemoryStore) CreateAccountIdentity(ctx context.Context, id *AccountIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.data.identities {
+		if other.Provider == id.Provider && (other.Subject == id.Subject || other.UserID == id.UserID) {
+			return ErrIdentityLinked
+		}
+	}
+
+	id.IdentityID = s.data.nextID("account_identity")
+	s.data.identities[id.IdentityID] = *id
+	return nil
+}
+
+func (s *MemoryStore) GetAccountByIdentity(ctx context.Context, provider, subject string) (*Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, identity := range s.data.identities {
+		if identity.Provider != provider || identity.Subject != subject {
+			continue
+		}
+		if a, ok := s.data.accounts[identity.UserID]; ok && a.DeletedAt == nil {
+			return &a.Account, nil
+		}
+	}
+
+	return nil, ErrIdentityNotFound
+}
+
+func (s *MemoryStore) GetAccountIdentities(ctx context.Context, userID int) ([]*AccountIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identities := []*AccountIdentity{}
+	for _, id := range sortedIDs(s.data.identities) {
+		if identity := s.data.identities[id]; identity.UserID == userID {
+			identities = append(identities, &identity)
+		}
+	}
+	return identities, nil
+}
+
+func (s *MemoryStore) DeleteAccountIdentity(ctx context.Context, userID int, provider string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, count := 0, 0
+	for id, identity := range s.data.identities {
+		if identity.UserID != userID {
+			continue
+		}
+		count++
+		if identity.Provider == provider {
+			target = id
+		}
+	}
+
+	if target == 0 {
+		return ErrIdentityNotFound
+	}
+	if s.data.accounts[userID].encryptedPassword == "" && count == 1 {
+		return ErrLastLoginMethod
+	}
+
+	delete(s.data.identities, target)
+	return nil
+}
+
+// -- Problems --
+func (s *MemoryStore) CreateProblem(ctx context.Context, prob *Problem) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.data.problems {
+		if p.Slug == prob.Slug {
+			return -1, ErrSlugTaken
+		}
+	}
+	tags, err := s.knownTags(prob.Tags)
+	if err != nil {
+		return -1, err
+	}
+
+	stored := *prob
+	stored.ProblemID = s.data.nextID("problem")
+	stored.Tags = tags
+	stored.Revision = 1
+	stored.PublishedAt = nil
+	if stored.Status == "" {
+		stored.Status = ProblemDraft
+	}
+	s.data.problems[stored.ProblemID] = stored
+	s.addProblemRevision(stored, prob.AuthorID)
+	return stored.ProblemID, nil
+}
+
+func (s *MemoryStore) GetProblemByID(ctx context.Context, id int) (*Problem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.data.problems[id]
+	if !ok {
+		return nil, NotFound("problem %d not found", id)
+	}
+	return &p, nil
+}
+
+func (s *MemoryStore) GetProblemByName(ctx context.Context, name string) (*Problem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range sortedIDs(s.data.problems) {
+		if p := s.data.problems[id]; p.ProblemName == name {
+			return &p, nil
+		}
+	}
+	return nil, NotFound("Problem %s not found", name)
+}
+
+func (s *MemoryStore) GetProblemBySlug(ctx context.Context, slug string) (*Problem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.data.problems {
+		if p.Slug == slug {
+			return &p, nil
+		}
+	}
+	return nil, NotFound("problem %s not found", slug)
+}
+
+func (s *MemoryStore) GetProblems(ctx context.Context, filter ProblemFilter) (*Page[*Problem], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	problems := []*Problem{}
+	for _, id := range sortedIDs(s.data.problems) {
+		p := s.data.problems[id]
+		switch {
+		case filter.Difficulty != 0 && int(p.Difficulty) != filter.Difficulty:
+		case filter.Tag != "" && !slices.Contains(p.Tags, filter.Tag):
+		case filter.SolvedBy != 0 && !s.solved(filter.SolvedBy, p.ProblemID):
+		case filter.UnsolvedBy != 0 && s.solved(filter.UnsolvedBy, p.ProblemID):
+		case filter.Statuses != nil && !slices.Contains(filter.Statuses, p.Status):
+		case filter.AuthorID != 0 && (p.AuthorID == nil || *p.AuthorID != filter.AuthorID):
+		default:
+			problems = append(problems, &p)
+		}
+	}
+	return problemOrder.paginate(problems, filter.PageRequest)
+}
+
+/* Whether the account has an accepted submission for the problem */
+func (s *MemoryStore) solved(userID, problemID int) bool {
+	for _, sub := range s.data.submissions {
+		if sub.UserID == userID && sub.ProblemID == problemID && sub.Verdict == VerdictAccepted {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * A rough stand-in for Postgres full-text search: every word of the query has to appear in the
+ * name or the prompt, and words in the name count more. There is no stemming, so "sums" does
+ * not find "sum".
+ */
+func (s *MemoryStore) SearchProblems(ctx context.Context, query string, limit int) ([]*ProblemSearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	terms := searchWords(query)
+	results := []*ProblemSearchResult{}
+	for _, id := range sortedIDs(s.data.problems) {
+		p := s.data.problems[id]
+		if p.Status != ProblemPublished {
+			continue
+		}
+		name, prompt := searchWords(p.ProblemName), searchWords(p.Prompt)
+
+		result := &ProblemSearchResult{Problem: p}
+		for _, term := range terms {
+			inName, inPrompt := slices.Contains(name, term), slices.Contains(prompt, term)
+			if !inName && !inPrompt {
+				result = nil
+				break
+			}
+			if inName {
+				result.Rank += 1
+			}
+			if inPrompt {
+				result.Rank += 0.4
+			}
+		}
+		if result != nil && len(terms) > 0 {
+			results = append(results, result)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (s *MemoryStore) UpdateProblem(ctx context.Context, prob *Problem, editedBy *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.data.problems[prob.ProblemID]
+	if !ok {
+		return NotFound("problem %d not found", prob.ProblemID)
+	}
+	if stored.Revision != prob.Revision {
+		return ErrStaleRevision
+	}
+	for _, p := range s.data.problems {
+		if p.Slug == prob.Slug && p.ProblemID != prob.ProblemID {
+			return ErrSlugTaken
+		}
+	}
+	tags, err := s.knownTags(prob.Tags)
+	if err != nil {
+		return err
+	}
+
+	stored.ProblemName = prob.ProblemName
+	stored.Prompt = prob.Prompt
+	stored.StarterCode = prob.StarterCode
+	stored.Difficulty = prob.Difficulty
+	stored.FunctionName = prob.FunctionName
+	stored.RevealHiddenOnFailure = prob.RevealHiddenOnFailure
+	stored.Slug = prob.Slug
+	stored.Tags = tags
+	stored.Revision++
+	s.data.problems[stored.ProblemID] = stored
+	s.addProblemRevision(stored, editedBy)
+
+	prob.Revision = stored.Revision
+	return nil
+}
+
+func (s *MemoryStore) addProblemRevision(p Problem, editedBy *int) {
+	id := s.data.nextID("problem_revision")
+	s.data.revisions[id] = memRevision{
+		ProblemRevision: ProblemRevision{Revision: p.Revision, EditedBy: editedBy, CreatedAt: time.Now().UTC(), Problem: p},
+		problemID:       p.ProblemID,
+	}
+}
+
+func (s *MemoryStore) SetProblemStatus(ctx context.Context, problemID int, from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.data.problems[problemID]
+	if !ok {
+		return NotFound("problem %d not found", problemID)
+	}
+	if p.Status != from {
+		return Conflict("problem %d is %s, not %s", problemID, p.Status, from)
+	}
+
+	p.Status = to
+	if to == ProblemPublished && p.PublishedAt == nil {
+		now := time.Now().UTC()
+		p.PublishedAt = &now
+	}
+	s.data.problems[problemID] = p
+	return nil
+}
+
+func (s *MemoryStore) GetProblemRevisions(ctx context.Context, problemID int) ([]*ProblemRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions := []*ProblemRevision{}
+	for _, id := range sortedIDs(s.data.revisions) {
+		if rev := s.data.revisions[id]; rev.problemID == problemID {
+			revisions = append(revisions, &rev.ProblemRevision)
+		}
+	}
+	slices.Reverse(revisions)
+	return revisions, nil
+}
+
+func (s *MemoryStore) SetProblemTags(ctx context.Context, problemID int, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.data.problems[problemID]
+	if !ok {
+		return NotFound("problem %d not found", problemID)
+	}
+
+	known, err := s.knownTags(tags)
+	if err != nil {
+		return err
+	}
+	p.Tags = known
+	s.data.problems[problemID] = p
+	return nil
+}
+
+/* A sorted copy of `tags` without duplicates, or an error for the first one that doesn't exist */
+func (s *MemoryStore) knownTags(tags []string) ([]string, error) {
+	known := []string{}
+	for _, tag := range tags {
+		if _, ok := s.data.tags[tag]; !ok {
+			return nil, unknownTag(tag)
+		}
+		known = append(known, tag)
+	}
+	slices.Sort(known)
+	return slices.Compact(known), nil
+}
+
+// -- Reference solutions --
+func (s *MemoryStore) SetReferenceSolution(ctx context.Context, solution *ReferenceSolution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.problems[solution.ProblemID]; !ok {
+		return NotFound("problem %d not found", solution.ProblemID)
+	}
+
+	solution.UpdatedAt = time.Now().UTC()
+	s.data.solutions[[2]int{solution.ProblemID, solution.LanguageID}] = *solution
+	return nil
+}
+
+func (s *MemoryStore) GetReferenceSolutions(ctx context.Context, problemID int) ([]*ReferenceSolution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	solutions := []*ReferenceSolution{}
+	for _, solution := range s.data.solutions {
+		if solution.ProblemID == problemID {
+			solution := solution
+			solutions = append(solutions, &solution)
+		}
+	}
+	sort.Slice(solutions, func(i, j int) bool { return solutions[i].LanguageID < solutions[j].LanguageID })
+	return solutions, nil
+}
+
+func (s *MemoryStore) DeleteReferenceSolution(ctx context.Context, problemID, languageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]int{problemID, languageID}
+	if _, ok := s.data.solutions[key]; !ok {
+		return NotFound("problem %d has no %s reference solution", problemID, languageName(languageID))
+	}
+	delete(s.data.solutions, key)
+	return nil
+}
+
+// -- Tags --
+func (s *MemoryStore) CreateTag(ctx context.Context, tag *Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.tags[tag.Slug]; ok {
+		return ErrTagTaken
+	}
+	s.data.tags[tag.Slug] = *tag
+	return nil
+}
+
+func (s *MemoryStore) GetTags(ctx context.Context) ([]*Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tags := []*Tag{}
+	for _, tag := range s.data.tags {
+		tag := tag
+		tags = append(tags, &tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Slug < tags[j].Slug })
+	return tags, nil
+}
+
+// -- Test cases --
+func (s *MemoryStore) CreateTestCase(ctx context.Context, testcase *TestCase) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.problems[testcase.ProblemID]; !ok {
+		return -1, NotFound("problem %d not found", testcase.ProblemID)
+	}
+
+	stored := *testcase
+	stored.TestCaseID = s.data.nextID("test_case")
+	stored.Flag = ""
+	s.data.testCases[stored.TestCaseID] = stored
+	return stored.TestCaseID, nil
+}
+
+func (s *MemoryStore) CreateTestCases(ctx context.Context, testcases []*TestCase) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tc := range testcases {
+		if _, ok := s.data.problems[tc.ProblemID]; !ok {
+			return NotFound("problem %d not found", tc.ProblemID)
+		}
+	}
+	for _, tc := range testcases {
+		stored := *tc
+		stored.TestCaseID = s.data.nextID("test_case")
+		stored.Flag = ""
+		s.data.testCases[stored.TestCaseID] = stored
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetTestCasesByProblemID(ctx context.Context, id int) ([]*TestCase, error) {
+	return s.filterTestCases(func(tc *TestCase) bool { return tc.ProblemID == id }), nil
+}
+
+func (s *MemoryStore) GetTestCaseSanityChecks(ctx context.Context, id int) ([]*TestCase, error) {
+	return s.GetTestCasesByKind(ctx, id, TestCaseSanity)
+}
+
+func (s *MemoryStore) GetTestCasesByKind(ctx context.Context, id int, kinds ...string) ([]*TestCase, error) {
+	return s.filterTestCases(func(tc *TestCase) bool {
+		if tc.ProblemID != id {
+			return false
+		}
+		for _, kind := range kinds {
+			if tc.Kind == kind {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (s *MemoryStore) GetTestCases(ctx context.Context, filter TestCaseFilter) (*Page[*TestCase], error) {
+	testCases := s.filterTestCases(func(tc *TestCase) bool {
+		if filter.ProblemID != 0 && tc.ProblemID != filter.ProblemID {
+			return false
+		}
+		if filter.Kinds == nil {
+			return true
+		}
+		for _, kind := range filter.Kinds {
+			if tc.Kind == kind {
+				return true
+			}
+		}
+		return false
+	})
+	return testCaseOrder.paginate(testCases, filter.PageRequest)
+}
+
+func (s *MemoryStore) filterTestCases(match func(*TestCase) bool) []*TestCase {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	testCases := []*TestCase{}
+	for _, id := range sortedIDs(s.data.testCases) {
+		if tc := s.data.testCases[id]; match(&tc) {
+			testCases = append(testCases, &tc)
+		}
+	}
+	return testCases
+}
+
+func (s *MemoryStore) UpdateTestCase(context.Context, *TestCase) error {
+	return nil
+}
+
+func (s *MemoryStore) DeleteTestCases(ctx context.Context, problemID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, tc := range s.data.testCases {
+		if tc.ProblemID == problemID {
+			delete(s.data.testCases, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetTestCaseFlags(ctx context.Context, problemID int, flags map[int]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, tc := range s.data.testCases {
+		if tc.ProblemID == problemID {
+			tc.Flag = flags[id]
+			s.data.testCases[id] = tc
+		}
+	}
+	return nil
+}
+
+// -- Submissions --
+func (s *MemoryStore) CreateSubmission(ctx context.Context, sub *Submission) (*Submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.accounts[sub.UserID]; !ok {
+		return nil, NotFound("Account %d not found", sub.UserID)
+	}
+	if _, ok := s.data.problems[sub.ProblemID]; !ok {
+		return nil, NotFound("problem %d not found", sub.ProblemID)
+	}
+
+	stored := *sub
+	stored.SubmissionID = s.data.nextID("submission")
+	stored.SubmittedAt = time.Now().UTC()
+	if stored.Verdict == "" {
+		stored.Verdict = VerdictPending
+	}
+	s.data.submissions[stored.SubmissionID] = stored
+	return &stored, nil
+}
+
+func (s *MemoryStore) GetSubmissionByID(ctx context.Context, id int) (*Submission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.data.submissions[id]
+	if !ok {
+		return nil, NotFound("Submission %d not found", id)
+	}
+	return &sub, nil
+}
+
+func (s *MemoryStore) GetSubmissions(ctx context.Context, filter SubmissionFilter) (*Page[*Submission], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := []*Submission{}
+	for _, id := range sortedIDs(s.data.submissions) {
+		sub := s.data.submissions[id]
+		switch {
+		case filter.UserID != 0 && sub.UserID != filter.UserID:
+		case filter.ProblemID != 0 && sub.ProblemID != filter.ProblemID:
+		case filter.Language != 0 && sub.Language != filter.Language:
+		case filter.Verdict != "" && sub.Verdict != filter.Verdict:
+		case !filter.From.IsZero() && sub.SubmittedAt.Before(filter.From):
+		case !filter.To.IsZero() && !sub.SubmittedAt.Before(filter.To):
+		default:
+			subs = append(subs, &sub)
+		}
+	}
+	return submissionOrder.paginate(subs, filter.PageRequest)
+}
+
+func (s *MemoryStore) UpdateSubmission(context.Context, *Submission) error {
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
+)
 
 func commonMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -9,3 +11,68 @@ func commonMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+/*
+ * An accessRule decides whether `account` may perform the request. `account` is nil for
+ * anonymous callers. Rules return errUnauthenticated when a login would help and
+ * errForbidden otherwise.
+ */
+type accessRule func(r *http.Request, account *Account) error
+
+var (
+	errUnauthenticated = Unauthorized("authentication required")
+	errForbidden       = Forbidden("you do not have permission to perform this action")
+)
+
+func authenticated(r *http.Request, account *Account) error {
+	if account == nil {
+		return errUnauthenticated
+	}
+	return nil
+}
+
+func hasRole(role string) accessRule {
+	return func(r *http.Request, account *Account) error {
+		if account == nil {
+			return errUnauthenticated
+		}
+		if !account.HasRole(role) {
+			return errForbidden
+		}
+		return nil
+	}
+}
+
+/* Allows only the account whose id is in the route's {id} variable */
+func owner(r *http.Request, account *Account) error {
+	if account == nil {
+		return errUnauthenticated
+	}
+
+	id, err := getID(r, "user_id")
+	if err != nil || id != account.UserID {
+		return errForbidden
+	}
+	return nil
+}
+
+/* Allows the account whose id is in the route's {id} variable, and admins */
+func ownerOrAdmin(r *http.Request, account *Account) error {
+	if account == nil {
+		return errUnauthenticated
+	}
+	if account.HasRole(RoleAdmin) {
+		return nil
+	}
+
+	return owner(r, account)
+}
+
+func (s *APIServer) authorize(f apiFunc, rule accessRule) apiFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := rule(r, currentAccount(r)); err != nil {
+			return err
+		}
+		return f(w, r)
+	}
+}
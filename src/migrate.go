@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+/* Arbitrary key for pg_advisory_lock so two processes never migrate at once */
+const migrationLockKey = 7_301_994
+
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+/*
+ * Reads the embedded migrations. Files are named <version>_<name>.up.sql and
+ * <version>_<name>.down.sql and every version needs both.
+ */
+func loadMigrations() ([]*migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+	for _, e := range entries {
+		name := e.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		versionStr, label, found := strings.Cut(base, "_")
+		if !ok || !found || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("Invalid migration file name %s", name)
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid migration version in %s", name)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("Migration %d has two names: %s and %s", version, m.Name, label)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]*migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("Migration %d_%s is missing its up or down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("Migrations must be numbered 1..n without gaps, found %d at position %d", m.Version, i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+/* The version the code expects, i.e. the newest embedded migration */
+func latestSchemaVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	return len(migrations), nil
+}
+
+func (s *PostgresStore) createMigrationsTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`
+
+	_, err := s.db.Exec(query)
+	return err
+}
+
+/* Highest applied version, 0 for an empty database */
+func (s *PostgresStore) SchemaVersion() (int, error) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+/* Refuses to serve against a database that is behind or ahead of the code */
+func (s *PostgresStore) CheckSchemaVersion() error {
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	expected, err := latestSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case current < expected:
+		return fmt.Errorf("database schema is at version %d but this build needs %d, run `main migrate up`", current, expected)
+	case current > expected:
+		return fmt.Errorf("database schema is at version %d which is newer than this build (%d), deploy a newer build or migrate down", current, expected)
+	}
+
+	return nil
+}
+
+/* Holds the advisory lock around `f`. The lock is per session so it needs its own connection */
+func (s *PostgresStore) withMigrationLock(f func() error) error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	if err := s.createMigrationsTable(); err != nil {
+		return err
+	}
+
+	return f()
+}
+
+/* Runs `query` and records (or removes) the version in the same transaction */
+func (s *PostgresStore) applyMigration(query string, bookkeeping string, args ...interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/* Applies every pending migration in order */
+func (s *PostgresStore) MigrateUp() error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(func() error {
+		current, err := s.SchemaVersion()
+		if err != nil {
+			return err
+		}
+
+		if current > len(migrations) {
+			return fmt.Errorf("database schema is at version %d but this build only knows %d migrations", current, len(migrations))
+		}
+
+		for _, m := range migrations[current:] {
+			log.Printf("- Applying migration %d_%s", m.Version, m.Name)
+			err := s.applyMigration(m.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+/* Rolls back the newest `steps` migrations */
+func (s *PostgresStore) MigrateDown(steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(func() error {
+		current, err := s.SchemaVersion()
+		if err != nil {
+			return err
+		}
+
+		if current > len(migrations) {
+			return fmt.Errorf("database schema is at version %d but this build only knows %d migrations", current, len(migrations))
+		}
+
+		for i := 0; i < steps && current > 0; i++ {
+			m := migrations[current-1]
+			log.Printf("- Reverting migration %d_%s", m.Version, m.Name)
+			if err := s.applyMigration(m.Down, `DELETE FROM schema_migrations WHERE version=$1`, m.Version); err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			current--
+		}
+
+		return nil
+	})
+}
+
+/* Applied and pending migrations, for `main migrate status` */
+func (s *PostgresStore) MigrationStatus() ([]string, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(migrations))
+	for _, m := range migrations {
+		state := "pending"
+		if m.Version <= current {
+			state = "applied"
+		}
+		lines = append(lines, fmt.Sprintf("%04d_%s\t%s", m.Version, m.Name, state))
+	}
+
+	return lines, nil
+}
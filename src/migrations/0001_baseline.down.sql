@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS LoginAttempt;
+DROP TABLE IF EXISTS account_identity;
+DROP TABLE IF EXISTS OAuthState;
+DROP TABLE IF EXISTS AccountToken;
+DROP TABLE IF EXISTS RefreshToken;
+DROP TABLE IF EXISTS Submission;
+DROP TABLE IF EXISTS TestCase;
+DROP TABLE IF EXISTS Problem;
+DROP TABLE IF EXISTS Account;
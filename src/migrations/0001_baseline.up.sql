@@ -0,0 +1,104 @@
+-- Schema as it existed before versioned migrations. Everything is IF NOT EXISTS so
+-- databases created by the old PostgresStore.Init can be adopted as version 1.
+
+CREATE TABLE IF NOT EXISTS Account (
+	user_id SERIAL PRIMARY KEY,
+	first_name VARCHAR(50),
+	last_name VARCHAR(50),
+	username VARCHAR(50),
+	email VARCHAR(254),
+	encrypted_password VARCHAR(100),
+	created_at TIMESTAMP
+);
+ALTER TABLE Account ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'player';
+ALTER TABLE Account ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE Account ALTER COLUMN email TYPE VARCHAR(254);
+ALTER TABLE Account ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE UNIQUE INDEX IF NOT EXISTS account_username_key ON Account (LOWER(username)) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS account_email_key ON Account (LOWER(email)) WHERE deleted_at IS NULL;
+
+-- problem_name and function_name were added by hand on deployed databases, see 0002
+CREATE TABLE IF NOT EXISTS Problem (
+	problem_id SERIAL PRIMARY KEY,
+	prompt VARCHAR(255),
+	starter_code TEXT,
+	difficulty SMALLINT,
+	problem_name TEXT,
+	function_name TEXT
+);
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS reveal_hidden_on_failure BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Deployed databases store the test data as one io document (data/loader.py), see 0002
+CREATE TABLE IF NOT EXISTS TestCase (
+	test_case_id SERIAL PRIMARY KEY,
+	problem_id INT REFERENCES Problem(problem_id),
+	is_sanity_check BOOLEAN,
+	io JSONB
+);
+ALTER TABLE TestCase ADD COLUMN IF NOT EXISTS kind VARCHAR(10) NOT NULL DEFAULT 'hidden';
+UPDATE TestCase SET kind='sanity' WHERE is_sanity_check AND kind='hidden';
+
+CREATE TABLE IF NOT EXISTS Submission (
+	submission_id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES Account(user_id),
+	problem_id INT REFERENCES Problem(problem_id),
+	submitted_at TIMESTAMP DEFAULT NOW(),
+	source_code TEXT,
+	language INT,
+	runtime_ms INT,
+	mem_usage_kb INT
+);
+
+CREATE TABLE IF NOT EXISTS RefreshToken (
+	token_id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES Account(user_id),
+	family_id VARCHAR(32) NOT NULL,
+	token_hash CHAR(64) UNIQUE NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP
+);
+ALTER TABLE RefreshToken ADD COLUMN IF NOT EXISTS ip VARCHAR(45) NOT NULL DEFAULT '';
+ALTER TABLE RefreshToken ADD COLUMN IF NOT EXISTS user_agent VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS AccountToken (
+	token_id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES Account(user_id),
+	purpose VARCHAR(20) NOT NULL,
+	token_hash CHAR(64) UNIQUE NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS OAuthState (
+	state VARCHAR(64) PRIMARY KEY,
+	provider VARCHAR(20) NOT NULL,
+	code_verifier VARCHAR(128) NOT NULL,
+	user_id INT REFERENCES Account(user_id),
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS account_identity (
+	identity_id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES Account(user_id),
+	provider VARCHAR(20) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	email VARCHAR(254),
+	created_at TIMESTAMP NOT NULL,
+	CONSTRAINT account_identity_subject_key UNIQUE (provider, subject),
+	CONSTRAINT account_identity_provider_key UNIQUE (user_id, provider)
+);
+
+CREATE TABLE IF NOT EXISTS LoginAttempt (
+	attempt_id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES Account(user_id),
+	username VARCHAR(50) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	user_agent VARCHAR(255) NOT NULL,
+	success BOOLEAN NOT NULL,
+	attempted_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS login_attempt_username_idx ON LoginAttempt (username, attempted_at);
+CREATE INDEX IF NOT EXISTS login_attempt_ip_idx ON LoginAttempt (ip, attempted_at);
@@ -0,0 +1,3 @@
+-- Irreversible: the reconciled columns are kept. Rolling back the prompt type would
+-- truncate prompts longer than 255 characters and the old input/output columns were
+-- never read by the code.
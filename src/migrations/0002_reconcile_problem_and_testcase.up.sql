@@ -0,0 +1,25 @@
+-- Databases created by the old PostgresStore.Init have a Problem table without
+-- problem_name/function_name and a TestCase table with input/output/problem_name
+-- columns instead of the io document the code reads. Bring them in line.
+
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS problem_name TEXT;
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS function_name TEXT;
+ALTER TABLE Problem ALTER COLUMN prompt TYPE TEXT;
+
+ALTER TABLE TestCase ADD COLUMN IF NOT EXISTS io JSONB;
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_name = 'testcase' AND column_name = 'input'
+	) THEN
+		UPDATE TestCase
+		SET io = jsonb_build_object('input', input::jsonb, 'output', output::jsonb)
+		WHERE io IS NULL AND input IS NOT NULL;
+	END IF;
+END $$;
+
+ALTER TABLE TestCase DROP COLUMN IF EXISTS problem_name;
+ALTER TABLE TestCase DROP COLUMN IF EXISTS input;
+ALTER TABLE TestCase DROP COLUMN IF EXISTS output;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS submission_submitted_at_idx;
+DROP INDEX IF EXISTS submission_user_idx;
+
+ALTER TABLE Submission DROP COLUMN IF EXISTS verdict;
@@ -0,0 +1,7 @@
+-- Submissions record how they were judged, so problems can be filtered by whether the
+-- caller has solved them. Existing rows were never judged.
+
+ALTER TABLE Submission ADD COLUMN IF NOT EXISTS verdict VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+CREATE INDEX IF NOT EXISTS submission_user_idx ON Submission (user_id, problem_id, verdict);
+CREATE INDEX IF NOT EXISTS submission_submitted_at_idx ON Submission (submitted_at, submission_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS problem_search_idx;
+ALTER TABLE Problem DROP COLUMN IF EXISTS search;
+ALTER TABLE Problem DROP CONSTRAINT IF EXISTS problem_slug_key;
+ALTER TABLE Problem DROP COLUMN IF EXISTS slug;
+
+DROP TABLE IF EXISTS problem_tag;
+DROP TABLE IF EXISTS Tag;
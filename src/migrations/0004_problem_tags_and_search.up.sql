@@ -0,0 +1,63 @@
+-- Tags, URL slugs and full-text search for problems.
+
+CREATE TABLE IF NOT EXISTS Tag (
+	tag_id SERIAL PRIMARY KEY,
+	slug VARCHAR(50) NOT NULL,
+	name VARCHAR(50) NOT NULL,
+	CONSTRAINT tag_slug_key UNIQUE (slug)
+);
+
+CREATE TABLE IF NOT EXISTS problem_tag (
+	problem_id INT NOT NULL REFERENCES Problem(problem_id) ON DELETE CASCADE,
+	tag_id INT NOT NULL REFERENCES Tag(tag_id) ON DELETE CASCADE,
+	PRIMARY KEY (problem_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS problem_tag_tag_idx ON problem_tag (tag_id);
+
+INSERT INTO Tag (slug, name) VALUES
+	('arrays', 'Arrays'),
+	('strings', 'Strings'),
+	('hash-table', 'Hash Table'),
+	('linked-list', 'Linked List'),
+	('stack', 'Stack'),
+	('queue', 'Queue'),
+	('trees', 'Trees'),
+	('graphs', 'Graphs'),
+	('dynamic-programming', 'Dynamic Programming'),
+	('greedy', 'Greedy'),
+	('sorting', 'Sorting'),
+	('binary-search', 'Binary Search'),
+	('two-pointers', 'Two Pointers'),
+	('recursion', 'Recursion'),
+	('math', 'Math'),
+	('bit-manipulation', 'Bit Manipulation')
+ON CONFLICT (slug) DO NOTHING;
+
+-- Slugs for existing problems, made from their names the way slugify does. Names that
+-- collide or have no letters or digits fall back to including the id, which is kept whole
+-- by cutting the name short instead.
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS slug VARCHAR(100);
+
+UPDATE Problem
+SET slug = LEFT(TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(COALESCE(problem_name, '')), '[^a-z0-9]+', '-', 'g')), 100)
+WHERE slug IS NULL;
+
+UPDATE Problem p
+SET slug = CONCAT_WS('-',
+	NULLIF(RTRIM(LEFT(p.slug, 100 - LENGTH('-problem-' || p.problem_id)), '-'), ''),
+	'problem-' || p.problem_id
+)
+WHERE p.slug = '' OR EXISTS (
+	SELECT 1 FROM Problem other
+	WHERE other.slug = p.slug AND other.problem_id < p.problem_id
+);
+
+ALTER TABLE Problem ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE Problem ADD CONSTRAINT problem_slug_key UNIQUE (slug);
+
+-- Names weigh more than prompts when ranking search results
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', COALESCE(problem_name, '')), 'A') ||
+	setweight(to_tsvector('english', COALESCE(prompt, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS problem_search_idx ON Problem USING GIN (search);
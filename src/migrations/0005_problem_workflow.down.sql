@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS problem_revision;
+
+ALTER TABLE Problem DROP COLUMN IF EXISTS published_at;
+ALTER TABLE Problem DROP COLUMN IF EXISTS revision;
+DROP INDEX IF EXISTS problem_author_idx;
+ALTER TABLE Problem DROP COLUMN IF EXISTS author_id;
+DROP INDEX IF EXISTS problem_status_idx;
+ALTER TABLE Problem DROP CONSTRAINT IF EXISTS problem_status_check;
+ALTER TABLE Problem DROP COLUMN IF EXISTS status;
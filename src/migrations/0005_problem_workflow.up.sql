@@ -0,0 +1,43 @@
+-- Problem states, authors and revision history.
+
+-- Problems from before drafts went live the moment they were created
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published';
+ALTER TABLE Problem ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE Problem ADD CONSTRAINT problem_status_check CHECK (status IN ('draft', 'in_review', 'published', 'retired'));
+CREATE INDEX IF NOT EXISTS problem_status_idx ON Problem (status);
+
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS author_id INT REFERENCES Account(user_id);
+CREATE INDEX IF NOT EXISTS problem_author_idx ON Problem (author_id);
+
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1;
+ALTER TABLE Problem ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+UPDATE Problem SET published_at = NOW() WHERE status = 'published' AND published_at IS NULL;
+
+-- Each revision keeps the whole problem, as the API returned it, after the edit
+CREATE TABLE IF NOT EXISTS problem_revision (
+	problem_id INT NOT NULL REFERENCES Problem(problem_id) ON DELETE CASCADE,
+	revision INT NOT NULL,
+	edited_by INT REFERENCES Account(user_id),
+	created_at TIMESTAMP NOT NULL,
+	problem JSONB NOT NULL,
+	PRIMARY KEY (problem_id, revision)
+);
+
+INSERT INTO problem_revision (problem_id, revision, created_at, problem)
+SELECT p.problem_id, p.revision, NOW(), jsonb_build_object(
+	'problem_id', p.problem_id,
+	'problem_name', COALESCE(p.problem_name, ''),
+	'prompt', COALESCE(p.prompt, ''),
+	'starter_code', COALESCE(p.starter_code, ''),
+	'difficulty', COALESCE(p.difficulty, 0),
+	'function_name', COALESCE(p.function_name, ''),
+	'reveal_hidden_on_failure', p.reveal_hidden_on_failure,
+	'slug', p.slug,
+	'tags', to_jsonb(ARRAY(SELECT t.slug FROM problem_tag pt JOIN Tag t ON t.tag_id = pt.tag_id WHERE pt.problem_id = p.problem_id ORDER BY t.slug)),
+	'status', p.status,
+	'author_id', p.author_id,
+	'revision', p.revision,
+	'published_at', p.published_at
+)
+FROM Problem p
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+ALTER TABLE TestCase DROP COLUMN IF EXISTS flag;
+
+DROP TABLE IF EXISTS reference_solution;
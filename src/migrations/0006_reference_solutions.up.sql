@@ -0,0 +1,12 @@
+-- Known good programs per problem and language, and why they disagree with a test case.
+
+CREATE TABLE IF NOT EXISTS reference_solution (
+	problem_id INT NOT NULL REFERENCES Problem(problem_id) ON DELETE CASCADE,
+	language_id INT NOT NULL,
+	source_code TEXT NOT NULL,
+	updated_by INT REFERENCES Account(user_id),
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (problem_id, language_id)
+);
+
+ALTER TABLE TestCase ADD COLUMN IF NOT EXISTS flag TEXT NOT NULL DEFAULT '';
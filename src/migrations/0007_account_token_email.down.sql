@@ -0,0 +1 @@
+ALTER TABLE AccountToken DROP COLUMN IF EXISTS email;
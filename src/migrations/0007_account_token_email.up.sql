@@ -0,0 +1,6 @@
+-- The email an account token was sent to, so it stops working once the account's email changes.
+
+ALTER TABLE AccountToken ADD COLUMN IF NOT EXISTS email VARCHAR(254);
+UPDATE AccountToken t SET email = a.email FROM Account a WHERE a.user_id = t.user_id AND t.email IS NULL;
+UPDATE AccountToken SET email = '' WHERE email IS NULL;
+ALTER TABLE AccountToken ALTER COLUMN email SET NOT NULL;
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownProvider    = NotFound("unknown or disabled oauth provider")
+	ErrIdentityLinked     = Conflict("this identity is already linked to an account")
+	ErrProviderNoEmail    = BadRequest("the provider did not share a verified email address")
+	ErrLastLoginMethod    = Conflict("cannot unlink the only way to sign in, set a password first")
+	ErrEmailNeedsLinking  = Conflict("an account with this email already exists, sign in and link it from your profile")
+	ErrIdentityNotFound   = NotFound("identity not found")
+	oauthHTTPClient       = &http.Client{Timeout: 10 * time.Second}
+	usernameInvalidChars  = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+	oauthProviderDefaults = map[string]OAuthProvider{
+		"github": {
+			AuthURL:     "https://github.com/login/oauth/authorize",
+			TokenURL:    "https://github.com/login/oauth/access_token",
+			UserInfoURL: "https://api.github.com/user",
+			EmailsURL:   "https://api.github.com/user/emails",
+			Scopes:      []string{"read:user", "user:email"},
+		},
+		"google": {
+			AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL:    "https://oauth2.googleapis.com/token",
+			UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+			Scopes:      []string{"openid", "email", "profile"},
+		},
+	}
+)
+
+/*
+ * An OAuth2 authorization-code provider. Every URL can be overridden from the environment
+ * (e.g. OAUTH_GITHUB_TOKEN_URL) so tests can point at a local mock IdP.
+ */
+type OAuthProvider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	EmailsURL    string // github only, the user endpoint omits private emails
+	Scopes       []string
+	RedirectURL  string
+}
+
+/* The parts of a provider's user info we care about */
+type OAuthUser struct {
+	Subject       string
+	Login         string
+	Email         string
+	EmailVerified bool
+}
+
+/* Pending authorization request, keyed by the random state parameter */
+type OAuthState struct {
+	State        string
+	Provider     string
+	CodeVerifier string
+	UserID       *int // set when an already signed in account is linking a new identity
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+type AccountIdentity struct {
+	IdentityID int       `json:"identity_id"`
+	UserID     int       `json:"user_id"`
+	Provider   string    `json:"provider"`
+	Subject    string    `json:"subject"`
+	Email      string    `json:"email"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type OAuthStartResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+/* Loads the providers that have a client id configured */
+func NewOAuthProvidersFromEnv() map[string]*OAuthProvider {
+	providers := map[string]*OAuthProvider{}
+
+	for name, defaults := range oauthProviderDefaults {
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+		p := defaults
+		p.Name = name
+		p.ClientID = os.Getenv(prefix + "CLIENT_ID")
+		p.ClientSecret = os.Getenv(prefix + "CLIENT_SECRET")
+		if p.ClientID == "" {
+			continue
+		}
+
+		for env, field := range map[string]*string{
+			"AUTH_URL":     &p.AuthURL,
+			"TOKEN_URL":    &p.TokenURL,
+			"USERINFO_URL": &p.UserInfoURL,
+			"EMAILS_URL":   &p.EmailsURL,
+		} {
+			if v := os.Getenv(prefix + env); v != "" {
+				*field = v
+			}
+		}
+
+		p.RedirectURL = appURL() + "/oauth/" + name + "/callback"
+		providers[name] = &p
+	}
+
+	return providers
+}
+
+/* S256 code challenge for PKCE */
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *OAuthProvider) authorizationURL(state, verifier string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {pkceChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	return p.AuthURL + "?" + q.Encode()
+}
+
+/* Trades the authorization code for an access token */
+func (p *OAuthProvider) exchange(code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json") // github answers form encoded otherwise
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.doJSON(req, &body); err != nil {
+		return "", err
+	}
+
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("%s token exchange failed: %s %s", p.Name, body.Error, body.ErrorDescription)
+	}
+
+	return body.AccessToken, nil
+}
+
+func (p *OAuthProvider) get(rawURL, accessToken string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	return p.doJSON(req, v)
+}
+
+func (p *OAuthProvider) doJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("User-Agent", "algoduels-api") // required by github
+
+	res, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s responded %d: %s", p.Name, res.StatusCode, body)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+/* Fetches and normalizes the signed in user */
+func (p *OAuthProvider) fetchUser(accessToken string) (*OAuthUser, error) {
+	if p.Name == "github" {
+		var u struct {
+			ID    int64  `json:"id"`
+			Login string `json:"login"`
+		}
+		if err := p.get(p.UserInfoURL, accessToken, &u); err != nil {
+			return nil, err
+		}
+
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := p.get(p.EmailsURL, accessToken, &emails); err != nil {
+			return nil, err
+		}
+
+		user := &OAuthUser{Subject: strconv.FormatInt(u.ID, 10), Login: u.Login}
+		for _, e := range emails {
+			if e.Primary && e.Verified {
+				user.Email = e.Email
+				user.EmailVerified = true
+			}
+		}
+		return user, nil
+	}
+
+	// OpenID Connect userinfo
+	var u struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := p.get(p.UserInfoURL, accessToken, &u); err != nil {
+		return nil, err
+	}
+
+	login, _, _ := strings.Cut(u.Email, "@")
+	return &OAuthUser{Subject: u.Sub, Login: login, Email: u.Email, EmailVerified: u.EmailVerified}, nil
+}
+
+/* Turns a provider login into a valid username that isn't taken yet */
+func (s *APIServer) availableUsername(ctx context.Context, login string) string {
+	base := usernameInvalidChars.ReplaceAllString(login, "")
+	if len(base) > maxUsernameLength-5 {
+		base = base[:maxUsernameLength-5]
+	}
+	if validateUsername(base) != "" {
+		base = "player"
+	}
+
+	candidate := base
+	for i := 0; i < 10; i++ {
+		if _, err := s.store.GetAccountByUsername(ctx, candidate); err != nil {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%s", base, randomToken(3))
+	}
+
+	return candidate
+}
+
+func (s *APIServer) oauthProvider(r *http.Request) (*OAuthProvider, error) {
+	p, ok := s.oauth[mux.Vars(r)["provider"]]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// GET api/auth/oauth/{provider}/start
+// Signed in callers start a linking flow, everyone else a sign in / sign up flow
+func (s *APIServer) handleOAuthStartRequest(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.oauthProvider(r)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	state := &OAuthState{
+		State:        randomToken(24),
+		Provider:     p.Name,
+		CodeVerifier: randomToken(48),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oauthStateTTL),
+	}
+	if account := currentAccount(r); account != nil {
+		state.UserID = &account.UserID
+	}
+
+	if err := s.store.CreateOAuthState(r.Context(), state); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, OAuthStartResponse{AuthorizationURL: p.authorizationURL(state.State, state.CodeVerifier)})
+}
+
+// POST api/auth/oauth/{provider}/callback
+// Called by the frontend with the code and state the provider redirected back with. A link is
+// finished with the access token of the account that started it
+func (s *APIServer) handleOAuthCallbackRequest(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.oauthProvider(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(OAuthCallbackRequest)
+	if err := decodeJSON(w, r, req); err != nil {
+		return err
+	}
+
+	state, err := s.store.ConsumeOAuthState(r.Context(), req.State)
+	if err != nil && !errors.Is(err, ErrInvalidToken) {
+		return err
+	}
+	if err != nil || state.Provider != p.Name {
+		return BadRequest("invalid or expired oauth state")
+	}
+
+	// Otherwise anyone could start linking and have someone else finish it with their identity
+	if state.UserID != nil {
+		if account := currentAccount(r); account == nil || account.UserID != *state.UserID {
+			return Forbidden("only the account that started linking can finish it")
+		}
+	}
+
+	accessToken, err := p.exchange(req.Code, state.CodeVerifier)
+	if err != nil {
+		return UpstreamFailed(err, "could not complete sign in with %s", p.Name)
+	}
+
+	user, err := p.fetchUser(accessToken)
+	if err != nil {
+		return UpstreamFailed(err, "could not complete sign in with %s", p.Name)
+	}
+
+	identity := &AccountIdentity{
+		Provider:  p.Name,
+		Subject:   user.Subject,
+		Email:     user.Email,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	/* Linking a new identity to the account that started the flow */
+	if state.UserID != nil {
+		identity.UserID = *state.UserID
+		if err := s.store.CreateAccountIdentity(r.Context(), identity); err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusCreated, identity)
+	}
+
+	/* Returning user */
+	account, err := s.store.GetAccountByIdentity(r.Context(), p.Name, user.Subject)
+	if err == nil {
+		tokens, err := s.issueTokens(r, account.UserID, randomToken(16))
+		if err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, tokens)
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return err
+	}
+
+	/* First sign in, create an account */
+	if user.Email == "" || !user.EmailVerified {
+		return ErrProviderNoEmail
+	}
+
+	// Never attach to an existing account by email alone, that would let anyone who controls
+	// an identity with the same address take the account over
+	_, err = s.store.GetAccountByEmail(r.Context(), user.Email)
+	if err == nil {
+		return ErrEmailNeedsLinking
+	}
+	if !hasCode(err, CodeNotFound) {
+		return err
+	}
+
+	account, err = s.store.CreateAccount(r.Context(), NewAccountRequest(s.availableUsername(r.Context(), user.Login), "", "", user.Email, ""))
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.MarkEmailVerified(r.Context(), account.UserID); err != nil {
+		return err
+	}
+
+	identity.UserID = account.UserID
+	if err := s.store.CreateAccountIdentity(r.Context(), identity); err != nil {
+		return err
+	}
+
+	tokens, err := s.issueTokens(r, account.UserID, randomToken(16))
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusCreated, tokens)
+}
+
+// GET api/accounts/{id}/identities
+func (s *APIServer) handleGetAccountIdentities(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r, "user_id")
+	if err != nil {
+		return err
+	}
+
+	identities, err := s.store.GetAccountIdentities(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, identities)
+}
+
+// DELETE api/accounts/{id}/identities/{provider}
+func (s *APIServer) handleDeleteAccountIdentity(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r, "user_id")
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteAccountIdentity(r.Context(), id, mux.Vars(r)["provider"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+type mockIdPUser struct {
+	Sub           string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+/*
+ * An OpenID Connect provider that signs in whoever the test says. It only hands out an access
+ * token for a code when the verifier matches the challenge the code was issued for.
+ */
+type mockIdP struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	codes  map[string]mockIdPGrant
+	tokens map[string]mockIdPUser
+}
+
+type mockIdPGrant struct {
+	challenge string
+	user      mockIdPUser
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	idp := &mockIdP{codes: map[string]mockIdPGrant{}, tokens: map[string]mockIdPUser{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+
+		grant, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		if !ok || pkceChallenge(r.FormValue("code_verifier")) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := randomToken(16)
+		idp.tokens[token] = grant.user
+		json.NewEncoder(w).Encode(map[string]string{"access_token": token, "token_type": "bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+
+		user, ok := idp.tokens[r.Header.Get("Authorization")[len("Bearer "):]]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(user)
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *mockIdP) provider() *OAuthProvider {
+	return &OAuthProvider{
+		Name:        "google",
+		ClientID:    "client",
+		AuthURL:     idp.URL + "/authorize",
+		TokenURL:    idp.URL + "/token",
+		UserInfoURL: idp.URL + "/userinfo",
+		RedirectURL: "http://localhost:3000/oauth/google/callback",
+	}
+}
+
+/* Signs `user` in at the authorization URL, returning the code and state it redirects back with */
+func (idp *mockIdP) authorize(t *testing.T, authorizationURL string, user mockIdPUser) OAuthCallbackRequest {
+	t.Helper()
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("expected a PKCE challenge in %s", authorizationURL)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code := randomToken(16)
+	idp.codes[code] = mockIdPGrant{challenge: q.Get("code_challenge"), user: user}
+	return OAuthCallbackRequest{Code: code, State: q.Get("state")}
+}
+
+func newOAuthClient(t *testing.T) (*specClient, *mockIdP) {
+	c := newSpecClient(t)
+	idp := newMockIdP(t)
+	c.server.oauth = map[string]*OAuthProvider{"google": idp.provider()}
+	return c, idp
+}
+
+/* Runs the whole flow for `user`, signed in as `token` when it isn't empty */
+func (c *specClient) oauthSignIn(idp *mockIdP, token string, user mockIdPUser, status int) map[string]interface{} {
+	c.t.Helper()
+	start := c.call("GET", "/auth/oauth/google/start", token, nil, http.StatusOK)
+	callback := idp.authorize(c.t, start["authorization_url"].(string), user)
+	return c.call("POST", "/auth/oauth/google/callback", token, callback, status)
+}
+
+/* The path of the account `username` */
+func (c *specClient) accountPath(username string) string {
+	c.t.Helper()
+	account, err := c.server.store.GetAccountByUsername(context.Background(), username)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return fmt.Sprintf("/accounts/%d", account.UserID)
+}
+
+func TestOAuthRejectsBadStateAndVerifier(t *testing.T) {
+	c, idp := newOAuthClient(t)
+	user := mockIdPUser{Sub: "1", Email: "alice@example.com", EmailVerified: true}
+
+	start := c.call("GET", "/auth/oauth/google/start", "", nil, http.StatusOK)
+	callback := idp.authorize(t, start["authorization_url"].(string), user)
+
+	c.call("POST", "/auth/oauth/google/callback", "", OAuthCallbackRequest{Code: callback.Code, State: "made-up"}, http.StatusBadRequest)
+
+	// A code issued for this flow, redeemed with another flow's state and so its verifier
+	other := c.call("GET", "/auth/oauth/google/start", "", nil, http.StatusOK)
+	otherState := idp.authorize(t, other["authorization_url"].(string), user).State
+	c.call("POST", "/auth/oauth/google/callback", "", OAuthCallbackRequest{Code: callback.Code, State: otherState}, http.StatusBadGateway)
+
+	// States are single use, even when the sign in failed
+	c.call("POST", "/auth/oauth/google/callback", "", OAuthCallbackRequest{Code: callback.Code, State: otherState}, http.StatusBadRequest)
+
+	if _, err := c.server.store.GetAccountByEmail(context.Background(), user.Email); !hasCode(err, CodeNotFound) {
+		t.Errorf("expected no account to be created, got %v", err)
+	}
+}
+
+func TestOAuthFirstSignInCreatesAccount(t *testing.T) {
+	c, idp := newOAuthClient(t)
+	user := mockIdPUser{Sub: "1", Email: "alice@example.com", EmailVerified: true}
+
+	c.oauthSignIn(idp, "", mockIdPUser{Sub: "2", Email: "bob@example.com"}, http.StatusBadRequest)
+
+	tokens := c.oauthSignIn(idp, "", user, http.StatusCreated)
+	alice := tokens["access_token"].(string)
+	if again := c.oauthSignIn(idp, "", user, http.StatusOK); again["access_token"] == "" {
+		t.Fatalf("expected the returning user to be signed in, got %v", again)
+	}
+
+	path := c.accountPath("alice")
+	if got := c.call("GET", path, alice, nil, http.StatusOK); got["email"] != user.Email || got["email_verified"] != true {
+		t.Errorf("expected a verified account with the provider's email, got %v", got)
+	}
+	if identities := c.callList("GET", path+"/identities", alice, nil, http.StatusOK); len(identities) != 1 {
+		t.Errorf("expected the identity to be linked, got %v", identities)
+	}
+
+	// The identity is the only way into the account
+	c.call("DELETE", path+"/identities/google", alice, nil, http.StatusConflict)
+}
+
+func TestOAuthLinksAndUnlinksIdentities(t *testing.T) {
+	c, idp := newOAuthClient(t)
+	alice := signUp(c, "alice", RolePlayer)
+	path := c.accountPath("alice")
+
+	// The provider's email doesn't have to match when the account owner is the one linking
+	user := mockIdPUser{Sub: "1", Email: "alice@gmail.example", EmailVerified: true}
+	identity := c.oauthSignIn(idp, alice, user, http.StatusCreated)
+	if identity["provider"] != "google" || identity["subject"] != "1" {
+		t.Fatalf("unexpected identity %v", identity)
+	}
+	if identities := c.callList("GET", path+"/identities", alice, nil, http.StatusOK); len(identities) != 1 {
+		t.Fatalf("expected the identity to be listed, got %v", identities)
+	}
+
+	if tokens := c.oauthSignIn(idp, "", user, http.StatusOK); tokens["access_token"] == "" {
+		t.Fatalf("expected the linked identity to sign in, got %v", tokens)
+	}
+	c.oauthSignIn(idp, signUp(c, "bob", RolePlayer), user, http.StatusConflict)
+
+	c.call("DELETE", path+"/identities/google", alice, nil, http.StatusNoContent)
+	c.call("DELETE", path+"/identities/google", alice, nil, http.StatusNotFound)
+	if identities := c.callList("GET", path+"/identities", alice, nil, http.StatusOK); len(identities) != 0 {
+		t.Fatalf("expected the identity to be unlinked, got %v", identities)
+	}
+}
+
+/* A link started by one account can't be finished by whoever the URL is sent to */
+func TestOAuthLinkOnlyFinishesForItsAccount(t *testing.T) {
+	c, idp := newOAuthClient(t)
+	mallory := signUp(c, "mallory", RolePlayer)
+	victim := mockIdPUser{Sub: "1", Email: "alice@example.com", EmailVerified: true}
+
+	for _, token := range []string{"", signUp(c, "alice", RolePlayer)} {
+		start := c.call("GET", "/auth/oauth/google/start", mallory, nil, http.StatusOK)
+		callback := idp.authorize(t, start["authorization_url"].(string), victim)
+		c.call("POST", "/auth/oauth/google/callback", token, callback, http.StatusForbidden)
+	}
+
+	if identities := c.callList("GET", c.accountPath("mallory")+"/identities", mallory, nil, http.StatusOK); len(identities) != 0 {
+		t.Errorf("expected nothing to be linked to mallory, got %v", identities)
+	}
+}
+
+/* Owning an identity with the same email isn't proof of owning the account */
+func TestOAuthDoesNotAttachByEmail(t *testing.T) {
+	c, idp := newOAuthClient(t)
+	alice := signUp(c, "alice", RolePlayer)
+
+	c.oauthSignIn(idp, "", mockIdPUser{Sub: "1", Email: "ALICE@example.com", EmailVerified: true}, http.StatusConflict)
+
+	if identities := c.callList("GET", c.accountPath("alice")+"/identities", alice, nil, http.StatusOK); len(identities) != 0 {
+		t.Errorf("expected no identity to be linked, got %v", identities)
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Just enough of OpenAPI 3.0 to describe this API. The document is built from the route table,
+ * and its schemas from the Go types the handlers read and write, so it can't drift from what
+ * the router actually serves. openapi.json at the root of the repo is a copy of it, kept up to
+ * date by TestOpenAPISpecUpToDate, and is what client/ is generated from.
+ */
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	AnyOf                []*openAPISchema          `json:"anyOf,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	Minimum              *int                      `json:"minimum,omitempty"`
+	Maximum              *int                      `json:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+var errorResponseSchema = &openAPISchema{
+	Type: "object",
+	Properties: map[string]*openAPISchema{
+		"error":  {Type: "string"},
+		"code":   {Type: "string"},
+		"fields": {Type: "object", AdditionalProperties: &openAPISchema{Type: "string"}},
+	},
+	Required: []string{"code", "error"},
+}
+
+func (s *APIServer) openAPI() *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "AlgoDuels API", Version: "1.0.0"},
+		Servers: []openAPIServer{{URL: apiRoute}},
+		Paths:   map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: map[string]*openAPISchema{"Error": errorResponseSchema},
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	routes := s.routes()
+	schemas := &schemaBuilder{schemas: doc.Components.Schemas, inputs: map[string]bool{}}
+	operations := make([]*openAPIOperation, len(routes))
+
+	// Response types first, so a type that is also read from a request body keeps its own name
+	// for the response and the request gets the "Input" variant
+	for i, rt := range routes {
+		operations[i] = rt.operation(schemas)
+	}
+	for i, rt := range routes {
+		if rt.request != nil {
+			operations[i].RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  jsonContent(schemas.schemaOf(rt.request, true)),
+			}
+			if rt.ndjson {
+				operations[i].RequestBody.Content[ndjsonContentType] = openAPIMediaType{Schema: operations[i].RequestBody.Content["application/json"].Schema.Items}
+			}
+		}
+
+		path := openAPIPath(rt.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = operations[i]
+	}
+
+	return doc
+}
+
+/* Everything but the request body, see openAPI */
+func (rt route) operation(schemas *schemaBuilder) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: rt.name,
+		Summary:     rt.summary,
+		Tags:        []string{rt.tag},
+		Responses:   map[string]*openAPIResponse{},
+	}
+
+	for _, name := range pathParams(rt.path) {
+		schema := &openAPISchema{Type: "string"}
+		if strings.Contains(rt.path, "{"+name+":[0-9]+}") {
+			schema.Type = "integer"
+		}
+		op.Parameters = append(op.Parameters, openAPIParameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	if rt.query != nil {
+		op.Parameters = append(op.Parameters, schemas.queryParams(reflect.TypeOf(rt.query))...)
+	}
+
+	op.Responses[strconv.Itoa(rt.status)] = successResponse(rt.status, rt.response, schemas)
+	for status, body := range rt.also {
+		op.Responses[strconv.Itoa(status)] = successResponse(status, body, schemas)
+	}
+
+	errorStatuses := []int{}
+	if rt.request != nil || rt.query != nil {
+		errorStatuses = append(errorStatuses, http.StatusBadRequest)
+	}
+	if rt.access != nil {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+		errorStatuses = append(errorStatuses, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	if len(pathParams(rt.path)) > 0 {
+		errorStatuses = append(errorStatuses, http.StatusNotFound)
+	}
+	if rt.limit != nil {
+		errorStatuses = append(errorStatuses, http.StatusTooManyRequests)
+	}
+	for _, status := range errorStatuses {
+		op.Responses[strconv.Itoa(status)] = errorResponse(http.StatusText(status))
+	}
+	op.Responses["default"] = errorResponse("Error")
+
+	return op
+}
+
+func successResponse(status int, body interface{}, schemas *schemaBuilder) *openAPIResponse {
+	response := &openAPIResponse{Description: http.StatusText(status)}
+	if body != nil {
+		response.Content = jsonContent(schemas.schemaOf(body, false))
+	}
+	return response
+}
+
+func errorResponse(description string) *openAPIResponse {
+	return &openAPIResponse{
+		Description: description,
+		Content:     jsonContent(&openAPISchema{Ref: schemaRefPrefix + "Error"}),
+	}
+}
+
+func jsonContent(schema *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+/* Names of the variables in a mux path, in order */
+func pathParams(path string) []string {
+	names := []string{}
+	for _, segment := range strings.Split(openAPIPath(path), "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, segment[1:len(segment)-1])
+		}
+	}
+	return names
+}
+
+/*
+ * Turns Go types into schemas the way encoding/json would serialise them. Named structs become
+ * components and are referenced, everything else is inlined.
+ *
+ * Responses and requests differ in what is required: a response always has every field that
+ * isn't omitempty, while a request only needs what its `validate` tags require, and documents
+ * their bounds and enums too. A struct used both ways gets a second component, suffixed with
+ * "Input", for requests.
+ */
+type schemaBuilder struct {
+	schemas map[string]*openAPISchema
+	inputs  map[string]bool // component names built for requests
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (b *schemaBuilder) schemaOf(v interface{}, input bool) *openAPISchema {
+	if variants, ok := v.(anyOf); ok {
+		schema := &openAPISchema{}
+		for _, variant := range variants {
+			schema.AnyOf = append(schema.AnyOf, b.schema(reflect.TypeOf(variant), input))
+		}
+		return schema
+	}
+	return b.schema(reflect.TypeOf(v), input)
+}
+
+func (b *schemaBuilder) schema(t reflect.Type, input bool) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return &openAPISchema{Ref: schemaRefPrefix + b.component(t, input)}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: b.schema(t.Elem(), input)}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: b.schema(t.Elem(), input)}
+	case reflect.Struct:
+		return b.object(t, input)
+	}
+
+	// interface{}, i.e. anything at all
+	return &openAPISchema{}
+}
+
+func (b *schemaBuilder) component(t reflect.Type, input bool) string {
+	name := componentName(t)
+	if _, ok := b.schemas[name]; ok && b.inputs[name] != input {
+		name += "Input"
+	}
+	if _, ok := b.schemas[name]; ok {
+		return name
+	}
+
+	// Registered before its fields are walked so that recursive types terminate
+	b.schemas[name] = &openAPISchema{}
+	b.inputs[name] = input
+	*b.schemas[name] = *b.object(t, input)
+	return name
+}
+
+/* The type's name, with generic ones named after their type argument, Page[*Problem] -> ProblemPage */
+func componentName(t reflect.Type) string {
+	name, arg, generic := strings.Cut(t.Name(), "[")
+	if !generic {
+		return name
+	}
+	arg = strings.TrimSuffix(arg, "]")
+	return arg[strings.LastIndexAny(arg, ".*]")+1:] + name
+}
+
+/* The fields of a query struct as query parameters, documented like request body fields */
+func (b *schemaBuilder) queryParams(t reflect.Type) []openAPIParameter {
+	params := []openAPIParameter{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			params = append(params, b.queryParams(f.Type)...)
+			continue
+		}
+		if !f.IsExported() || jsonFieldName(f) == "-" {
+			continue
+		}
+
+		schema := b.schema(f.Type, true)
+		required := applyRules(schema, f.Tag.Get("validate"))
+		params = append(params, openAPIParameter{Name: jsonFieldName(f), In: "query", Required: required, Schema: schema})
+	}
+	return params
+}
+
+func (b *schemaBuilder) object(t reflect.Type, input bool) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	b.addFields(schema, t, input)
+	sort.Strings(schema.Required)
+	return schema
+}
+
+func (b *schemaBuilder) addFields(schema *openAPISchema, t reflect.Type, input bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			b.addFields(schema, f.Type, input)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		property := b.schema(f.Type, input)
+		if f.Type.Kind() == reflect.Ptr && property.Ref == "" {
+			property.Nullable = true
+		}
+		schema.Properties[name] = property
+
+		required := !strings.Contains(opts, "omitempty")
+		if input {
+			required = applyRules(property, f.Tag.Get("validate"))
+		}
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+/* Documents a field's `validate` tag on its schema, see validateTags. Reports whether it's required */
+func applyRules(property *openAPISchema, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		bound, _ := strconv.Atoi(arg)
+
+		switch name {
+		case "required":
+			required = true
+		case "min", "max":
+			limit := &bound
+			switch {
+			case property.Type == "string" && name == "min":
+				property.MinLength = limit
+			case property.Type == "string":
+				property.MaxLength = limit
+			case property.Type == "array" && name == "min":
+				property.MinItems = limit
+			case property.Type == "array":
+				property.MaxItems = limit
+			case name == "min":
+				property.Minimum = limit
+			default:
+				property.Maximum = limit
+			}
+		case "oneof":
+			for _, option := range strings.Fields(arg) {
+				property.Enum = append(property.Enum, option)
+			}
+		case "difficulty":
+			property.Enum = []interface{}{difficulties.Easy, difficulties.Medium, difficulties.Hard}
+		}
+	}
+	return required
+}
+
+// GET api/openapi.json
+func (s *APIServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) error {
+	return WriteJSON(w, http.StatusOK, s.openAPI())
+}
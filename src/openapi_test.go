@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bobby-rust/algoduels-api/client"
+	"github.com/gorilla/mux"
+)
+
+var updateSpec = flag.Bool("update", false, "rewrite ../openapi.json from the route table")
+
+const specPath = "../openapi.json"
+
+/* openapi.json is what client/ is generated from, so it has to match what the server serves */
+func TestOpenAPISpecUpToDate(t *testing.T) {
+	spec, err := json.MarshalIndent(newTestServer().openAPI(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec = append(spec, '\n')
+
+	if *updateSpec {
+		if err := os.WriteFile(specPath, spec, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	committed, err := os.ReadFile(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(committed, spec) {
+		t.Fatalf("%s is out of date, run `make openapi`", specPath)
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	doc := newTestServer().openAPI()
+
+	var check func(schema *openAPISchema, at string)
+	check = func(schema *openAPISchema, at string) {
+		if schema == nil {
+			return
+		}
+		if schema.Ref != "" && doc.Components.Schemas[strings.TrimPrefix(schema.Ref, schemaRefPrefix)] == nil {
+			t.Errorf("%s: unresolved %s", at, schema.Ref)
+		}
+		for name, property := range schema.Properties {
+			check(property, at+"."+name)
+		}
+		for i, variant := range schema.AnyOf {
+			check(variant, fmt.Sprintf("%s.anyOf[%d]", at, i))
+		}
+		check(schema.Items, at+"[]")
+		check(schema.AdditionalProperties, at+"{}")
+	}
+
+	for name, schema := range doc.Components.Schemas {
+		check(schema, name)
+	}
+	for path, operations := range doc.Paths {
+		for method, op := range operations {
+			if op.RequestBody != nil {
+				check(op.RequestBody.Content["application/json"].Schema, method+" "+path+" request")
+			}
+			for status, response := range op.Responses {
+				check(response.Content["application/json"].Schema, method+" "+path+" "+status)
+			}
+		}
+	}
+}
+
+/* The generated client has to be able to call the server, not just match its document */
+func TestGeneratedClientSubmits(t *testing.T) {
+	s := newTestServer()
+	srv := httptest.NewServer(s.newRouter())
+	defer srv.Close()
+	ctx := context.Background()
+
+	problem := NewProblem("Add", "Add a and b", "def add(a, b):", "add", 1)
+	id, err := s.store.CreateProblem(ctx, problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range [][2]string{{ProblemDraft, ProblemInReview}, {ProblemInReview, ProblemPublished}} {
+		if err := s.store.SetProblemStatus(ctx, id, status[0], status[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	api := client.New(srv.URL)
+	account, err := api.CreateAccount(ctx, &client.CreateAccountRequest{Username: "alice", Email: "alice@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := api.Login(ctx, &client.LoginRequest{Username: "alice", Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.Token = tokens.AccessToken
+
+	sub, err := api.CreateSubmission(ctx, &client.CreateSubmissionRequest{ProblemID: id, SourceCode: "def add(a, b): return a + b", Language: 71})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ProblemID != id || sub.UserID != account.UserID || sub.SubmissionID == 0 {
+		t.Errorf("unexpected submission %+v", sub)
+	}
+}
+
+/*
+ * Drives every route that doesn't need judge0, an OAuth provider or an emailed token through
+ * the router, and checks each request and response body against the document. Routes missing
+ * from the scenario fail the test unless they are listed in notExercised.
+ */
+func TestResponsesMatchSpec(t *testing.T) {
+	notExercised := map[string]bool{
+		"runCode":        true, // judge0
+		"runCodeBatch":   true,
+		"startOAuth":     true, // no providers configured
+		"completeOAuth":  true,
+		"unlinkIdentity": true,
+		"verifyEmail":    true, // the tokens only exist in emails
+		"resetPassword":  true,
+	}
+
+	c := newSpecClient(t)
+	ctx := context.Background()
+
+	alice := c.call("POST", "/accounts", "", CreateAccountRequest{Username: "alice", Email: "alice@example.com", Password: testPassword}, http.StatusCreated)
+	aliceID := int(alice["user_id"].(float64))
+	bob := c.call("POST", "/accounts", "", CreateAccountRequest{Username: "bob", Email: "bob@example.com", Password: testPassword}, http.StatusCreated)
+	bobID := int(bob["user_id"].(float64))
+
+	if err := c.server.store.UpdateAccountRole(ctx, aliceID, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := c.call("POST", "/auth/login", "", LoginRequest{Username: "alice", Password: testPassword}, http.StatusOK)
+	admin := tokens["access_token"].(string)
+	bobTokens := c.call("POST", "/auth/login", "", LoginRequest{Username: "bob", Password: testPassword}, http.StatusOK)
+	player := bobTokens["access_token"].(string)
+
+	/* Accounts, as each of the views */
+	c.call("GET", "/accounts?sort=username&order=desc&limit=1", admin, nil, http.StatusOK)
+	c.call("GET", fmt.Sprintf("/accounts/%d", bobID), "", nil, http.StatusOK)
+	c.call("GET", fmt.Sprintf("/accounts/%d", bobID), player, nil, http.StatusOK)
+	c.call("GET", fmt.Sprintf("/accounts/%d", bobID), admin, nil, http.StatusOK)
+	name := "Bob"
+	c.call("PATCH", fmt.Sprintf("/accounts/%d", bobID), player, UpdateAccountRequest{FirstName: &name}, http.StatusOK)
+	c.call("PUT", fmt.Sprintf("/accounts/%d/role", bobID), admin, UpdateRoleRequest{Role: RoleProblemSetter}, http.StatusOK)
+	c.call("GET", fmt.Sprintf("/accounts/%d/identities", bobID), player, nil, http.StatusOK)
+	c.call("GET", "/accounts/999", "", nil, http.StatusNotFound)
+	c.call("POST", "/accounts", "", CreateAccountRequest{Username: "x", Email: "nope", Password: "short"}, http.StatusBadRequest)
+
+	/* Problems and test cases */
+	c.call("POST", "/tags", admin, CreateTagRequest{Slug: "math", Name: "Math"}, http.StatusCreated)
+	c.call("POST", "/tags", admin, CreateTagRequest{Slug: "math", Name: "Maths"}, http.StatusConflict)
+	c.call("POST", "/tags", admin, CreateTagRequest{Slug: "arrays", Name: "Arrays"}, http.StatusCreated)
+	c.call("GET", "/tags", "", nil, http.StatusOK)
+	problem := c.call("POST", "/problems", admin, CreateProblemRequest{
+		ProblemName:  "Two Sum",
+		Tags:         []string{"math"},
+		Prompt:       "Add them up",
+		StarterCode:  "def two_sum(a, b):",
+		FunctionName: "two_sum",
+		Difficulty:   1,
+		TestCases: []CreateTestCaseRequest{
+			{IO: IO{Input: map[string]interface{}{"a": 1, "b": 2}, Output: 3}, Kind: TestCaseSanity},
+		},
+	}, http.StatusCreated)
+	problemID := int(problem["problem_id"].(float64))
+
+	/* Authoring, from draft to published */
+	c.call("GET", fmt.Sprintf("/problems/%d", problemID), "", nil, http.StatusNotFound)
+	c.call("GET", "/problems/drafts?status=draft&sort=name", admin, nil, http.StatusOK)
+	c.call("GET", "/problems/drafts", "", nil, http.StatusUnauthorized)
+	c.call("PUT", fmt.Sprintf("/problems/%d", problemID), admin, UpdateProblemRequest{
+		Revision:     1,
+		ProblemName:  "Two Sum",
+		Tags:         []string{"math"},
+		Prompt:       "Add them up, a and b",
+		StarterCode:  "def two_sum(a, b):",
+		FunctionName: "two_sum",
+		Difficulty:   1,
+	}, http.StatusOK)
+	c.call("PUT", fmt.Sprintf("/problems/%d", problemID), admin, UpdateProblemRequest{Revision: 1, ProblemName: "Two Sum", Prompt: "p", StarterCode: "s", FunctionName: "two_sum", Difficulty: 1}, http.StatusConflict)
+	c.callList("GET", fmt.Sprintf("/problems/%d/revisions", problemID), admin, nil, http.StatusOK)
+	c.call("POST", fmt.Sprintf("/problems/%d/review", problemID), admin, nil, http.StatusOK)
+	c.call("POST", fmt.Sprintf("/problems/%d/withdraw", problemID), admin, nil, http.StatusOK)
+	c.call("POST", fmt.Sprintf("/problems/%d/publish", problemID), admin, nil, http.StatusConflict)
+	c.call("POST", fmt.Sprintf("/problems/%d/review", problemID), admin, nil, http.StatusOK)
+	c.call("POST", fmt.Sprintf("/problems/%d/publish", problemID), admin, nil, http.StatusConflict)
+	c.call("PUT", fmt.Sprintf("/problems/%d/solutions/71", problemID), admin, SetReferenceSolutionRequest{SourceCode: "def two_sum(a, b): return a - b"}, http.StatusOK)
+	c.call("POST", fmt.Sprintf("/problems/%d/publish", problemID), admin, nil, http.StatusConflict)
+	c.call("PUT", fmt.Sprintf("/problems/%d/solutions/71", problemID), admin, SetReferenceSolutionRequest{SourceCode: "def two_sum(a, b): return a + b"}, http.StatusOK)
+	c.call("PUT", fmt.Sprintf("/problems/%d/solutions/63", problemID), admin, SetReferenceSolutionRequest{SourceCode: "const two_sum = (a, b) => { return a + b }"}, http.StatusOK)
+	c.call("PUT", fmt.Sprintf("/problems/%d/solutions/1", problemID), admin, SetReferenceSolutionRequest{SourceCode: "x"}, http.StatusNotFound)
+	c.callList("GET", fmt.Sprintf("/problems/%d/solutions", problemID), admin, nil, http.StatusOK)
+	c.call("DELETE", fmt.Sprintf("/problems/%d/solutions/63", problemID), admin, nil, http.StatusNoContent)
+	c.call("POST", fmt.Sprintf("/problems/%d/publish", problemID), admin, nil, http.StatusOK)
+	c.call("DELETE", fmt.Sprintf("/problems/%d/solutions/71", problemID), admin, nil, http.StatusConflict)
+
+	c.call("GET", "/problems", "", nil, http.StatusOK)
+	c.call("GET", "/problems?difficulty=1&sort=name&limit=10", "", nil, http.StatusOK)
+	c.call("GET", "/problems?solved=false", player, nil, http.StatusOK)
+	c.call("GET", "/problems?sort=prompt&limit=0", "", nil, http.StatusBadRequest)
+	c.call("GET", fmt.Sprintf("/problems/%d", problemID), "", nil, http.StatusOK)
+	c.call("GET", "/problems/name/Two%20Sum", "", nil, http.StatusOK)
+	c.call("GET", "/problems/slug/two-sum", "", nil, http.StatusOK)
+	c.call("GET", "/problems?tag=math", "", nil, http.StatusOK)
+	c.call("GET", "/problems/search?q=add+them", "", nil, http.StatusOK)
+	c.call("GET", "/problems/search", "", nil, http.StatusBadRequest)
+	c.call("PUT", fmt.Sprintf("/problems/%d/tags", problemID), admin, SetProblemTagsRequest{Tags: []string{"arrays", "math"}}, http.StatusOK)
+	c.call("PUT", fmt.Sprintf("/problems/%d/tags", problemID), admin, SetProblemTagsRequest{Tags: []string{"graphs"}}, http.StatusBadRequest)
+	c.call("POST", "/testcases", admin, CreateTestCaseRequest{ProblemID: problemID, IO: IO{Input: map[string]interface{}{"a": 2, "b": 2}, Output: 4}, Kind: TestCaseHidden}, http.StatusCreated)
+	c.call("POST", "/testcases", admin, CreateTestCaseRequest{ProblemID: problemID, IO: IO{Input: map[string]interface{}{"a": 2, "b": 2}, Output: 5}, Kind: TestCaseHidden}, http.StatusBadRequest)
+	c.call("POST", fmt.Sprintf("/problems/%d/testcases:bulk", problemID), admin, []CreateTestCaseRequest{
+		{IO: IO{Input: map[string]interface{}{"a": 5, "b": 5}, Output: 10}},
+		{IO: IO{Input: map[string]interface{}{"a": 2, "b": 2}, Output: 4}},
+	}, http.StatusOK)
+	c.call("POST", fmt.Sprintf("/problems/%d/testcases:bulk?replace=true", problemID), admin, []CreateTestCaseRequest{{IO: IO{Input: map[string]interface{}{"a": 1, "c": 1}, Output: 2}}}, http.StatusBadRequest)
+	c.call("GET", fmt.Sprintf("/testcases/%d", problemID), admin, nil, http.StatusOK)
+	c.call("GET", fmt.Sprintf("/testcases/sanity/%d", problemID), "", nil, http.StatusOK)
+	imported := ProblemPackage{
+		Format:             packageFormat,
+		Slug:               "add-three",
+		ProblemName:        "Add three",
+		Prompt:             "Add a and b, and then 1",
+		StarterCode:        "def add_three(a, b):",
+		Difficulty:         1,
+		FunctionName:       "add_three",
+		TestCases:          []PackageTestCase{{IO: IO{Input: map[string]interface{}{"a": 1, "b": 1}, Output: 3}}},
+		ReferenceSolutions: map[string]string{"python3": "def add_three(a, b): return a + b + 1"},
+	}
+	c.callList("POST", "/admin/problems/import", admin, ImportProblemsRequest{Packages: []ProblemPackage{imported}}, http.StatusOK)
+	imported.Format = packageFormat + 1
+	c.callList("POST", "/admin/problems/import", admin, ImportProblemsRequest{Packages: []ProblemPackage{imported}}, http.StatusBadRequest)
+	c.call("POST", "/problems", "", CreateProblemRequest{ProblemName: "nope", Prompt: "p", StarterCode: "s", FunctionName: "f", Difficulty: 1}, http.StatusUnauthorized)
+
+	/* Submissions */
+	sub := c.call("POST", "/submissions", player, CreateSubmissionRequest{ProblemID: problemID, SourceCode: "def two_sum(a, b): return a + b", Language: 71}, http.StatusCreated)
+	c.call("GET", "/submissions", player, nil, http.StatusOK)
+	c.call("GET", "/submissions?verdict=pending&from=2020-01-01T00:00:00Z&sort=submitted_at&order=desc", player, nil, http.StatusOK)
+	c.call("GET", fmt.Sprintf("/submissions?user_id=%d", aliceID), player, nil, http.StatusForbidden)
+	c.call("GET", fmt.Sprintf("/submissions/%d", int(sub["submission_id"].(float64))), player, nil, http.StatusOK)
+	c.call("GET", "/submissions", "", nil, http.StatusUnauthorized)
+	c.call("POST", fmt.Sprintf("/problems/%d/retire", problemID), admin, nil, http.StatusOK)
+	c.call("POST", "/submissions", player, CreateSubmissionRequest{ProblemID: problemID, SourceCode: "def two_sum(a, b): return a + b", Language: 71}, http.StatusConflict)
+
+	/* Sessions and the end of bob */
+	sessions := c.callList("GET", fmt.Sprintf("/accounts/%d/sessions", bobID), player, nil, http.StatusOK)
+	secondLogin := c.call("POST", "/auth/login", "", LoginRequest{Username: "bob", Password: testPassword}, http.StatusOK)
+	c.call("DELETE", fmt.Sprintf("/accounts/%d/sessions/%s", bobID, sessions[0].(map[string]interface{})["session_id"]), player, nil, http.StatusNoContent)
+	refreshed := c.call("POST", "/auth/refresh", "", RefreshRequest{RefreshToken: secondLogin["refresh_token"].(string)}, http.StatusOK)
+	c.call("POST", "/auth/logout", "", RefreshRequest{RefreshToken: refreshed["refresh_token"].(string)}, http.StatusNoContent)
+	c.call("POST", fmt.Sprintf("/accounts/%d/password", bobID), player, ChangePasswordRequest{OldPassword: testPassword, NewPassword: "an0ther-passw0rd"}, http.StatusNoContent)
+	c.call("POST", "/auth/forgot-password", "", ForgotPasswordRequest{Email: "bob@example.com"}, http.StatusAccepted)
+	c.call("DELETE", fmt.Sprintf("/accounts/%d", bobID), admin, nil, http.StatusNoContent)
+
+	c.call("GET", "/openapi.json", "", nil, http.StatusOK)
+
+	for _, rt := range c.server.routes() {
+		if !c.exercised[rt.name] && !notExercised[rt.name] {
+			t.Errorf("%s %s is not exercised, add it to the scenario", rt.method, rt.path)
+		}
+	}
+}
+
+/* Calls the API through the router, validating both bodies against the document */
+type specClient struct {
+	t         *testing.T
+	server    *APIServer
+	router    *mux.Router
+	doc       *openAPIDocument
+	exercised map[string]bool
+}
+
+func newSpecClient(t *testing.T) *specClient {
+	s := newTestServer()
+	return &specClient{t: t, server: s, router: s.newRouter(), doc: s.openAPI(), exercised: map[string]bool{}}
+}
+
+func (c *specClient) call(method, path, token string, body interface{}, status int) map[string]interface{} {
+	c.t.Helper()
+	decoded := c.do(method, path, token, body, status)
+	object, _ := decoded.(map[string]interface{})
+	return object
+}
+
+func (c *specClient) callList(method, path, token string, body interface{}, status int) []interface{} {
+	c.t.Helper()
+	decoded := c.do(method, path, token, body, status)
+	list, _ := decoded.([]interface{})
+	return list
+}
+
+func (c *specClient) do(method, path, token string, body interface{}, status int) interface{} {
+	c.t.Helper()
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	r := httptest.NewRequest(method, apiRoute+path, bytes.NewReader(reqBody))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	var match mux.RouteMatch
+	if !c.router.Match(r, &match) || match.Route == nil || match.Route.GetName() == "" {
+		c.t.Fatalf("%s %s: no route", method, path)
+	}
+	op := c.operation(match.Route.GetName())
+	c.exercised[op.OperationID] = true
+
+	for name := range r.URL.Query() {
+		if !hasQueryParam(op, name) {
+			c.t.Fatalf("%s %s: sent a query parameter the document doesn't describe: %s", method, path, name)
+		}
+	}
+	if body != nil {
+		if op.RequestBody == nil {
+			c.t.Fatalf("%s %s: sent a body the document doesn't describe", method, path)
+		}
+		c.validate(op.RequestBody.Content["application/json"].Schema, reqBody, method+" "+path+" request")
+	}
+
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, r)
+	if w.Code != status {
+		c.t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, status, w.Code, w.Body.String())
+	}
+
+	response, ok := op.Responses[strconv.Itoa(w.Code)]
+	if !ok {
+		if w.Code < 400 {
+			c.t.Fatalf("%s %s: status %d is not in the document", method, path, w.Code)
+		}
+		response = op.Responses["default"]
+	}
+
+	if response.Content == nil {
+		if w.Body.Len() > 0 {
+			c.t.Errorf("%s %s: expected no body, got %s", method, path, w.Body.String())
+		}
+		return nil
+	}
+	return c.validate(response.Content["application/json"].Schema, w.Body.Bytes(), fmt.Sprintf("%s %s %d", method, path, w.Code))
+}
+
+func hasQueryParam(op *openAPIOperation, name string) bool {
+	for _, p := range op.Parameters {
+		if p.In == "query" && p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *specClient) operation(name string) *openAPIOperation {
+	for _, operations := range c.doc.Paths {
+		for _, op := range operations {
+			if op.OperationID == name {
+				return op
+			}
+		}
+	}
+	c.t.Fatalf("no operation %s", name)
+	return nil
+}
+
+func (c *specClient) validate(schema *openAPISchema, body []byte, at string) interface{} {
+	c.t.Helper()
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		c.t.Fatalf("%s: %v", at, err)
+	}
+	for _, problem := range validateSchema(c.doc, schema, decoded, "$") {
+		c.t.Errorf("%s: %s", at, problem)
+	}
+	return decoded
+}
+
+/*
+ * A validator for the subset of JSON Schema the document uses. It is stricter than the spec in
+ * one way: objects with properties reject any the schema doesn't list, so a field added to a
+ * type without the document noticing shows up here.
+ */
+func validateSchema(doc *openAPIDocument, schema *openAPISchema, value interface{}, at string) []string {
+	if schema.Ref != "" {
+		return validateSchema(doc, doc.Components.Schemas[strings.TrimPrefix(schema.Ref, schemaRefPrefix)], value, at)
+	}
+
+	if len(schema.AnyOf) > 0 {
+		for _, variant := range schema.AnyOf {
+			if len(validateSchema(doc, variant, value, at)) == 0 {
+				return nil
+			}
+		}
+		return []string{at + ": matches none of anyOf"}
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return []string{at + ": null is not " + schema.Type}
+	}
+
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, option := range schema.Enum {
+			found = found || fmt.Sprint(option) == fmt.Sprint(value)
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: %v is not one of %v", at, value, schema.Enum)}
+		}
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{fmt.Sprintf("%s: expected a string, got %v", at, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected a boolean, got %v", at, value)}
+		}
+	case "number", "integer":
+		n, ok := value.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected a number, got %v", at, value)}
+		}
+		if schema.Type == "integer" && n != math.Trunc(n) {
+			return []string{fmt.Sprintf("%s: expected an integer, got %v", at, n)}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %v", at, value)}
+		}
+		problems := []string{}
+		for i, item := range items {
+			problems = append(problems, validateSchema(doc, schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %v", at, value)}
+		}
+		return validateObject(doc, schema, object, at)
+	}
+	return nil
+}
+
+func validateObject(doc *openAPIDocument, schema *openAPISchema, object map[string]interface{}, at string) []string {
+	problems := []string{}
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			problems = append(problems, at+": missing "+name)
+		}
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		property, ok := schema.Properties[key]
+		switch {
+		case ok:
+		case schema.AdditionalProperties != nil:
+			property = schema.AdditionalProperties
+		case len(schema.Properties) > 0:
+			problems = append(problems, at+": unexpected property "+key)
+			continue
+		default:
+			continue
+		}
+		problems = append(problems, validateSchema(doc, property, object[key], at+"."+key)...)
+	}
+	return problems
+}
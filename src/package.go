@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*
+ * Problem packages move problems between databases and in and out of version control. On disk a
+ * package is a directory:
+ *
+ *	problem.json          the manifest, see packageManifest
+ *	prompt.md             the files the manifest names, relative to it
+ *	starter_code.txt
+ *	tests/hidden.json     JSON arrays of test cases, read in the order the manifest lists them
+ *	solutions/python3.py  reference solutions, one per language
+ *
+ * Reading one gives a ProblemPackage, which is also what POST api/admin/problems/import takes.
+ * Imports match problems by slug and bring them in line with their package, test cases and
+ * reference solutions included, so importing a package twice is the same as importing it once.
+ *
+ * packageFormat is bumped whenever a package would mean something else to an older reader.
+ * Readers take every format up to their own.
+ */
+const packageFormat = 1
+
+const manifestFile = "problem.json"
+
+/* problem.json. Prompt, StarterCode, TestCases and ReferenceSolutions name files */
+type packageManifest struct {
+	Format                int               `json:"format"`
+	Slug                  string            `json:"slug"`
+	ProblemName           string            `json:"problem_name"`
+	Difficulty            int               `json:"difficulty"`
+	FunctionName          string            `json:"function_name"`
+	RevealHiddenOnFailure bool              `json:"reveal_hidden_on_failure,omitempty"`
+	Tags                  []string          `json:"tags,omitempty"`
+	Prompt                string            `json:"prompt"`
+	StarterCode           string            `json:"starter_code"`
+	TestCases             []string          `json:"test_cases"`
+	ReferenceSolutions    map[string]string `json:"reference_solutions,omitempty"` // language name -> file
+}
+
+var sourceExtensions = map[string]string{
+	"python3":    ".py",
+	"javascript": ".js",
+}
+
+/* Reads the package in `dir`. It still has to be validated, see checkRequest */
+func readPackage(dir string) (*ProblemPackage, error) {
+	var version struct {
+		Format int `json:"format"`
+	}
+	if err := readPackageJSON(dir, manifestFile, &version, false); err != nil {
+		return nil, err
+	}
+	if version.Format > packageFormat {
+		return nil, fmt.Errorf("%s is in package format %d, this build reads up to %d", dir, version.Format, packageFormat)
+	}
+
+	manifest := new(packageManifest)
+	if err := readPackageJSON(dir, manifestFile, manifest, true); err != nil {
+		return nil, err
+	}
+
+	pkg := &ProblemPackage{
+		Format:                manifest.Format,
+		Slug:                  manifest.Slug,
+		ProblemName:           manifest.ProblemName,
+		Difficulty:            manifest.Difficulty,
+		FunctionName:          manifest.FunctionName,
+		RevealHiddenOnFailure: manifest.RevealHiddenOnFailure,
+		Tags:                  manifest.Tags,
+		TestCases:             []PackageTestCase{},
+	}
+
+	var err error
+	if pkg.Prompt, err = readPackageFile(dir, manifest.Prompt); err != nil {
+		return nil, err
+	}
+	if pkg.StarterCode, err = readPackageFile(dir, manifest.StarterCode); err != nil {
+		return nil, err
+	}
+
+	for _, name := range manifest.TestCases {
+		var tests []PackageTestCase
+		if err := readPackageJSON(dir, name, &tests, true); err != nil {
+			return nil, err
+		}
+		pkg.TestCases = append(pkg.TestCases, tests...)
+	}
+
+	if len(manifest.ReferenceSolutions) > 0 {
+		pkg.ReferenceSolutions = map[string]string{}
+	}
+	for language, name := range manifest.ReferenceSolutions {
+		if pkg.ReferenceSolutions[language], err = readPackageFile(dir, name); err != nil {
+			return nil, err
+		}
+	}
+	return pkg, nil
+}
+
+/* Reads a file the manifest names, which has to be inside the package */
+func readPackageFile(dir, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%s: %q is outside of the package", dir, name)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	return string(b), err
+}
+
+func readPackageJSON(dir, name string, v interface{}, strict bool) error {
+	content, err := readPackageFile(dir, name)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(content))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Join(dir, name), err)
+	}
+	return nil
+}
+
+/* Writes `pkg` to `dir` in the layout readPackage reads, creating it if needed */
+func writePackage(dir string, pkg *ProblemPackage) error {
+	manifest := &packageManifest{
+		Format:                packageFormat,
+		Slug:                  pkg.Slug,
+		ProblemName:           pkg.ProblemName,
+		Difficulty:            pkg.Difficulty,
+		FunctionName:          pkg.FunctionName,
+		RevealHiddenOnFailure: pkg.RevealHiddenOnFailure,
+		Tags:                  pkg.Tags,
+		Prompt:                "prompt.md",
+		StarterCode:           "starter_code.txt",
+		TestCases:             []string{},
+	}
+
+	files := map[string][]byte{
+		manifest.Prompt:      []byte(pkg.Prompt),
+		manifest.StarterCode: []byte(pkg.StarterCode),
+	}
+
+	// One file per kind, so that hidden test cases are easy to tell apart in a diff
+	byKind := map[string][]PackageTestCase{}
+	for _, tc := range pkg.TestCases {
+		byKind[tc.Kind] = append(byKind[tc.Kind], tc)
+	}
+	for _, kind := range []string{TestCaseExample, TestCaseSanity, TestCaseHidden} {
+		if len(byKind[kind]) == 0 {
+			continue
+		}
+		name := filepath.Join("tests", kind+".json")
+		b, err := marshalPackageJSON(byKind[kind])
+		if err != nil {
+			return err
+		}
+		manifest.TestCases = append(manifest.TestCases, name)
+		files[name] = b
+	}
+
+	if len(pkg.ReferenceSolutions) > 0 {
+		manifest.ReferenceSolutions = map[string]string{}
+	}
+	for language, source := range pkg.ReferenceSolutions {
+		name := filepath.Join("solutions", language+sourceExtensions[language])
+		manifest.ReferenceSolutions[language] = name
+		files[name] = []byte(source)
+	}
+
+	b, err := marshalPackageJSON(manifest)
+	if err != nil {
+		return err
+	}
+	files[manifestFile] = b
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Indented, and without escaping the < and > that prompts and outputs are full of */
+func marshalPackageJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/* The package of a stored problem */
+func exportPackage(ctx context.Context, store Storage, problem *Problem) (*ProblemPackage, error) {
+	pkg := &ProblemPackage{
+		Format:                packageFormat,
+		Slug:                  problem.Slug,
+		ProblemName:           problem.ProblemName,
+		Prompt:                problem.Prompt,
+		StarterCode:           problem.StarterCode,
+		Difficulty:            int(problem.Difficulty),
+		FunctionName:          problem.FunctionName,
+		RevealHiddenOnFailure: problem.RevealHiddenOnFailure,
+		Tags:                  problem.Tags,
+		TestCases:             []PackageTestCase{},
+	}
+
+	tests, err := store.GetTestCasesByProblemID(ctx, problem.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tc := range tests {
+		pkg.TestCases = append(pkg.TestCases, PackageTestCase{IO: tc.IO, Kind: tc.Kind})
+	}
+
+	solutions, err := store.GetReferenceSolutions(ctx, problem.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(solutions) > 0 {
+		pkg.ReferenceSolutions = map[string]string{}
+	}
+	for _, solution := range solutions {
+		pkg.ReferenceSolutions[languageName(solution.LanguageID)] = solution.SourceCode
+	}
+	return pkg, nil
+}
+
+/*
+ * Creates or updates the problem of every package, all of them or none. Reference solutions are
+ * run before the transaction, the judge being far too slow to hold one open for. New problems
+ * are drafts written by `importedBy`, which is nil for imports from the command line.
+ */
+func (s *APIServer) importPackages(ctx context.Context, pkgs []ProblemPackage, importedBy *int) ([]*ImportResult, error) {
+	flags := make([]map[string]string, len(pkgs))
+	for i := range pkgs {
+		var err error
+		if flags[i], err = s.verifyPackage(ctx, &pkgs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []*ImportResult
+	err := s.store.WithTx(ctx, func(tx Storage) error {
+		results = nil
+		for i := range pkgs {
+			result, err := importPackage(ctx, tx, &pkgs[i], flags[i], importedBy)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+/* What the package's reference solutions disagree with, keyed by the test case's ioKey */
+func (s *APIServer) verifyPackage(ctx context.Context, pkg *ProblemPackage) (map[string]string, error) {
+	problem := &Problem{FunctionName: pkg.FunctionName}
+
+	solutions := []*ReferenceSolution{}
+	for language, source := range pkg.ReferenceSolutions {
+		solutions = append(solutions, &ReferenceSolution{LanguageID: languageIDs[language], SourceCode: source})
+	}
+	sort.Slice(solutions, func(i, j int) bool { return solutions[i].LanguageID < solutions[j].LanguageID })
+
+	tests := make([]*TestCase, len(pkg.TestCases))
+	for i, tc := range pkg.TestCases {
+		tests[i] = &TestCase{TestCaseID: i, IO: tc.IO}
+	}
+
+	byIndex, err := s.verifyTestCases(ctx, problem, solutions, tests)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := map[string]string{}
+	for i, flag := range byIndex {
+		flags[ioKey(pkg.TestCases[i].IO)] = flag
+	}
+	return flags, nil
+}
+
+/* Identifies a test case by its input and output. encoding/json sorts map keys */
+func ioKey(io IO) string {
+	b, _ := json.Marshal(io)
+	return string(b)
+}
+
+func testCaseKey(kind string, io IO) string {
+	return kind + " " + ioKey(io)
+}
+
+/* Brings the problem with the package's slug in line with it, see importPackages */
+func importPackage(ctx context.Context, tx Storage, pkg *ProblemPackage, flags map[string]string, importedBy *int) (*ImportResult, error) {
+	result := &ImportResult{Slug: pkg.Slug, Changes: []string{}}
+	changed := func(what string) {
+		if !slices.Contains(result.Changes, "created") {
+			result.Changes = append(result.Changes, what)
+		}
+	}
+
+	for i := range pkg.TestCases {
+		if pkg.TestCases[i].Kind == "" {
+			pkg.TestCases[i].Kind = TestCaseHidden
+		}
+	}
+	tags := append([]string{}, pkg.Tags...)
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+
+	problem, err := tx.GetProblemBySlug(ctx, pkg.Slug)
+	switch {
+	case hasCode(err, CodeNotFound):
+		problem = NewProblem(pkg.ProblemName, pkg.Prompt, pkg.StarterCode, pkg.FunctionName, uint8(pkg.Difficulty))
+		problem.RevealHiddenOnFailure = pkg.RevealHiddenOnFailure
+		problem.Slug = pkg.Slug
+		problem.Tags = tags
+		problem.AuthorID = importedBy
+		if problem.ProblemID, err = tx.CreateProblem(ctx, problem); err != nil {
+			return nil, err
+		}
+		changed("created")
+
+	case err != nil:
+		return nil, err
+
+	default:
+		imported := *problem
+		imported.ProblemName = pkg.ProblemName
+		imported.Prompt = pkg.Prompt
+		imported.StarterCode = pkg.StarterCode
+		imported.Difficulty = uint8(pkg.Difficulty)
+		imported.FunctionName = pkg.FunctionName
+		imported.RevealHiddenOnFailure = pkg.RevealHiddenOnFailure
+		imported.Tags = tags
+		if !problemFieldsEqual(problem, &imported) {
+			if problem.Status == ProblemRetired {
+				return nil, Conflict("problem %s is retired", pkg.Slug)
+			}
+			if err := tx.UpdateProblem(ctx, &imported, importedBy); err != nil {
+				return nil, err
+			}
+			changed("problem")
+		}
+	}
+	result.ProblemID = problem.ProblemID
+
+	// Without reference solutions nothing would check the test cases, and the problem's own
+	// solutions would be deleted
+	if problem.Status == ProblemPublished || problem.Status == ProblemRetired {
+		if len(pkg.ReferenceSolutions) == 0 {
+			return nil, Conflict("problem %s is %s, but its package has no reference solutions", pkg.Slug, problem.Status)
+		}
+		for i, tc := range pkg.TestCases {
+			if flag, ok := flags[ioKey(tc.IO)]; ok {
+				return nil, Conflict("problem %s is %s, but test case %d of its package is flagged: %s", pkg.Slug, problem.Status, i, flag)
+			}
+		}
+	}
+
+	if err := importTestCases(ctx, tx, problem.ProblemID, pkg.TestCases, changed); err != nil {
+		return nil, err
+	}
+	if err := importReferenceSolutions(ctx, tx, problem.ProblemID, pkg.ReferenceSolutions, importedBy, changed); err != nil {
+		return nil, err
+	}
+
+	tests, err := tx.GetTestCasesByProblemID(ctx, problem.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int]string{}
+	for _, tc := range tests {
+		if flag, ok := flags[ioKey(tc.IO)]; ok {
+			byID[tc.TestCaseID] = flag
+		}
+	}
+	if err := tx.SetTestCaseFlags(ctx, problem.ProblemID, byID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+/* What UpdateProblem writes */
+func problemFieldsEqual(a, b *Problem) bool {
+	return a.ProblemName == b.ProblemName &&
+		a.Prompt == b.Prompt &&
+		a.StarterCode == b.StarterCode &&
+		a.Difficulty == b.Difficulty &&
+		a.FunctionName == b.FunctionName &&
+		a.RevealHiddenOnFailure == b.RevealHiddenOnFailure &&
+		slices.Equal(a.Tags, b.Tags)
+}
+
+/* Replaces the problem's test cases unless they already are the package's, in any order */
+func importTestCases(ctx context.Context, tx Storage, problemID int, imported []PackageTestCase, changed func(string)) error {
+	tests, err := tx.GetTestCasesByProblemID(ctx, problemID)
+	if err != nil {
+		return err
+	}
+
+	have := make([]string, len(tests))
+	for i, tc := range tests {
+		have[i] = testCaseKey(tc.Kind, tc.IO)
+	}
+	want := make([]string, len(imported))
+	for i, tc := range imported {
+		want[i] = testCaseKey(tc.Kind, tc.IO)
+	}
+	slices.Sort(have)
+	slices.Sort(want)
+	if slices.Equal(have, want) {
+		return nil
+	}
+
+	if err := tx.DeleteTestCases(ctx, problemID); err != nil {
+		return err
+	}
+	testCases := make([]*TestCase, len(imported))
+	for i, tc := range imported {
+		testCases[i] = NewTestCase(problemID, tc.IO.Input, tc.IO.Output, tc.Kind)
+	}
+	if err := tx.CreateTestCases(ctx, testCases); err != nil {
+		return err
+	}
+	changed("test_cases")
+	return nil
+}
+
+/* Sets the package's reference solutions and removes the problem's others */
+func importReferenceSolutions(ctx context.Context, tx Storage, problemID int, imported map[string]string, importedBy *int, changed func(string)) error {
+	solutions, err := tx.GetReferenceSolutions(ctx, problemID)
+	if err != nil {
+		return err
+	}
+
+	have := map[int]string{}
+	for _, solution := range solutions {
+		have[solution.LanguageID] = solution.SourceCode
+	}
+
+	updated := false
+	for language, source := range imported {
+		languageID := languageIDs[language]
+		if current, ok := have[languageID]; ok && current == source {
+			delete(have, languageID)
+			continue
+		}
+		delete(have, languageID)
+
+		if err := tx.SetReferenceSolution(ctx, &ReferenceSolution{ProblemID: problemID, LanguageID: languageID, SourceCode: source, UpdatedBy: importedBy}); err != nil {
+			return err
+		}
+		updated = true
+	}
+	for languageID := range have {
+		if err := tx.DeleteReferenceSolution(ctx, problemID, languageID); err != nil {
+			return err
+		}
+		updated = true
+	}
+
+	if updated {
+		changed("reference_solutions")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestProblemPackageRoundTrip(t *testing.T) {
+	pkg, err := readPackage("../data/problems/two-sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRequest(pkg); err != nil {
+		t.Fatal(err)
+	}
+	if len(pkg.TestCases) != 3 || pkg.ReferenceSolutions["javascript"] == "" {
+		t.Fatalf("readPackage returned %+v", pkg)
+	}
+
+	dir := t.TempDir()
+	if err := writePackage(dir, pkg); err != nil {
+		t.Fatal(err)
+	}
+	written, err := readPackage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(written, pkg) {
+		t.Fatalf("expected %+v back, got %+v", pkg, written)
+	}
+}
+
+func TestImportProblems(t *testing.T) {
+	c := newSpecClient(t)
+	admin := signUp(c, "alice", RoleAdmin)
+	setter := signUp(c, "bob", RoleProblemSetter)
+
+	add := ProblemPackage{
+		Format:       packageFormat,
+		Slug:         "add",
+		ProblemName:  "Add",
+		Prompt:       "Add a and b",
+		StarterCode:  "def add(a, b):",
+		Difficulty:   1,
+		FunctionName: "add",
+		TestCases: []PackageTestCase{
+			{IO: IO{Input: map[string]interface{}{"a": 1, "b": 2}, Output: 3}, Kind: TestCaseExample},
+			{IO: IO{Input: map[string]interface{}{"a": 2, "b": 2}, Output: 4}, Kind: TestCaseHidden},
+		},
+		ReferenceSolutions: map[string]string{"python3": "def add(a, b): return a + b"},
+	}
+	imported := func(token string, status int, pkgs ...ProblemPackage) []interface{} {
+		t.Helper()
+		return c.callList("POST", "/admin/problems/import", token, ImportProblemsRequest{Packages: pkgs}, status)
+	}
+	changes := func(results []interface{}) []interface{} {
+		t.Helper()
+		return results[0].(map[string]interface{})["changes"].([]interface{})
+	}
+
+	c.call("POST", "/admin/problems/import", setter, ImportProblemsRequest{Packages: []ProblemPackage{add}}, http.StatusForbidden)
+	if got := changes(imported(admin, http.StatusOK, add)); !reflect.DeepEqual(got, []interface{}{"created"}) {
+		t.Fatalf("expected the problem to be created, got %v", got)
+	}
+	if got := changes(imported(admin, http.StatusOK, add)); len(got) != 0 {
+		t.Fatalf("expected importing again to change nothing, got %v", got)
+	}
+
+	problem, err := c.server.store.GetProblemBySlug(context.Background(), "add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != ProblemDraft || problem.AuthorID == nil {
+		t.Fatalf("expected a draft by alice, got %+v", problem)
+	}
+	exported, err := exportPackage(context.Background(), c.server.store, problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exported.ReferenceSolutions, add.ReferenceSolutions) || len(exported.TestCases) != 2 || exported.Prompt != add.Prompt {
+		t.Fatalf("exportPackage returned %+v", exported)
+	}
+
+	add.Prompt = "Add up a and b"
+	add.TestCases[1].IO.Output = 5
+	if got := changes(imported(admin, http.StatusOK, add)); !reflect.DeepEqual(got, []interface{}{"problem", "test_cases"}) {
+		t.Fatalf("expected the prompt and test cases to change, got %v", got)
+	}
+	testCases := c.call("GET", "/testcases/"+strconv.Itoa(problem.ProblemID), admin, nil, http.StatusOK)["items"].([]interface{})
+	if flag, _ := testCases[1].(map[string]interface{})["flag"].(string); flag == "" {
+		t.Fatalf("expected the wrong test case to be flagged, got %v", testCases)
+	}
+
+	// Published problems only take packages their reference solutions agree with, and a package
+	// that is refused takes the others in the same import down with it
+	add.TestCases[1].IO.Output = 4
+	imported(admin, http.StatusOK, add)
+	path := "/problems/" + strconv.Itoa(problem.ProblemID)
+	c.call("POST", path+"/review", admin, nil, http.StatusOK)
+	c.call("POST", path+"/publish", admin, nil, http.StatusOK)
+
+	other := add
+	other.Slug, other.ProblemName = "add-again", "Add again"
+	add.TestCases = append(add.TestCases, PackageTestCase{IO: IO{Input: map[string]interface{}{"a": 3, "b": 3}, Output: 7}})
+	imported(admin, http.StatusConflict, other, add)
+	if _, err := c.server.store.GetProblemBySlug(context.Background(), "add-again"); !hasCode(err, CodeNotFound) {
+		t.Fatalf("expected add-again not to be imported, got %v", err)
+	}
+
+	// A package without reference solutions can't vouch for its test cases
+	unchecked := add
+	unchecked.TestCases = add.TestCases[:2]
+	unchecked.ReferenceSolutions = nil
+	imported(admin, http.StatusConflict, unchecked)
+	if solutions, _ := c.server.store.GetReferenceSolutions(context.Background(), problem.ProblemID); len(solutions) != 1 {
+		t.Fatalf("expected the problem to keep its reference solution, got %+v", solutions)
+	}
+
+	add.Format = packageFormat + 1
+	imported(admin, http.StatusBadRequest, add)
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Cursor pagination for the list endpoints. A list is ordered by one of its sort keys with the
+ * row's ID as a tie breaker, and a cursor is the sort key and ID of the last row of the previous
+ * page. Unlike offsets, pages stay stable while rows are being added.
+ */
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+/* One page of a list. NextCursor asks for the page after it and is null on the last page */
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type PageRequest struct {
+	Limit int    // 0 for defaultPageLimit
+	Sort  string // one of the list's sort keys, "" for "id"
+	Desc  bool
+	After *Cursor // nil for the first page
+}
+
+func (p PageRequest) limit() int {
+	if p.Limit <= 0 {
+		return defaultPageLimit
+	}
+	if p.Limit > maxPageLimit {
+		return maxPageLimit
+	}
+	return p.Limit
+}
+
+func (p PageRequest) sortKey() string {
+	if p.Sort == "" {
+		return "id"
+	}
+	return p.Sort
+}
+
+/* Where a page ended. Clients only ever see it encoded, see encodeCursor */
+type Cursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  string `json:"k"` // the row's sort key, see formatSortValue
+	ID   int    `json:"id"`
+}
+
+func encodeCursor(c *Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(Cursor)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+/* One way to order a list: the expression Postgres sorts by, and the same value in Go */
+type sortKey[T any] struct {
+	column string
+	cast   string               // Postgres type a cursor's key is compared as
+	value  func(*T) interface{} // an int, string or time.Time
+}
+
+/*
+ * The sort keys of one list, besides "id" which every list has. Strings are compared bytewise
+ * (COLLATE "C") so that Postgres and the in-memory store agree on the order.
+ */
+type sortKeys[T any] struct {
+	idColumn string
+	id       func(*T) int
+	keys     map[string]sortKey[T]
+}
+
+func (o sortKeys[T]) key(name string) (sortKey[T], error) {
+	if name == "id" {
+		return sortKey[T]{column: o.idColumn, cast: "bigint", value: func(row *T) interface{} { return o.id(row) }}, nil
+	}
+	if key, ok := o.keys[name]; ok {
+		return key, nil
+	}
+	return sortKey[T]{}, fmt.Errorf("unknown sort key %q", name)
+}
+
+/* Parses a cursor's key as the sort key `name` */
+func (o sortKeys[T]) parseKey(name, s string) (interface{}, error) {
+	key, err := o.key(name)
+	if err != nil {
+		return nil, err
+	}
+	return key.parse(s)
+}
+
+/* Bits of the Postgres integer types, so a key that parses also casts */
+var castBits = map[string]int{"smallint": 16, "integer": 32, "bigint": 64}
+
+/* Parses what formatSortValue wrote back into the key's type */
+func (k sortKey[T]) parse(s string) (interface{}, error) {
+	switch k.value(new(T)).(type) {
+	case time.Time:
+		return time.Parse(time.RFC3339Nano, s)
+	case int:
+		n, err := strconv.ParseInt(s, 10, castBits[k.cast])
+		return int(n), err
+	}
+	return s, nil
+}
+
+/* Adds the page's cursor to `f` and returns the ORDER BY and LIMIT that follow the WHERE clause */
+func (o sortKeys[T]) sql(f *queryFilter, page PageRequest) (string, error) {
+	key, err := o.key(page.sortKey())
+	if err != nil {
+		return "", err
+	}
+
+	direction, after := "ASC", ">"
+	if page.Desc {
+		direction, after = "DESC", "<"
+	}
+
+	if page.After != nil {
+		f.add(fmt.Sprintf("(%s, %s) %s (?::%s, ?)", key.column, o.idColumn, after, key.cast), page.After.Key, page.After.ID)
+	}
+
+	// One row more than asked for, to know whether there is a next page
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s LIMIT %d", key.column, direction, o.idColumn, direction, page.limit()+1), nil
+}
+
+/* Sorts and pages through every row of a list in memory, the way sql does in Postgres */
+func (o sortKeys[T]) paginate(rows []*T, page PageRequest) (*Page[*T], error) {
+	key, err := o.key(page.sortKey())
+	if err != nil {
+		return nil, err
+	}
+
+	compare := func(a *T, value interface{}, id int) int {
+		if c := compareSortValues(key.value(a), value); c != 0 {
+			return c
+		}
+		return o.id(a) - id
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		c := compare(rows[i], key.value(rows[j]), o.id(rows[j]))
+		if page.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	if page.After != nil {
+		after, err := key.parse(page.After.Key)
+		if err != nil {
+			return nil, err
+		}
+		start := sort.Search(len(rows), func(i int) bool {
+			c := compare(rows[i], after, page.After.ID)
+			if page.Desc {
+				return c < 0
+			}
+			return c > 0
+		})
+		rows = rows[start:]
+	}
+
+	if len(rows) > page.limit()+1 {
+		rows = rows[:page.limit()+1]
+	}
+	return o.page(rows, page)
+}
+
+/* Turns up to limit+1 rows into a page, the extra row only telling that there is a next one */
+func (o sortKeys[T]) page(rows []*T, page PageRequest) (*Page[*T], error) {
+	key, err := o.key(page.sortKey())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Page[*T]{Items: rows}
+	if len(rows) > page.limit() {
+		result.Items = rows[:page.limit()]
+		last := result.Items[len(result.Items)-1]
+		next := encodeCursor(&Cursor{Sort: page.sortKey(), Desc: page.Desc, Key: formatSortValue(key.value(last)), ID: o.id(last)})
+		result.NextCursor = &next
+	}
+	return result, nil
+}
+
+func formatSortValue(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case int:
+		return strconv.Itoa(v)
+	}
+	return fmt.Sprint(v)
+}
+
+func compareSortValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case int:
+		return a - b.(int)
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	panic(fmt.Sprintf("pagination: can't compare %T", a))
+}
+
+/* WHERE conditions and their arguments. Each ? in a condition becomes the next $n */
+type queryFilter struct {
+	conds []string
+	args  []interface{}
+}
+
+func (f *queryFilter) add(cond string, args ...interface{}) {
+	for _, arg := range args {
+		f.args = append(f.args, arg)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(f.args)), 1)
+	}
+	f.conds = append(f.conds, cond)
+}
+
+func (f *queryFilter) where() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conds, " AND ")
+}
+
+/* What each list can be filtered by. The zero value of a field matches everything */
+type ProblemFilter struct {
+	Difficulty int
+	Tag        string // slug
+	SolvedBy   int    // only problems this account has an accepted submission for
+	UnsolvedBy int    // only problems this account has no accepted submission for
+	Statuses   []string
+	AuthorID   int
+	PageRequest
+}
+
+type TestCaseFilter struct {
+	ProblemID int
+	Kinds     []string
+	PageRequest
+}
+
+type SubmissionFilter struct {
+	UserID    int
+	ProblemID int
+	Language  int
+	Verdict   string
+	From, To  time.Time // submitted in [From, To)
+	PageRequest
+}
+
+var (
+	accountOrder = sortKeys[Account]{
+		idColumn: "user_id",
+		id:       func(a *Account) int { return a.UserID },
+		keys: map[string]sortKey[Account]{
+			"username": {`LOWER(COALESCE(username, '')) COLLATE "C"`, "text", func(a *Account) interface{} { return strings.ToLower(a.Username) }},
+		},
+	}
+
+	problemOrder = sortKeys[Problem]{
+		idColumn: "problem_id",
+		id:       func(p *Problem) int { return p.ProblemID },
+		keys: map[string]sortKey[Problem]{
+			"name":       {`COALESCE(problem_name, '') COLLATE "C"`, "text", func(p *Problem) interface{} { return p.ProblemName }},
+			"difficulty": {"COALESCE(difficulty, 0)", "smallint", func(p *Problem) interface{} { return int(p.Difficulty) }},
+		},
+	}
+
+	testCaseOrder = sortKeys[TestCase]{
+		idColumn: "test_case_id",
+		id:       func(tc *TestCase) int { return tc.TestCaseID },
+	}
+
+	submissionOrder = sortKeys[Submission]{
+		idColumn: "submission_id",
+		id:       func(s *Submission) int { return s.SubmissionID },
+		keys: map[string]sortKey[Submission]{
+			"submitted_at": {"submitted_at", "timestamp", func(s *Submission) interface{} { return s.SubmittedAt }},
+			"runtime_ms":   {"COALESCE(runtime_ms, 0)", "integer", func(s *Submission) interface{} { return s.RuntimeMs }},
+		},
+	}
+)
+
+/* Query parameters every list endpoint takes */
+type PageQuery struct {
+	Limit  int    `json:"limit,omitempty" validate:"min=1,max=100"`
+	Cursor string `json:"cursor,omitempty"`
+	Order  string `json:"order,omitempty" validate:"oneof=asc desc"`
+}
+
+/* The sort keys of a list, whatever its rows are */
+type listOrder interface {
+	parseKey(name, s string) (interface{}, error)
+}
+
+/*
+ * The page asked for of a list in `order`, sorted by `sort`. A cursor only continues the order
+ * it came from, and its key has to parse as that order's sort key.
+ */
+func (q PageQuery) page(sort string, order listOrder) (PageRequest, error) {
+	page := PageRequest{Limit: q.Limit, Sort: sort, Desc: q.Order == "desc"}
+	if q.Cursor == "" {
+		return page, nil
+	}
+
+	cursor, err := decodeCursor(q.Cursor)
+	if err != nil || cursor.Sort != page.sortKey() || cursor.Desc != page.Desc {
+		return page, ValidationErrors{"cursor": "is not a cursor for this sort order"}
+	}
+	if _, err := order.parseKey(cursor.Sort, cursor.Key); err != nil {
+		return page, ValidationErrors{"cursor": "is not a cursor for this list"}
+	}
+	page.After = cursor
+	return page, nil
+}
+
+type ListAccountsQuery struct {
+	PageQuery
+	Sort string `json:"sort,omitempty" validate:"oneof=id username"`
+}
+
+type ListProblemsQuery struct {
+	PageQuery
+	Sort       string `json:"sort,omitempty" validate:"oneof=id name difficulty"`
+	Difficulty int    `json:"difficulty,omitempty" validate:"difficulty"`
+	Tag        string `json:"tag,omitempty"`
+	Solved     *bool  `json:"solved,omitempty"` // by the caller, who has to be signed in
+}
+
+/* A setter's own drafts and problems in review, or everyone's for admins */
+type ListDraftsQuery struct {
+	PageQuery
+	Sort   string `json:"sort,omitempty" validate:"oneof=id name difficulty"`
+	Status string `json:"status,omitempty" validate:"oneof=draft in_review"`
+}
+
+type ListTestCasesQuery struct {
+	PageQuery
+}
+
+type ListSubmissionsQuery struct {
+	PageQuery
+	Sort      string    `json:"sort,omitempty" validate:"oneof=id submitted_at runtime_ms"`
+	UserID    int       `json:"user_id,omitempty"`
+	ProblemID int       `json:"problem_id,omitempty"`
+	Language  int       `json:"language,omitempty"`
+	Verdict   string    `json:"verdict,omitempty" validate:"oneof=pending accepted wrong_answer runtime_error compile_error time_limit_exceeded"`
+	From      time.Time `json:"from,omitempty"`
+	To        time.Time `json:"to,omitempty"`
+}
+
+func (q *ListSubmissionsQuery) Validate() error {
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return ValidationErrors{"to": "must be after from"}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Buckets that have been full for this long are forgotten */
+const idleBucketTTL = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+/* In-memory token buckets keyed by an arbitrary string (an account id or an IP) */
+type RateLimiter struct {
+	rate  float64 // tokens added per second
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(perMinute int, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+/* Takes a token for `key`. When none is left it returns how long until one will be */
+func (l *RateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleBucketTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleBucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+/* A budget applies both per authenticated account and per client IP */
+type rateBudget struct {
+	perAccount *RateLimiter
+	perIP      *RateLimiter
+}
+
+func newRateBudget(accountPerMinute, ipPerMinute, burst int) *rateBudget {
+	return &rateBudget{
+		perAccount: NewRateLimiter(accountPerMinute, burst),
+		perIP:      NewRateLimiter(ipPerMinute, burst),
+	}
+}
+
+type rateBudgets struct {
+	run      *rateBudget
+	runBatch *rateBudget
+	submit   *rateBudget
+	login    *rateBudget
+	mail     *rateBudget  // routes that email a link, see sendAccountTokenEmail
+	mailTo   *RateLimiter // per address, however the email was asked for
+}
+
+func newRateBudgets() *rateBudgets {
+	return &rateBudgets{
+		run:      newRateBudget(30, 20, 10),
+		runBatch: newRateBudget(6, 4, 2),
+		submit:   newRateBudget(10, 10, 5),
+		login:    newRateBudget(10, 10, 5),
+		mail:     newRateBudget(5, 10, 10),
+		mailTo:   NewRateLimiter(1, 3),
+	}
+}
+
+/*
+ * How many proxies in front of the API append to X-Forwarded-For, from TRUST_PROXY. Any
+ * value that isn't a number (e.g. "true") means one.
+ */
+func trustedProxies() int {
+	value := os.Getenv("TRUST_PROXY")
+	if value == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		return n
+	}
+	return 1
+}
+
+/*
+ * The caller's IP. Clients can send any X-Forwarded-For they like and each proxy appends the
+ * address it was called from, so only the last TRUST_PROXY entries can be believed. The one
+ * the outermost trusted proxy added is the caller.
+ */
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	hops := trustedProxies()
+	if hops == 0 {
+		return host
+	}
+
+	addrs := []string{}
+	for _, fwd := range r.Header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(fwd, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	addrs = append(addrs, host)
+
+	return addrs[max(len(addrs)-1-hops, 0)]
+}
+
+func (s *APIServer) rateLimit(f apiFunc, budget *rateBudget) apiFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		now := time.Now()
+
+		ok, wait := budget.perIP.Allow(clientIP(r), now)
+		if ok {
+			if account := currentAccount(r); account != nil {
+				ok, wait = budget.perAccount.Allow(strconv.Itoa(account.UserID), now)
+			}
+		}
+
+		if !ok {
+			return RateLimited(wait, "rate limit exceeded, try again later")
+		}
+
+		return f(w, r)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPIgnoresSpoofedForwardedFor(t *testing.T) {
+	cases := []struct {
+		trustProxy string
+		forwarded  []string
+		want       string
+	}{
+		{"", []string{"6.6.6.6"}, "10.0.0.1"},
+		{"1", nil, "10.0.0.1"},
+		{"1", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"true", []string{"6.6.6.6, 203.0.113.7"}, "203.0.113.7"},
+		{"1", []string{"6.6.6.6", "203.0.113.7"}, "203.0.113.7"},
+		{"2", []string{"6.6.6.6, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{"3", []string{"203.0.113.7"}, "203.0.113.7"},
+	}
+
+	for _, c := range cases {
+		t.Setenv("TRUST_PROXY", c.trustProxy)
+		r := httptest.NewRequest("GET", "/api/run", nil)
+		r.RemoteAddr = "10.0.0.1:4321"
+		for _, fwd := range c.forwarded {
+			r.Header.Add("X-Forwarded-For", fwd)
+		}
+
+		if got := clientIP(r); got != c.want {
+			t.Errorf("TRUST_PROXY=%q, X-Forwarded-For %q: got %s, want %s", c.trustProxy, c.forwarded, got, c.want)
+		}
+	}
+}
+
+/* Asking for password resets can't be used to flood an inbox or the mail server */
+func TestForgotPasswordIsRateLimited(t *testing.T) {
+	c := newSpecClient(t)
+	mailer := NewLogMailer("")
+	c.server.mailer = mailer
+	c.call("POST", "/accounts", "", CreateAccountRequest{Username: "alice", Email: "alice@example.com", Password: testPassword}, http.StatusCreated)
+
+	// The sign up's verification email counts against the address too
+	for i := 0; i < 9; i++ {
+		c.call("POST", "/auth/forgot-password", "", ForgotPasswordRequest{Email: "ALICE@example.com"}, http.StatusAccepted)
+	}
+	if len(mailer.Sent) != 3 {
+		t.Errorf("expected 3 emails to alice, got %d", len(mailer.Sent))
+	}
+
+	c.call("POST", "/auth/forgot-password", "", ForgotPasswordRequest{Email: "bob@example.com"}, http.StatusTooManyRequests)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+/* How many runs verifying test cases has at the judge at once */
+const maxParallelRuns = 8
+
+/*
+ * Reference solutions are what test cases are checked against. A test case that one of them
+ * disagrees with is refused when it is added, and flagged when the solutions change or the
+ * problem is about to be published. Problems are only published with at least one reference
+ * solution and no flagged test cases, so a published problem never expects an output that no
+ * program returns.
+ */
+
+/* The name languageIDs has for `id` */
+func languageName(id int) string {
+	for name, languageID := range languageIDs {
+		if languageID == id {
+			return name
+		}
+	}
+	return strconv.Itoa(id)
+}
+
+/*
+ * Runs every solution against every test case, maxParallelRuns at a time. Returns why some
+ * solution disagrees, keyed by test case id, for the test cases that have one and nothing for
+ * the rest. When several solutions disagree the flag is the first one's. The first error, or ctx
+ * ending, stops the runs still waiting.
+ */
+func (s *APIServer) verifyTestCases(ctx context.Context, problem *Problem, solutions []*ReferenceSolution, tests []*TestCase) (map[int]string, error) {
+	programs := make([]string, len(solutions))
+	for i, solution := range solutions {
+		var err error
+		if programs[i], err = buildProgram(solution.LanguageID, solution.SourceCode, problem.FunctionName); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		flags     = map[int]string{}
+		flaggedBy = map[int]int{} // test case id -> index of the solution that flagged it
+		firstErr  error
+		wg        sync.WaitGroup
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	slots := make(chan struct{}, maxParallelRuns)
+runs:
+	for i, solution := range solutions {
+		for _, tc := range tests {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				break runs
+			}
+
+			wg.Add(1)
+			go func(i int, solution *ReferenceSolution, tc *TestCase) {
+				defer func() { <-slots; wg.Done() }()
+
+				tr, err := runTestCase(ctx, s.executor, programs[i], solution.LanguageID, tc)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err != nil:
+					fail(err)
+				case !tr.Passed:
+					if by, ok := flaggedBy[tc.TestCaseID]; !ok || i < by {
+						flaggedBy[tc.TestCaseID] = i
+						flags[tc.TestCaseID] = fmt.Sprintf("the %s reference solution %s", languageName(solution.LanguageID), tr.failure())
+					}
+				}
+			}(i, solution, tc)
+		}
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return flags, nil
+}
+
+/* Verifies every test case of the problem against `solutions`, which replace the stored ones */
+func (s *APIServer) verifySuite(ctx context.Context, problem *Problem, solutions []*ReferenceSolution) (map[int]string, error) {
+	tests, err := s.store.GetTestCasesByProblemID(ctx, problem.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.verifyTestCases(ctx, problem, solutions, tests)
+}
+
+/* The flag of the test case with the lowest id, for error messages */
+func firstFlag(flags map[int]string) string {
+	ids := make([]int, 0, len(flags))
+	for id := range flags {
+		ids = append(ids, id)
+	}
+	first := slices.Min(ids)
+	return fmt.Sprintf("test case %d: %s", first, flags[first])
+}
+
+/* What publishing needs, see the top of this file */
+func (s *APIServer) checkPublishable(ctx context.Context, problem *Problem) error {
+	solutions, err := s.store.GetReferenceSolutions(ctx, problem.ProblemID)
+	if err != nil {
+		return err
+	}
+	if len(solutions) == 0 {
+		return Conflict("problem %d has no reference solution", problem.ProblemID)
+	}
+
+	tests, err := s.store.GetTestCasesByProblemID(ctx, problem.ProblemID)
+	if err != nil {
+		return err
+	}
+	if len(tests) == 0 {
+		return Conflict("problem %d has no test cases", problem.ProblemID)
+	}
+
+	flags, err := s.verifyTestCases(ctx, problem, solutions, tests)
+	if err != nil {
+		return err
+	}
+	if err := s.store.SetTestCaseFlags(ctx, problem.ProblemID, flags); err != nil {
+		return err
+	}
+	if len(flags) > 0 {
+		return Conflict("problem %d has %d test cases its reference solutions disagree with, see their flags", problem.ProblemID, len(flags))
+	}
+	return nil
+}
+
+/* Refuses a new test case that any of the problem's reference solutions disagrees with */
+func (s *APIServer) checkNewTestCase(ctx context.Context, problem *Problem, tc *TestCase) error {
+	solutions, err := s.store.GetReferenceSolutions(ctx, problem.ProblemID)
+	if err != nil {
+		return err
+	}
+
+	flags, err := s.verifyTestCases(ctx, problem, solutions, []*TestCase{tc})
+	if err != nil {
+		return err
+	}
+	if flag, ok := flags[tc.TestCaseID]; ok {
+		return ValidationErrors{"io.output": flag}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReferenceSolutionsCheckTestCases(t *testing.T) {
+	c := newSpecClient(t)
+	admin := signUp(c, "alice", RoleAdmin)
+	author := signUp(c, "bob", RoleProblemSetter)
+
+	newProblem := func(name string, cases ...CreateTestCaseRequest) string {
+		t.Helper()
+		problem := c.call("POST", "/problems", author, CreateProblemRequest{
+			ProblemName:  name,
+			Prompt:       "Add a and b",
+			StarterCode:  "def add(a, b):",
+			FunctionName: "add",
+			Difficulty:   1,
+			TestCases:    cases,
+		}, http.StatusCreated)
+		return fmt.Sprintf("/problems/%d", int(problem["problem_id"].(float64)))
+	}
+	sum := func(a, b, want int) CreateTestCaseRequest {
+		return CreateTestCaseRequest{IO: IO{Input: map[string]interface{}{"a": a, "b": b}, Output: want}}
+	}
+	right := SetReferenceSolutionRequest{SourceCode: "def add(a, b): return a + b"}
+	wrong := SetReferenceSolutionRequest{SourceCode: "def add(a, b): return a * b"}
+
+	// Test cases written before the solution are flagged when it disagrees with them
+	path := newProblem("Flagged", sum(1, 2, 3), sum(2, 2, 5))
+	c.call("PUT", path+"/solutions/71", author, right, http.StatusOK)
+	testCases := c.call("GET", "/testcases"+strings.TrimPrefix(path, "/problems"), author, nil, http.StatusOK)["items"].([]interface{})
+	if len(testCases) != 2 || testCases[0].(map[string]interface{})["flag"] != nil {
+		t.Fatalf("expected only the second test case to be flagged, got %v", testCases)
+	}
+	if flag, _ := testCases[1].(map[string]interface{})["flag"].(string); flag != "the python3 reference solution returned 4, expected 5" {
+		t.Fatalf("unexpected flag %q", flag)
+	}
+	c.call("POST", path+"/review", author, nil, http.StatusOK)
+	c.call("POST", path+"/publish", admin, nil, http.StatusConflict)
+
+	// Test cases written after it are refused
+	path = newProblem("Checked", sum(1, 2, 3))
+	c.call("PUT", path+"/solutions/71", author, right, http.StatusOK)
+	id := strings.TrimPrefix(path, "/problems/")
+	added := CreateTestCaseRequest{IO: IO{Input: map[string]interface{}{"a": 2, "b": 2}, Output: 5}}
+	fmt.Sscan(id, &added.ProblemID)
+	c.call("POST", "/testcases", author, added, http.StatusBadRequest)
+	added.IO.Output = 4
+	c.call("POST", "/testcases", author, added, http.StatusCreated)
+
+	// and once the problem is published, so are solutions that disagree
+	c.call("POST", path+"/review", author, nil, http.StatusOK)
+	c.call("POST", path+"/publish", admin, nil, http.StatusOK)
+	c.call("PUT", path+"/solutions/71", admin, wrong, http.StatusBadRequest)
+	c.call("PUT", path+"/solutions/71", admin, right, http.StatusOK)
+}
+
+/* fakeExecutor, taking a while like judge0 does and counting how many runs it has at once */
+type slowExecutor struct {
+	fakeExecutor
+	delay time.Duration
+
+	mu            sync.Mutex
+	running, most int
+}
+
+func (e *slowExecutor) Execute(ctx context.Context, req *Judge0Submission) (*ExecResult, error) {
+	e.mu.Lock()
+	e.running++
+	e.most = max(e.most, e.running)
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.running--
+		e.mu.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(e.delay):
+	}
+	return e.fakeExecutor.Execute(ctx, req)
+}
+
+func TestVerifyTestCasesRunsInParallel(t *testing.T) {
+	s := newTestServer()
+	executor := &slowExecutor{delay: 20 * time.Millisecond}
+	s.executor = executor
+
+	problem := &Problem{FunctionName: "add"}
+	solutions := []*ReferenceSolution{
+		{LanguageID: languageIDs["python3"], SourceCode: "def add(a, b): return a + b"},
+		{LanguageID: languageIDs["javascript"], SourceCode: "function add(a, b) { return null }"},
+	}
+	tests := []*TestCase{}
+	for i := 0; i < 3*maxParallelRuns; i++ {
+		output := i + i
+		if i%2 == 1 {
+			output++
+		}
+		tests = append(tests, &TestCase{TestCaseID: i, IO: IO{Input: map[string]interface{}{"a": i, "b": i}, Output: output}})
+	}
+
+	flags, err := s.verifyTestCases(context.Background(), problem, solutions, tests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flags) != len(tests) {
+		t.Fatalf("expected every test case to be flagged by one solution or the other, got %v", flags)
+	}
+	for id, flag := range flags {
+		if python := strings.HasPrefix(flag, "the python3 "); python != (id%2 == 1) {
+			t.Errorf("expected test case %d to have the first disagreeing solution's flag, got %q", id, flag)
+		}
+	}
+	if executor.most != maxParallelRuns {
+		t.Errorf("expected %d runs at once, got %d", maxParallelRuns, executor.most)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	executor.delay = time.Hour
+	if _, err := s.verifyTestCases(ctx, problem, solutions, tests); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected verifying to stop with its context, got %v", err)
+	}
+}
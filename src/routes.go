@@ -1,116 +1,643 @@
 package main
 
 import (
-	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 )
 
-func (s *APIServer) handleAccount(w http.ResponseWriter, r *http.Request) error {
+/*
+ * One method on one path. The table in routes() is the only place routes are declared: Run
+ * registers it with the router and openapi.json is generated from it.
+ */
+type route struct {
+	method  string
+	path    string // relative to /api, mux syntax
+	name    string // operationId in the OpenAPI document
+	tag     string
+	summary string
+	status  int // status of a successful response
+	handler apiFunc
+	access  accessRule  // nil for public routes
+	limit   *rateBudget // nil when not rate limited
 
-	switch method := r.Method; method {
-	case "GET":
-		return s.handleGetAccount(w, r)
-	case "POST":
-		return s.handleCreateAccount(w, r)
-	}
-
-	return fmt.Errorf("Method not supported %s", r.Method)
+	/*
+	 * Sample values of the request body and of what a successful response writes, for the
+	 * OpenAPI document. Their types are all that matters. nil means there is no body.
+	 */
+	query    interface{} // struct the handler reads the query string into, see decodeQuery
+	request  interface{}
+	ndjson   bool // the request, an array, may also be sent as newline delimited JSON
+	response interface{}
+	also     map[int]interface{} // other successful statuses and their bodies
 }
 
-func (s *APIServer) handleAccountByID(w http.ResponseWriter, r *http.Request) error {
-	switch method := r.Method; method {
-	case "GET":
-		return s.handleGetAccountByID(w, r)
-	case "DELETE":
-		return s.handleDeleteAccount(w, r)
-	}
+/* A body that is one of several types, e.g. whichever view of an account the caller may see */
+type anyOf []interface{}
 
-	return fmt.Errorf("Method not supported %s", r.Method)
-}
+var accountViews = anyOf{PublicAccount{}, SelfAccount{}, AdminAccount{}}
 
-func (s *APIServer) handleProblem(w http.ResponseWriter, r *http.Request) error {
-	switch method := r.Method; method {
-	case "GET":
-		return s.handleGetProblems(w, r)
-	case "POST":
-		return s.handleCreateProblem(w, r)
-	}
+/* Numeric ids only, so /problems/search and friends can't be mistaken for an id */
+const idVar = "{id:[0-9]+}"
 
-	return fmt.Errorf("Method not supported %s", r.Method)
-}
+func (s *APIServer) routes() []route {
+	return []route{
+		/* Run code */
+		{
+			method:   "POST",
+			path:     "/run",
+			name:     "runCode",
+			tag:      "run",
+			summary:  "Run code against a problem's test cases",
+			status:   http.StatusOK,
+			request:  ExecReq{},
+			response: Result{},
+			handler:  s.handleRunCode,
+			limit:    s.limits.run,
+		},
+		{
+			method:   "POST",
+			path:     "/run/batch",
+			name:     "runCodeBatch",
+			tag:      "run",
+			summary:  "Run several programs in one request",
+			status:   http.StatusOK,
+			request:  ExecBatchReq{},
+			response: []*Result{},
+			handler:  s.handleRunBatchCode,
+			limit:    s.limits.runBatch,
+		},
 
-func (s *APIServer) handleProblemByID(w http.ResponseWriter, r *http.Request) error {
-	if method := r.Method; method == "GET" {
-		return s.handleGetProblemByID(w, r)
-	}
+		/* Auth */
+		{
+			method:   "POST",
+			path:     "/auth/login",
+			name:     "login",
+			tag:      "auth",
+			summary:  "Sign in with a username and password",
+			status:   http.StatusOK,
+			request:  LoginRequest{},
+			response: TokenResponse{},
+			handler:  s.handleLoginRequest,
+			limit:    s.limits.login,
+		},
+		{
+			method:   "POST",
+			path:     "/auth/refresh",
+			name:     "refreshTokens",
+			tag:      "auth",
+			summary:  "Exchange a refresh token for new tokens",
+			status:   http.StatusOK,
+			request:  RefreshRequest{},
+			response: TokenResponse{},
+			handler:  s.handleRefreshRequest,
+		},
+		{
+			method:  "POST",
+			path:    "/auth/logout",
+			name:    "logout",
+			tag:     "auth",
+			summary: "Revoke the session a refresh token belongs to",
+			status:  http.StatusNoContent,
+			request: RefreshRequest{},
+			handler: s.handleLogoutRequest,
+		},
+		{
+			method:  "POST",
+			path:    "/auth/verify",
+			name:    "verifyEmail",
+			tag:     "auth",
+			summary: "Verify an email address with an emailed token",
+			status:  http.StatusNoContent,
+			request: VerifyEmailRequest{},
+			handler: s.handleVerifyEmailRequest,
+		},
+		{
+			method:  "POST",
+			path:    "/auth/forgot-password",
+			name:    "forgotPassword",
+			tag:     "auth",
+			summary: "Email a password reset link",
+			status:  http.StatusAccepted,
+			request: ForgotPasswordRequest{},
+			handler: s.handleForgotPasswordRequest,
+			limit:   s.limits.mail,
+		},
+		{
+			method:  "POST",
+			path:    "/auth/reset-password",
+			name:    "resetPassword",
+			tag:     "auth",
+			summary: "Set a new password with an emailed token",
+			status:  http.StatusNoContent,
+			request: ResetPasswordRequest{},
+			handler: s.handleResetPasswordRequest,
+		},
+		{
+			method:   "GET",
+			path:     "/auth/oauth/{provider}/start",
+			name:     "startOAuth",
+			tag:      "auth",
+			summary:  "Start signing in or linking with an OAuth provider",
+			status:   http.StatusOK,
+			response: OAuthStartResponse{},
+			handler:  s.handleOAuthStartRequest,
+		},
+		{
+			method:   "POST",
+			path:     "/auth/oauth/{provider}/callback",
+			name:     "completeOAuth",
+			tag:      "auth",
+			summary:  "Complete an OAuth flow with the code and state from the provider",
+			status:   http.StatusOK,
+			request:  OAuthCallbackRequest{},
+			response: TokenResponse{},
+			also:     map[int]interface{}{http.StatusCreated: anyOf{TokenResponse{}, AccountIdentity{}}},
+			handler:  s.handleOAuthCallbackRequest,
+		},
 
-	return fmt.Errorf("Method not supported %s", r.Method)
-}
+		/* Accounts */
+		{
+			method:   "GET",
+			path:     "/accounts",
+			name:     "listAccounts",
+			tag:      "accounts",
+			summary:  "List accounts",
+			status:   http.StatusOK,
+			query:    ListAccountsQuery{},
+			response: Page[*AdminAccount]{},
+			handler:  s.handleGetAccount,
+			access:   hasRole(RoleAdmin),
+		},
+		{
+			method:   "POST",
+			path:     "/accounts",
+			name:     "createAccount",
+			tag:      "accounts",
+			summary:  "Sign up",
+			status:   http.StatusCreated,
+			request:  CreateAccountRequest{},
+			response: SelfAccount{},
+			handler:  s.handleCreateAccount,
+			limit:    s.limits.mail,
+		},
+		{
+			method:   "GET",
+			path:     "/accounts/" + idVar,
+			name:     "getAccount",
+			tag:      "accounts",
+			summary:  "Get an account",
+			status:   http.StatusOK,
+			response: accountViews,
+			handler:  s.handleGetAccountByID,
+		},
+		{
+			method:   "PATCH",
+			path:     "/accounts/" + idVar,
+			name:     "updateAccount",
+			tag:      "accounts",
+			summary:  "Update an account's profile",
+			status:   http.StatusOK,
+			request:  UpdateAccountRequest{},
+			response: accountViews,
+			handler:  s.handleUpdateAccount,
+			access:   ownerOrAdmin,
+			limit:    s.limits.mail,
+		},
+		{
+			method:  "DELETE",
+			path:    "/accounts/" + idVar,
+			name:    "deleteAccount",
+			tag:     "accounts",
+			summary: "Delete an account",
+			status:  http.StatusNoContent,
+			handler: s.handleDeleteAccount,
+			access:  ownerOrAdmin,
+		},
+		{
+			method:  "POST",
+			path:    "/accounts/" + idVar + "/password",
+			name:    "changePassword",
+			tag:     "accounts",
+			summary: "Change your password",
+			status:  http.StatusNoContent,
+			request: ChangePasswordRequest{},
+			handler: s.handleChangePassword,
+			access:  owner,
+		},
+		{
+			method:   "GET",
+			path:     "/accounts/" + idVar + "/identities",
+			name:     "listIdentities",
+			tag:      "accounts",
+			summary:  "List linked OAuth identities",
+			status:   http.StatusOK,
+			response: []*AccountIdentity{},
+			handler:  s.handleGetAccountIdentities,
+			access:   ownerOrAdmin,
+		},
+		{
+			method:  "DELETE",
+			path:    "/accounts/" + idVar + "/identities/{provider}",
+			name:    "unlinkIdentity",
+			tag:     "accounts",
+			summary: "Unlink an OAuth identity",
+			status:  http.StatusNoContent,
+			handler: s.handleDeleteAccountIdentity,
+			access:  owner,
+		},
+		{
+			method:   "GET",
+			path:     "/accounts/" + idVar + "/sessions",
+			name:     "listSessions",
+			tag:      "accounts",
+			summary:  "List active sessions",
+			status:   http.StatusOK,
+			response: []*Session{},
+			handler:  s.handleGetSessions,
+			access:   owner,
+		},
+		{
+			method:  "DELETE",
+			path:    "/accounts/" + idVar + "/sessions/{session_id}",
+			name:    "revokeSession",
+			tag:     "accounts",
+			summary: "Sign out a session",
+			status:  http.StatusNoContent,
+			handler: s.handleRevokeSession,
+			access:  owner,
+		},
+		{
+			method:   "PUT",
+			path:     "/accounts/" + idVar + "/role",
+			name:     "updateRole",
+			tag:      "accounts",
+			summary:  "Change an account's role",
+			status:   http.StatusOK,
+			request:  UpdateRoleRequest{},
+			response: map[string]string{},
+			handler:  s.handleUpdateAccountRole,
+			access:   hasRole(RoleAdmin),
+		},
 
-func (s *APIServer) handleProblemByName(w http.ResponseWriter, r *http.Request) error {
-	if method := r.Method; method == "GET" {
-		return s.handleGetProblemByName(w, r)
-	}
+		/* Problems */
+		{
+			method:   "GET",
+			path:     "/problems",
+			name:     "listProblems",
+			tag:      "problems",
+			summary:  "List published problems",
+			status:   http.StatusOK,
+			query:    ListProblemsQuery{},
+			response: Page[*Problem]{},
+			handler:  s.handleGetProblems,
+		},
+		{
+			method:   "POST",
+			path:     "/problems",
+			name:     "createProblem",
+			tag:      "problems",
+			summary:  "Create a draft problem, optionally with its test cases",
+			status:   http.StatusCreated,
+			request:  CreateProblemRequest{},
+			response: Problem{},
+			handler:  s.handleCreateProblem,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "GET",
+			path:     "/problems/" + idVar,
+			name:     "getProblem",
+			tag:      "problems",
+			summary:  "Get a problem",
+			status:   http.StatusOK,
+			response: Problem{},
+			handler:  s.handleGetProblemByID,
+		},
+		{
+			method:   "GET",
+			path:     "/problems/search",
+			name:     "searchProblems",
+			tag:      "problems",
+			summary:  "Search the names and prompts of published problems, best matches first",
+			status:   http.StatusOK,
+			query:    SearchProblemsQuery{},
+			response: []*ProblemSearchResult{},
+			handler:  s.handleSearchProblems,
+		},
+		{
+			method:   "GET",
+			path:     "/problems/slug/{slug}",
+			name:     "getProblemBySlug",
+			tag:      "problems",
+			summary:  "Get a problem by its slug",
+			status:   http.StatusOK,
+			response: Problem{},
+			handler:  s.handleGetProblemBySlug,
+		},
+		{
+			method:   "PUT",
+			path:     "/problems/" + idVar + "/tags",
+			name:     "setProblemTags",
+			tag:      "problems",
+			summary:  "Replace a problem's tags",
+			status:   http.StatusOK,
+			request:  SetProblemTagsRequest{},
+			response: Problem{},
+			handler:  s.handleSetProblemTags,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "GET",
+			path:     "/problems/name/{name}",
+			name:     "getProblemByName",
+			tag:      "problems",
+			summary:  "Get a problem by name",
+			status:   http.StatusOK,
+			response: Problem{},
+			handler:  s.handleGetProblemByName,
+		},
 
-	return fmt.Errorf("Method not supported %s", r.Method)
-}
+		/* Authoring, see workflow.go */
+		{
+			method:   "GET",
+			path:     "/problems/drafts",
+			name:     "listDrafts",
+			tag:      "problems",
+			summary:  "List your drafts and problems in review, or everyone's for admins",
+			status:   http.StatusOK,
+			query:    ListDraftsQuery{},
+			response: Page[*Problem]{},
+			handler:  s.handleGetDrafts,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "PUT",
+			path:     "/problems/" + idVar,
+			name:     "updateProblem",
+			tag:      "problems",
+			summary:  "Edit a problem, making a new revision",
+			status:   http.StatusOK,
+			request:  UpdateProblemRequest{},
+			response: Problem{},
+			handler:  s.handleUpdateProblem,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "GET",
+			path:     "/problems/" + idVar + "/revisions",
+			name:     "listProblemRevisions",
+			tag:      "problems",
+			summary:  "List a problem's revisions, newest first",
+			status:   http.StatusOK,
+			response: []*ProblemRevision{},
+			handler:  s.handleGetProblemRevisions,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "POST",
+			path:     "/problems/" + idVar + "/review",
+			name:     "submitProblemForReview",
+			tag:      "problems",
+			summary:  "Submit a draft for review",
+			status:   http.StatusOK,
+			response: Problem{},
+			handler:  s.handleSubmitProblemForReview,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "POST",
+			path:     "/problems/" + idVar + "/withdraw",
+			name:     "withdrawProblem",
+			tag:      "problems",
+			summary:  "Take a problem out of review and back to draft",
+			status:   http.StatusOK,
+			response: Problem{},
+			handler:  s.handleWithdrawProblem,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "GET",
+			path:     "/problems/" + idVar + "/solutions",
+			name:     "listReferenceSolutions",
+			tag:      "problems",
+			summary:  "List a problem's reference solutions",
+			status:   http.StatusOK,
+			response: []*ReferenceSolution{},
+			handler:  s.handleGetReferenceSolutions,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "PUT",
+			path:     "/problems/" + idVar + "/solutions/{language_id:[0-9]+}",
+			name:     "setReferenceSolution",
+			tag:      "problems",
+			summary:  "Add or replace the reference solution in a language, and check the test cases against it",
+			status:   http.StatusOK,
+			request:  SetReferenceSolutionRequest{},
+			response: ReferenceSolution{},
+			handler:  s.handleSetReferenceSolution,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:  "DELETE",
+			path:    "/problems/" + idVar + "/solutions/{language_id:[0-9]+}",
+			name:    "deleteReferenceSolution",
+			tag:     "problems",
+			summary: "Remove the reference solution in a language",
+			status:  http.StatusNoContent,
+			handler: s.handleDeleteReferenceSolution,
+			access:  hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "POST",
+			path:     "/problems/" + idVar + "/publish",
+			name:     "publishProblem",
+			tag:      "problems",
+			summary:  "Publish a problem once its reference solutions agree with all of its test cases",
+			status:   http.StatusOK,
+			response: Problem{},
+			handler:  s.handlePublishProblem,
+			access:   hasRole(RoleAdmin),
+		},
+		{
+			method:   "POST",
+			path:     "/problems/" + idVar + "/retire",
+			name:     "retireProblem",
+			tag:      "problems",
+			summary:  "Stop taking submissions for a published problem",
+			status:   http.StatusOK,
+			response: Problem{},
+			handler:  s.handleRetireProblem,
+			access:   hasRole(RoleAdmin),
+		},
 
-func (s *APIServer) handleTestCase(w http.ResponseWriter, r *http.Request) error {
-	if method := r.Method; method == "POST" {
-		return s.handleCreateTestCase(w, r)
-	}
+		{
+			method:   "POST",
+			path:     "/admin/problems/import",
+			name:     "importProblems",
+			tag:      "problems",
+			summary:  "Create or update problems from problem packages, all of them or none",
+			status:   http.StatusOK,
+			request:  ImportProblemsRequest{},
+			response: []*ImportResult{},
+			handler:  s.handleImportProblems,
+			access:   hasRole(RoleAdmin),
+		},
 
-	return fmt.Errorf("Method not supported %s", r.Method)
-}
+		/* Tags */
+		{
+			method:   "GET",
+			path:     "/tags",
+			name:     "listTags",
+			tag:      "tags",
+			summary:  "List the tags problems can have",
+			status:   http.StatusOK,
+			response: []*Tag{},
+			handler:  s.handleGetTags,
+		},
+		{
+			method:   "POST",
+			path:     "/tags",
+			name:     "createTag",
+			tag:      "tags",
+			summary:  "Create a tag",
+			status:   http.StatusCreated,
+			request:  CreateTagRequest{},
+			response: Tag{},
+			handler:  s.handleCreateTag,
+			access:   hasRole(RoleAdmin),
+		},
 
-func (s *APIServer) handleTestCaseByProblemID(w http.ResponseWriter, r *http.Request) error {
-	if method := r.Method; method == "GET" {
-		return s.handleGetTestCasesByProblemID(w, r)
-	}
+		/* Test Cases */
+		{
+			method:   "POST",
+			path:     "/testcases",
+			name:     "createTestCase",
+			tag:      "testcases",
+			summary:  "Add a test case to a problem",
+			status:   http.StatusCreated,
+			request:  CreateTestCaseRequest{},
+			response: TestCase{},
+			handler:  s.handleCreateTestCase,
+			access:   hasRole(RoleProblemSetter),
+		},
+		{
+			method:   "GET",
+			path:     "/testcases/" + idVar,
+			name:     "listTestCases",
+			tag:      "testcases",
+			summary:  "List a problem's test cases, hidden ones for admins only",
+			status:   http.StatusOK,
+			query:    ListTestCasesQuery{},
+			response: Page[*TestCase]{},
+			handler:  s.handleGetTestCasesByProblemID,
+		},
+		{
+			method:   "GET",
+			path:     "/testcases/sanity/" + idVar,
+			name:     "listSanityChecks",
+			tag:      "testcases",
+			summary:  "List a problem's sanity checks",
+			status:   http.StatusOK,
+			response: []*TestCase{},
+			handler:  s.handleGetTestCaseSanityChecks,
+		},
+		{
+			method:   "POST",
+			path:     "/problems/" + idVar + "/testcases:bulk",
+			name:     "createTestCases",
+			tag:      "testcases",
+			summary:  "Add up to 1000 test cases to a problem, or replace all of its test cases, at once",
+			status:   http.StatusOK,
+			query:    CreateTestCasesQuery{},
+			request:  []CreateTestCaseRequest{},
+			ndjson:   true,
+			response: CreateTestCasesResult{},
+			handler:  s.handleCreateTestCases,
+			access:   hasRole(RoleProblemSetter),
+		},
 
-	return fmt.Errorf("Method not supported %s", r.Method)
-}
+		/* Submissions */
+		{
+			method:   "GET",
+			path:     "/submissions",
+			name:     "listSubmissions",
+			tag:      "submissions",
+			summary:  "List your submissions, or every submission for admins",
+			status:   http.StatusOK,
+			query:    ListSubmissionsQuery{},
+			response: Page[*Submission]{},
+			handler:  s.handleGetSubmissions,
+			access:   authenticated,
+		},
+		{
+			method:   "POST",
+			path:     "/submissions",
+			name:     "createSubmission",
+			tag:      "submissions",
+			summary:  "Submit a solution",
+			status:   http.StatusCreated,
+			request:  CreateSubmissionRequest{},
+			response: Submission{},
+			handler:  s.handleCreateSubmission,
+			access:   authenticated,
+			limit:    s.limits.submit,
+		},
+		{
+			method:   "GET",
+			path:     "/submissions/" + idVar,
+			name:     "getSubmission",
+			tag:      "submissions",
+			summary:  "Get one of your submissions",
+			status:   http.StatusOK,
+			response: Submission{},
+			handler:  s.handleGetSubmissionByID,
+			access:   authenticated,
+		},
 
-func (s *APIServer) handleTestCaseSanity(w http.ResponseWriter, r *http.Request) error {
-	if method := r.Method; method == "GET" {
-		return s.handleGetTestCaseSanityChecks(w, r)
+		/* Docs */
+		{
+			method:   "GET",
+			path:     "/openapi.json",
+			name:     "getOpenAPI",
+			tag:      "docs",
+			summary:  "This document",
+			status:   http.StatusOK,
+			response: map[string]interface{}{},
+			handler:  s.handleOpenAPI,
+		},
 	}
-
-	return fmt.Errorf("Method not supported %s", r.Method)
 }
 
-func (s *APIServer) handleSubmission(w http.ResponseWriter, r *http.Request) error {
-	switch method := r.Method; method {
-	case "GET":
-		return s.handleGetSubmissions(w, r)
-	case "POST":
-		return s.handleCreateSubmission(w, r)
+/* The route's handler behind its access rule and rate limit, in that order */
+func (s *APIServer) routeHandler(rt route) apiFunc {
+	f := rt.handler
+	if rt.limit != nil {
+		f = s.rateLimit(f, rt.limit)
 	}
-
-	return fmt.Errorf("Method not supported %s", r.Method)
-}
-
-func (s *APIServer) handleSubmissionByID(w http.ResponseWriter, r *http.Request) error {
-	if method := r.Method; method == "GET" {
-		return s.handleGetSubmissionByID(w, r)
+	if rt.access != nil {
+		f = s.authorize(f, rt.access)
 	}
-
-	return fmt.Errorf("Method not supported %s", r.Method)
+	return f
 }
 
-func (s *APIServer) handleRun(w http.ResponseWriter, r *http.Request) error {
-	if method := r.Method; method == "POST" {
-		fmt.Println("Received post request...")
-		return s.handleRunCode(w, r)
+/* Answers any method a path doesn't have a route for */
+func methodNotAllowed(allowed []string) apiFunc {
+	allow := strings.Join(allowed, ", ")
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Allow", allow)
+		return MethodNotAllowed("Method %s is not allowed, use %s", r.Method, allow)
 	}
+}
 
-	return fmt.Errorf("Method not supported %s", r.Method)
+func notFound(w http.ResponseWriter, r *http.Request) error {
+	return NotFound("No route for %s %s", r.Method, r.URL.Path)
 }
 
-func (s *APIServer) handleRunBatch(w http.ResponseWriter, r *http.Request) error {
-	if method := r.Method; method == "POST" {
-		return s.handleRunBatchCode(w, r)
-	}
+var pathVarPattern = regexp.MustCompile(`\{(\w+):[^}]+\}`)
 
-	return fmt.Errorf("Method not supported %s", r.Method)
+/* `path` with mux's variable patterns removed, i.e. as OpenAPI expects it */
+func openAPIPath(path string) string {
+	return pathVarPattern.ReplaceAllString(path, "{$1}")
 }
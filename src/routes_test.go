@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newTestServer() *APIServer {
+	s := NewAPIServer(":0", NewMemoryStore())
+	s.executor = fakeExecutor{}
+	return s
+}
+
+/*
+ * Stands in for judge0. Programs that `return a + b` add up the a and b of their input, and
+ * everything else returns null.
+ */
+type fakeExecutor struct{}
+
+func (fakeExecutor) Execute(ctx context.Context, req *Judge0Submission) (*ExecResult, error) {
+	result := "null"
+	if strings.Contains(req.SourceCode, "return a + b") {
+		input := map[string]float64{}
+		if err := json.Unmarshal([]byte(req.Stdin), &input); err != nil {
+			return nil, err
+		}
+		result = strconv.FormatFloat(input["a"]+input["b"], 'f', -1, 64)
+	}
+
+	res := &ExecResult{Stdout: "\n" + resultMarker + result + "\n"}
+	res.Status.ID = judge0StatusAccepted
+	return res, nil
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestServer().newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/accounts/1", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status 405, got %d", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, PATCH, DELETE" {
+		t.Errorf("expected Allow to list the path's methods, got %q", got)
+	}
+
+	var body ApiError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != CodeMethodNotAllowed {
+		t.Errorf("expected code %s, got %s", CodeMethodNotAllowed, body.Code)
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	cases := []string{"/api/nope", "/api/problems/abc"}
+
+	for _, path := range cases {
+		w := httptest.NewRecorder()
+		newTestServer().newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s: expected a JSON error, got %q", path, got)
+		}
+	}
+}
+
+/* A variable's pattern, like {id:[0-9]+}. Colons outside of variables, as in testcases:bulk, are fine */
+var muxPattern = regexp.MustCompile(`\{[^}]*:`)
+
+func TestOpenAPIListsEveryRoute(t *testing.T) {
+	s := newTestServer()
+	w := httptest.NewRecorder()
+	s.newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var doc openAPIDocument
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rt := range s.routes() {
+		path := openAPIPath(rt.path)
+		if muxPattern.MatchString(path) {
+			t.Errorf("%s: mux pattern left in path", path)
+		}
+
+		op := doc.Paths[path][strings.ToLower(rt.method)]
+		if op == nil {
+			t.Errorf("%s %s missing from the document", rt.method, path)
+			continue
+		}
+		if op.OperationID != rt.name {
+			t.Errorf("%s %s: expected operationId %s, got %s", rt.method, path, rt.name, op.OperationID)
+		}
+		if (rt.access != nil) != (len(op.Security) > 0) {
+			t.Errorf("%s %s: security doesn't match the route's access rule", rt.method, path)
+		}
+	}
+}
@@ -1,9 +1,187 @@
 package main
 
 import (
-// "fmt"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
 )
 
-func runUserCodeWithInput() bool {
-	return true
+/* Printed by the harness in front of the JSON encoded return value of the user's function */
+const resultMarker = "__ALGODUELS_RESULT__"
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+/*
+ * Harnesses read the test input as a JSON object on stdin and call the user's function with
+ * the values in the order of the function's declared parameters, so test inputs can be keyed
+ * by parameter name without relying on map ordering.
+ */
+var harnesses = map[int]string{
+	languageIDs["javascript"]: `
+;(() => {
+	const __input = JSON.parse(require("fs").readFileSync(0, "utf8"));
+	const __fn = {{function}};
+	const __source = __fn.toString();
+	// x => ... has no parentheses, and the first ( of its body isn't its parameter list
+	const __arrow = __source.match(/^(?:async\s+)?([A-Za-z_$][\w$]*)\s*=>/);
+	const __list = __arrow ? __arrow[1] : (__source.match(/^[^(]*\(([^)]*)\)/) || [])[1];
+	if (__list === undefined) {
+		throw new Error("can't read the parameters of {{function}}, declare it with a parameter list");
+	}
+	const __params = __list.split(",").map((p) => p.split("=")[0].trim()).filter(Boolean);
+	const __result = __fn(...__params.map((p) => __input[p]));
+	console.log("\n` + resultMarker + `" + JSON.stringify(__result));
+})();
+`,
+	languageIDs["python3"]: `
+
+import sys as __sys, json as __json, inspect as __inspect
+__input = __json.loads(__sys.stdin.read())
+__result = {{function}}(*[__input[__p] for __p in __inspect.signature({{function}}).parameters])
+print()
+print("` + resultMarker + `" + __json.dumps(__result))
+`,
+}
+
+/* Appends the language's harness to the user's source code */
+func buildProgram(languageID int, sourceCode, functionName string) (string, error) {
+	harness, ok := harnesses[languageID]
+	if !ok {
+		return "", BadRequest("Unsupported language %d", languageID)
+	}
+
+	if !identifierPattern.MatchString(functionName) {
+		return "", fmt.Errorf("Invalid function name %q", functionName)
+	}
+
+	return sourceCode + strings.ReplaceAll(harness, "{{function}}", functionName), nil
+}
+
+/* Splits the harness result line out of stdout, returning the user's own output separately */
+func parseProgramOutput(stdout string) (userOutput string, result string, ok bool) {
+	idx := strings.LastIndex(stdout, resultMarker)
+	if idx == -1 {
+		return stdout, "", false
+	}
+
+	return strings.TrimRight(stdout[:idx], "\n"), strings.TrimSpace(stdout[idx+len(resultMarker):]), true
+}
+
+/* Compares two JSON documents structurally so formatting and number representation don't matter */
+func sameJSON(a, b []byte) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(x, y)
+}
+
+/**
+ * Gets the test cases for the request's problem, runs each one and stops at the first failure.
+ * Sanity runs only use the public example and sanity cases. Hidden cases never reveal their
+ * input or expected output unless they are the first failure and the problem allows it.
+ */
+func run(ctx context.Context, s *APIServer, account *Account, req *ExecReq) (*Result, error) {
+	problem, err := s.store.GetProblemByID(ctx, req.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := problem.checkVisibleTo(account); err != nil {
+		return nil, err
+	}
+
+	var tests []*TestCase
+	if req.IsSanityCheck {
+		tests, err = s.store.GetTestCasesByKind(ctx, req.ProblemID, TestCaseExample, TestCaseSanity)
+	} else {
+		tests, err = s.store.GetTestCasesByProblemID(ctx, req.ProblemID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := buildProgram(req.LanguageID, req.SourceCode, problem.FunctionName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Passed: true, TestResults: []TestResult{}}
+	for _, tc := range tests {
+		tr, err := runTestCase(ctx, s.executor, program, req.LanguageID, tc)
+		if err != nil {
+			return nil, err
+		}
+
+		if tc.Kind == TestCaseHidden && (tr.Passed || !problem.RevealHiddenOnFailure) {
+			tr.conceal()
+		}
+
+		result.TestResults = append(result.TestResults, *tr)
+		if !tr.Passed {
+			result.Passed = false
+			break
+		}
+	}
+
+	return result, nil
+}
+
+/* Executes `program` with the test case's input and checks the return value */
+func runTestCase(ctx context.Context, executor Executor, program string, languageID int, tc *TestCase) (*TestResult, error) {
+	input, err := json.Marshal(tc.IO.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := json.Marshal(tc.IO.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	execResult, err := executor.Execute(ctx, &Judge0Submission{
+		SourceCode: program,
+		LanguageID: languageID,
+		Stdin:      string(input),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tr := &TestResult{
+		Kind:     tc.Kind,
+		Input:    string(input),
+		Expected: string(expected),
+	}
+
+	if execResult.Status.ID != judge0StatusAccepted {
+		tr.Error = execResult.errorOutput()
+		tr.status = execResult.Status.Description
+		switch execResult.Status.ID {
+		case judge0StatusTimeLimit:
+			tr.verdict = VerdictTimeLimitExceeded
+		case judge0StatusCompile:
+			tr.verdict = VerdictCompileError
+		default:
+			tr.verdict = VerdictRuntimeError
+		}
+		return tr, nil
+	}
+
+	stdout, output, ok := parseProgramOutput(execResult.Stdout)
+	tr.Stdout = stdout
+	tr.Output = output
+	if !ok {
+		tr.Error = "Function did not return a value"
+		tr.status = tr.Error
+		tr.verdict = VerdictRuntimeError
+		return tr, nil
+	}
+
+	tr.Passed = sameJSON([]byte(output), expected)
+	tr.verdict = VerdictWrongAnswer
+	return tr, nil
 }
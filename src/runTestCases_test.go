@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+/* Runs the JavaScript harness with node, when it is installed, for every way of declaring f */
+func TestJavaScriptHarness(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+
+	for source, want := range map[string]string{
+		"function f(x, y = 1) { return x + y }":         "3",
+		"const f = (y, x) => x * 10 + y":                "12",
+		"const f = x => Math.max(x, 1)":                 "1",
+		"var f = function (x) { return [x, typeof y] }": `[1,"undefined"]`,
+	} {
+		program, err := buildProgram(languageIDs["javascript"], source, "f")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command("node", "-e", program)
+		cmd.Stdin = strings.NewReader(`{"x": 1, "y": 2}`)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("%s: %v\n%s", source, err, out)
+			continue
+		}
+		if _, result, ok := parseProgramOutput(string(out)); !ok || result != want {
+			t.Errorf("%s: expected %s, got %q", source, want, out)
+		}
+	}
+}
+
+/* A program that fails with its input on stderr, the way a player would try to read a hidden case */
+type stderrExecutor struct{}
+
+func (stderrExecutor) Execute(ctx context.Context, req *Judge0Submission) (*ExecResult, error) {
+	stderr := "ValueError: " + req.Stdin
+	res := &ExecResult{Stderr: &stderr}
+	res.Status.ID = 11
+	res.Status.Description = "Runtime Error (NZEC)"
+	return res, nil
+}
+
+/* Publishes a problem asking for add(a, b), with a hidden test case adding up 12345 and 67890 */
+func publishAddProblem(t *testing.T, s *APIServer) int {
+	t.Helper()
+	ctx := context.Background()
+
+	id, err := s.store.CreateProblem(ctx, NewProblem("Add", "Add a and b", "def add(a, b):", "add", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden := NewTestCase(id, map[string]interface{}{"a": 12345, "b": 67890}, 80235, TestCaseHidden)
+	if err := s.store.CreateTestCases(ctx, []*TestCase{hidden}); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range [][2]string{{ProblemDraft, ProblemInReview}, {ProblemInReview, ProblemPublished}} {
+		if err := s.store.SetProblemStatus(ctx, id, status[0], status[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return id
+}
+
+func TestHiddenFailureDoesNotEchoInput(t *testing.T) {
+	s := newTestServer()
+	s.executor = stderrExecutor{}
+	ctx := context.Background()
+	id := publishAddProblem(t, s)
+
+	result, err := run(ctx, s, nil, &ExecReq{ProblemID: id, LanguageID: languageIDs["python3"], SourceCode: "def add(a, b): raise ValueError(a)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := result.TestResults[0]
+	if result.Passed || !tr.Hidden || strings.Contains(tr.Error, "12345") || tr.Error != "Runtime Error (NZEC)" {
+		t.Errorf("expected only judge0's status for the hidden case, got %+v", tr)
+	}
+}
+
+/* Submissions are judged when they're made, so the verdict and solved filters have something to match */
+func TestSubmissionsAreJudged(t *testing.T) {
+	c := newSpecClient(t)
+	player := signUp(c, "dave", RolePlayer)
+	id := publishAddProblem(t, c.server)
+
+	cases := []struct {
+		source, verdict string
+	}{
+		{"def add(a, b): return a - b", VerdictWrongAnswer},
+		{"def add(a, b): return a + b", VerdictAccepted},
+	}
+	for _, tc := range cases {
+		sub := c.call("POST", "/submissions", player, CreateSubmissionRequest{ProblemID: id, SourceCode: tc.source, Language: languageIDs["python3"]}, http.StatusCreated)
+		if sub["verdict"] != tc.verdict {
+			t.Errorf("expected %q to be judged %s, got %v", tc.source, tc.verdict, sub["verdict"])
+		}
+	}
+
+	if page := c.call("GET", "/submissions?verdict=wrong_answer", player, nil, http.StatusOK); len(page["items"].([]interface{})) != 1 {
+		t.Errorf("expected one wrong answer, got %v", page["items"])
+	}
+	if page := c.call("GET", "/problems?solved=true", player, nil, http.StatusOK); len(page["items"].([]interface{})) != 1 {
+		t.Errorf("expected the problem to be solved, got %v", page["items"])
+	}
+	if page := c.call("GET", "/problems?solved=false", player, nil, http.StatusOK); len(page["items"].([]interface{})) != 0 {
+		t.Errorf("expected no unsolved problems, got %v", page["items"])
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	loginFailureWindow      = 15 * time.Minute
+	accountFailureThreshold = 5  // failures for one username before backoff starts
+	ipFailureThreshold      = 20 // failures from one IP, across usernames, before backoff starts
+	maxLoginLockout         = 15 * time.Minute
+)
+
+var ErrSessionNotFound = NotFound("session not found")
+
+/* One row of the login audit log */
+type LoginAttempt struct {
+	AttemptID   int       `json:"attempt_id"`
+	UserID      *int      `json:"user_id"`
+	Username    string    `json:"username"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	Success     bool      `json:"success"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+/* Failed logins inside the failure window. Account failures reset on a successful login */
+type LoginFailureStats struct {
+	AccountFailures    int
+	AccountLastFailure time.Time
+	IPFailures         int
+	IPLastFailure      time.Time
+}
+
+/* A refresh token family, i.e. everything issued from one login */
+type Session struct {
+	SessionID  string    `json:"session_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+/*
+ * Exponential backoff once `failures` passes `threshold`: 1s, 2s, 4s, ... after the last
+ * failure, capped at maxLoginLockout which amounts to a temporary lockout.
+ */
+func loginBackoff(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	backoff := time.Duration(math.Pow(2, float64(failures-threshold))) * time.Second
+	if backoff > maxLoginLockout || backoff <= 0 {
+		return maxLoginLockout
+	}
+	return backoff
+}
+
+/* How long the caller has to wait before another attempt is allowed, 0 if it is allowed now */
+func loginRetryAfter(stats *LoginFailureStats, now time.Time) time.Duration {
+	wait := time.Duration(0)
+
+	if d := stats.AccountLastFailure.Add(loginBackoff(stats.AccountFailures, accountFailureThreshold)).Sub(now); d > wait {
+		wait = d
+	}
+	if d := stats.IPLastFailure.Add(loginBackoff(stats.IPFailures, ipFailureThreshold)).Sub(now); d > wait {
+		wait = d
+	}
+
+	return wait
+}
+
+// GET api/accounts/{id}/sessions
+func (s *APIServer) handleGetSessions(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r, "user_id")
+	if err != nil {
+		return err
+	}
+
+	sessions, err := s.store.GetSessions(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, sessions)
+}
+
+// DELETE api/accounts/{id}/sessions/{session_id}
+func (s *APIServer) handleRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	id, err := getID(r, "user_id")
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.RevokeSession(r.Context(), id, mux.Vars(r)["session_id"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func errLoginLocked(wait time.Duration) error {
+	return RateLimited(wait, "too many failed login attempts, try again later")
+}
@@ -1,50 +1,123 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
 type Storage interface {
 	// Account CRUD
-	CreateAccount(*CreateAccountRequest) (*CreateAccountResponse, error)
-	GetAccountByID(int) (*Account, error)
-	GetAccounts() ([]*Account, error)
-	UpdateAccount(*Account) error
-	DeleteAccount(int) error
+	CreateAccount(context.Context, *CreateAccountRequest) (*Account, error)
+	GetAccountByID(context.Context, int) (*Account, error)
+	GetAccounts(context.Context, PageRequest) (*Page[*Account], error)
+	UpdateAccount(context.Context, *Account) error
+	DeleteAccount(context.Context, int) error
+	UpdateAccountRole(ctx context.Context, id int, role string) error
+	ChangeAccountPassword(ctx context.Context, id int, oldPassword, newPassword string) error
+	ResetAccountPassword(ctx context.Context, id int, newPassword string) error
+	GetAccountByEmail(context.Context, string) (*Account, error)
+	GetAccountByUsername(context.Context, string) (*Account, error)
+	MarkEmailVerified(ctx context.Context, id int) error
+	VerifyAccountPassword(ctx context.Context, username, password string) (*Account, error)
+
+	// Refresh tokens
+	CreateRefreshToken(context.Context, *RefreshToken) error
+	ConsumeRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash string) error
+	GetSessions(ctx context.Context, userID int) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID string) error
+
+	// Login audit log
+	RecordLoginAttempt(context.Context, *LoginAttempt) error
+	GetLoginFailureStats(ctx context.Context, username, ip string, since time.Time) (*LoginFailureStats, error)
+
+	// Single-use emailed tokens
+	CreateAccountToken(context.Context, *AccountToken) error
+	ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (*AccountToken, error)
+
+	// OAuth
+	CreateOAuthState(context.Context, *OAuthState) error
+	ConsumeOAuthState(ctx context.Context, state string) (*OAuthState, error)
+	CreateAccountIdentity(context.Context, *AccountIdentity) error
+	GetAccountByIdentity(ctx context.Context, provider, subject string) (*Account, error)
+	GetAccountIdentities(ctx context.Context, userID int) ([]*AccountIdentity, error)
+	DeleteAccountIdentity(ctx context.Context, userID int, provider string) error
 
 	/* --- Front end will not delete any of these below, so i have chosen to omit delete routes --- */
 
 	// Problem CRU - no need for delete
-	CreateProblem(*Problem) (int, error)
-	GetProblemByID(int) (*Problem, error)
-	GetProblemByName(string) (*Problem, error)
-	GetProblems() ([]*Problem, error)
-	UpdateProblem(*Problem) error
-
-	// TestCase CRU - no need for delete
-	CreateTestCase(*TestCase) (int, error)
-	GetTestCasesByProblemID(int) ([]*TestCase, error)
-	GetTestCaseSanityChecks(int) ([]*TestCase, error)
-	GetTestCases() ([]*TestCase, error)
-	UpdateTestCase(*TestCase) error
+	CreateProblem(context.Context, *Problem) (int, error)
+	GetProblemByID(context.Context, int) (*Problem, error)
+	GetProblemByName(context.Context, string) (*Problem, error)
+	GetProblemBySlug(context.Context, string) (*Problem, error)
+	GetProblems(context.Context, ProblemFilter) (*Page[*Problem], error)
+	SearchProblems(ctx context.Context, query string, limit int) ([]*ProblemSearchResult, error)
+	UpdateProblem(ctx context.Context, prob *Problem, editedBy *int) error
+	SetProblemTags(ctx context.Context, problemID int, tags []string) error
+	SetProblemStatus(ctx context.Context, problemID int, from, to string) error
+	GetProblemRevisions(ctx context.Context, problemID int) ([]*ProblemRevision, error)
+
+	// Reference solutions, one per problem and language
+	SetReferenceSolution(context.Context, *ReferenceSolution) error
+	GetReferenceSolutions(ctx context.Context, problemID int) ([]*ReferenceSolution, error)
+	DeleteReferenceSolution(ctx context.Context, problemID, languageID int) error
+
+	// Tags
+	CreateTag(context.Context, *Tag) error
+	GetTags(context.Context) ([]*Tag, error)
+
+	// TestCases. Deleting them only makes sense when replacing a whole suite
+	CreateTestCase(context.Context, *TestCase) (int, error)
+	CreateTestCases(context.Context, []*TestCase) error
+	GetTestCasesByProblemID(context.Context, int) ([]*TestCase, error)
+	GetTestCaseSanityChecks(context.Context, int) ([]*TestCase, error)
+	GetTestCasesByKind(ctx context.Context, problemID int, kinds ...string) ([]*TestCase, error)
+	GetTestCases(context.Context, TestCaseFilter) (*Page[*TestCase], error)
+	UpdateTestCase(context.Context, *TestCase) error
+	DeleteTestCases(ctx context.Context, problemID int) error
+	SetTestCaseFlags(ctx context.Context, problemID int, flags map[int]string) error
 
 	// Submission CRU - no need for delete (yet)
-	CreateSubmission(*Submission) (*Submission, error)
-	GetSubmissionByID(int) (*Submission, error)
-	GetSubmissions() ([]*Submission, error)
-	UpdateSubmission(*Submission) error
+	CreateSubmission(context.Context, *Submission) (*Submission, error)
+	GetSubmissionByID(context.Context, int) (*Submission, error)
+	GetSubmissions(context.Context, SubmissionFilter) (*Page[*Submission], error)
+	UpdateSubmission(context.Context, *Submission) error
+
+	/*
+	 * Runs fn against a Storage bound to one transaction, committing if it returns nil and
+	 * rolling back otherwise. fn may be called more than once if the transaction has to be
+	 * retried, so it should not have side effects outside of the store.
+	 */
+	WithTx(ctx context.Context, fn func(Storage) error) error
+}
+
+/* Upper bound on a single store call, on top of whatever deadline the caller's context has */
+const queryTimeout = 5 * time.Second
+
+// bcrypt hash of a random string, used to keep failed logins constant-time
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("algoduels-dummy-password"), bcrypt.DefaultCost)
+
+/* Implemented by both *sql.DB and *sql.Tx */
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 type PostgresStore struct {
-	db *sql.DB
+	db   *sql.DB
+	conn dbtx // db, or the open transaction for a store handed out by WithTx
+	tx   *sql.Tx
 }
 
 func NewPostgresStore() (*PostgresStore, error) {
@@ -63,6 +136,10 @@ func NewPostgresStore() (*PostgresStore, error) {
 	/* Create connection string */
 	connStr := fmt.Sprintf("host=%s user=%s dbname=%s password=%s sslmode=disable", dbHost, dbUser, dbName, dbPass)
 
+	return OpenPostgresStore(connStr)
+}
+
+func OpenPostgresStore(connStr string) (*PostgresStore, error) {
 	/* Open database connection */
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
@@ -75,94 +152,76 @@ func NewPostgresStore() (*PostgresStore, error) {
 
 	fmt.Println("Database connection opened")
 	return &PostgresStore{
-		db: db,
+		db:   db,
+		conn: db,
 	}, nil
 }
 
-func (s *PostgresStore) Init() error {
-	tableCreationFuncs := []func() error{
-		s.createAccountTable,
-		s.createProblemTable,
-		s.createTestCaseTable,
-		s.createSubmissionTable,
+const (
+	maxTxAttempts = 5
+	txRetryDelay  = 20 * time.Millisecond
+)
+
+func (s *PostgresStore) WithTx(ctx context.Context, fn func(Storage) error) error {
+	return s.inTx(ctx, func(tx *PostgresStore) error {
+		return fn(tx)
+	})
+}
+
+/*
+ * Transactions are serializable, so concurrent units of work can fail with a serialization
+ * failure or deadlock and are retried from the start with a growing delay. Nested calls join
+ * the outer transaction instead of starting a new one.
+ */
+func (s *PostgresStore) inTx(ctx context.Context, fn func(*PostgresStore) error) error {
+	if s.tx != nil {
+		return fn(s)
 	}
 
-	for _, f := range tableCreationFuncs {
-		if err := f(); err != nil {
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, fn)
+		if err == nil || !isRetryableTxError(err) || attempt == maxTxAttempts {
 			return err
 		}
-	}
-
-	return nil
-}
-
-func (s *PostgresStore) createAccountTable() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS Account (
-			user_id SERIAL PRIMARY KEY,
-			first_name VARCHAR(50),
-			last_name VARCHAR(50),
-			username VARCHAR(50),
-			email VARCHAR(50),
-			encrypted_password VARCHAR(100),
-			created_at TIMESTAMP
-		)
-	`
 
-	_, err := s.db.Exec(query)
-	return err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(txRetryDelay * time.Duration(1<<(attempt-1))):
+		}
+	}
 }
 
-func (s *PostgresStore) createProblemTable() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS Problem (
-			problem_id SERIAL PRIMARY KEY,
-			prompt VARCHAR(255),
-			starter_code TEXT,
-			difficulty SMALLINT
-		)
-	`
+func (s *PostgresStore) runTx(ctx context.Context, fn func(*PostgresStore) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
 
-	_, err := s.db.Exec(query)
-	return err
-}
+	// Rolling back after a commit is a no-op, so this also covers fn panicking
+	defer tx.Rollback()
 
-func (s *PostgresStore) createTestCaseTable() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS TestCase (
-			test_case_id SERIAL PRIMARY KEY,
-			problem_id INT REFERENCES Problem(problem_id),
-            problem_name text,
-			input TEXT,
-			output TEXT,
-            is_sanity_check BOOLEAN
-		)
-	`
+	if err := fn(&PostgresStore{db: s.db, conn: tx, tx: tx}); err != nil {
+		return err
+	}
 
-	_, err := s.db.Exec(query)
-	return err
+	return tx.Commit()
 }
 
-func (s *PostgresStore) createSubmissionTable() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS Submission (
-			submission_id SERIAL PRIMARY KEY,
-			user_id INT REFERENCES Account(user_id),
-			problem_id INT REFERENCES Problem(problem_id),
-			submitted_at TIMESTAMP DEFAULT NOW(),
-			source_code TEXT,
-			language INT,
-			runtime_ms INT,
-			mem_usage_kb INT
-		)
-	`
-
-	_, err := s.db.Exec(query)
-	return err
+/* serialization_failure and deadlock_detected, the two errors a retry can fix */
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
 }
 
 // -- Account Create --
-func (s *PostgresStore) CreateAccount(acc *CreateAccountRequest) (*CreateAccountResponse, error) {
+func (s *PostgresStore) CreateAccount(ctx context.Context, acc *CreateAccountRequest) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
 	query := `
 			INSERT INTO Account (
                 first_name,
@@ -173,399 +232,1402 @@ func (s *PostgresStore) CreateAccount(acc *CreateAccountRequest) (*CreateAccount
 				created_at
 			) 
 			VALUES ($1, $2, $3, $4, $5, $6)
-            RETURNING *
-		`
+            RETURNING ` + accountColumns
 
-	safePass, err := bcrypt.GenerateFromPassword([]byte(acc.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
+	// Accounts created through an OAuth provider have no password until they set one
+	var safePass []byte
+	if acc.Password != "" {
+		var err error
+		safePass, err = bcrypt.GenerateFromPassword([]byte(acc.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	rows, err := s.db.Query(query, acc.FirstName, acc.LastName, acc.Username, acc.Email, safePass, time.Now().UTC())
+	account, err := scanAccount(s.conn.QueryRowContext(ctx, query, acc.FirstName, acc.LastName, acc.Username, acc.Email, string(safePass), time.Now().UTC()))
 	if err != nil {
-		return nil, err
+		return nil, accountConflict(err)
 	}
 	fmt.Println("Account inserted into database.")
 
-	res, err := scanIntoAccountResponse(rows)
+	return account, nil
+}
 
-	if err != nil {
-		return nil, err
-	}
+// -- Account Read --
+func (s *PostgresStore) GetAccountByID(ctx context.Context, id int) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+	query := `SELECT ` + accountColumns + ` FROM Account WHERE user_id=$1 AND deleted_at IS NULL`
+
+	account, err := scanAccount(s.conn.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, NotFound("Account %d not found", id)
 	}
 
-	return res, nil
+	return account, err
 }
 
-// -- Account Read --
-func (s *PostgresStore) GetAccountByID(id int) (*Account, error) {
-	query := `SELECT * FROM Account WHERE user_id=$1`
+func (s *PostgresStore) GetAccountByEmail(ctx context.Context, email string) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	rows, err := s.db.Query(query, id)
+	query := `SELECT ` + accountColumns + ` FROM Account WHERE LOWER(email)=LOWER($1) AND deleted_at IS NULL`
 
-	if err != nil {
-		return nil, err
+	account, err := scanAccount(s.conn.QueryRowContext(ctx, query, email))
+	if err == sql.ErrNoRows {
+		return nil, NotFound("Account with email %s not found", email)
 	}
 
-	for rows.Next() {
-		return scanIntoAccount(rows)
+	return account, err
+}
+
+func (s *PostgresStore) GetAccountByUsername(ctx context.Context, username string) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + accountColumns + ` FROM Account WHERE LOWER(username)=LOWER($1) AND deleted_at IS NULL`
+
+	account, err := scanAccount(s.conn.QueryRowContext(ctx, query, username))
+	if err == sql.ErrNoRows {
+		return nil, NotFound("Account %s not found", username)
 	}
 
-	return nil, fmt.Errorf("Account %d not found", id)
+	return account, err
 }
 
-func (s *PostgresStore) GetAccounts() ([]*Account, error) {
-	query := `SELECT * FROM Account`
-
-	rows, err := s.db.Query(query)
+func (s *PostgresStore) GetAccounts(ctx context.Context, page PageRequest) (*Page[*Account], error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
+	f := &queryFilter{}
+	f.add("deleted_at IS NULL")
+	order, err := accountOrder.sql(f, page)
 	if err != nil {
 		return nil, err
 	}
 
-	accounts := []*Account{}
+	query := `SELECT ` + accountColumns + ` FROM Account` + f.where() + order
 
-	for rows.Next() {
-		account, err := scanIntoAccount(rows)
-		if err != nil {
-			return nil, err
-		}
-		accounts = append(accounts, account)
+	rows, err := s.conn.QueryContext(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
 	}
 
-	return accounts, nil
+	accounts, err := scanRows(rows, scanAccount)
+	if err != nil {
+		return nil, err
+	}
+	return accountOrder.page(accounts, page)
 }
 
 // -- Account Update --
-func (s *PostgresStore) UpdateAccount(*Account) error {
-	return nil
-}
+func (s *PostgresStore) UpdateAccount(ctx context.Context, acc *Account) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-// -- Account Delete --
-func (s *PostgresStore) DeleteAccount(id int) error {
 	query := `
-		DELETE FROM Account WHERE user_id=$1
+		UPDATE Account SET first_name=$2, last_name=$3, username=$4, email=$5,
+			email_verified=(email_verified AND LOWER(email)=LOWER($5))
+		WHERE user_id=$1 AND deleted_at IS NULL
 	`
 
-	_, err := s.db.Query(query, id)
+	return s.inTx(ctx, func(tx *PostgresStore) error {
+		res, err := tx.conn.ExecContext(ctx, query, acc.UserID, acc.FirstName, acc.LastName, acc.Username, acc.Email)
+		if err != nil {
+			return accountConflict(err)
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return NotFound("Account %d not found", acc.UserID)
+		}
+
+		// Links emailed to the old address mustn't verify or reset the new one
+		_, err = tx.conn.ExecContext(ctx, `DELETE FROM AccountToken WHERE user_id=$1 AND used_at IS NULL AND LOWER(email)<>LOWER($2)`, acc.UserID, acc.Email)
+		return err
+	})
+}
+
+func (s *PostgresStore) ChangeAccountPassword(ctx context.Context, id int, oldPassword, newPassword string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var current string
+	err := s.conn.QueryRowContext(ctx, `SELECT encrypted_password FROM Account WHERE user_id=$1 AND deleted_at IS NULL`, id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return NotFound("Account %d not found", id)
+	}
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := bcrypt.CompareHashAndPassword([]byte(current), []byte(oldPassword)); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	return s.ResetAccountPassword(ctx, id, newPassword)
 }
 
-// --  Problem Create --
-func (s *PostgresStore) CreateProblem(prob *Problem) (int, error) {
-	query := `
-			INSERT INTO Problem (
-				prompt,
-				starter_code,
-				difficulty
-			) 
-			VALUES ($1, $2, $3) RETURNING problem_id;
-		`
-	var problemID int
-	err := s.db.QueryRow(query, prob.Prompt, prob.StarterCode, prob.Difficulty).Scan(&problemID)
-	fmt.Printf("ProblemID: %d", problemID)
+/* Sets a new password without checking the old one and signs out every session */
+func (s *PostgresStore) ResetAccountPassword(ctx context.Context, id int, newPassword string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	safePass, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
-		return -1, err // -1 signifies an error occurred
+		return err
 	}
 
-	return problemID, nil
+	return s.inTx(ctx, func(tx *PostgresStore) error {
+		if _, err := tx.conn.ExecContext(ctx, `UPDATE Account SET encrypted_password=$2 WHERE user_id=$1`, id, safePass); err != nil {
+			return err
+		}
+
+		_, err := tx.conn.ExecContext(ctx, `UPDATE RefreshToken SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL`, id)
+		return err
+	})
 }
 
-// -- Problem Read --
-func (s *PostgresStore) GetProblemByID(id int) (*Problem, error) {
-	query := `SELECT * FROM Problem WHERE problem_id=$1`
+func (s *PostgresStore) UpdateAccountRole(ctx context.Context, id int, role string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	rows, err := s.db.Query(query, id)
+	query := `UPDATE Account SET role=$2 WHERE user_id=$1`
 
+	res, err := s.conn.ExecContext(ctx, query, id, role)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for rows.Next() {
-		return scanIntoProblem(rows)
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return NotFound("Account %d not found", id)
 	}
 
-	return nil, fmt.Errorf("problem %d not found", id)
+	return nil
+}
+
+func (s *PostgresStore) MarkEmailVerified(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, `UPDATE Account SET email_verified=TRUE WHERE user_id=$1`, id)
+	return err
 }
 
-func (s *PostgresStore) GetProblemByName(name string) (*Problem, error) {
-	query := `SELECT * FROM problem WHERE problem_name=$1`
+// -- Account Delete --
+/*
+ * Accounts are never removed because submissions (and later matches) reference them.
+ * Instead the row is anonymized, its credentials cleared and every session revoked.
+ */
+func (s *PostgresStore) DeleteAccount(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	rows, err := s.db.Query(query, name)
+	query := `
+		UPDATE Account SET
+			first_name='',
+			last_name='',
+			username='deleted-' || user_id,
+			email='',
+			encrypted_password='',
+			deleted_at=$2
+		WHERE user_id=$1 AND deleted_at IS NULL
+	`
 
-	if err != nil {
-		return nil, err
-	}
+	return s.inTx(ctx, func(tx *PostgresStore) error {
+		res, err := tx.conn.ExecContext(ctx, query, id, time.Now().UTC())
+		if err != nil {
+			return err
+		}
 
-	for rows.Next() {
-		return scanIntoProblem(rows)
-	}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return NotFound("Account %d not found", id)
+		}
+
+		// Unlinked so the provider identity can sign up again later
+		if _, err := tx.conn.ExecContext(ctx, `DELETE FROM account_identity WHERE user_id=$1`, id); err != nil {
+			return err
+		}
 
-	return nil, fmt.Errorf("Problem %s not found", name)
+		_, err = tx.conn.ExecContext(ctx, `UPDATE RefreshToken SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL`, id)
+		return err
+	})
 }
 
-func (s *PostgresStore) GetProblems() ([]*Problem, error) {
-	query := `SELECT * FROM Problem`
+// -- Account Auth --
+func (s *PostgresStore) VerifyAccountPassword(ctx context.Context, username, password string) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	rows, err := s.db.Query(query)
+	query := `SELECT ` + accountColumns + ` FROM Account WHERE LOWER(username)=LOWER($1) AND deleted_at IS NULL`
 
+	account, encryptedPassword, err := scanAccountWithPassword(s.conn.QueryRowContext(ctx, query, username))
+	if err == sql.ErrNoRows {
+		// Compare against a dummy hash so unknown usernames take as long as wrong passwords
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	problems := []*Problem{}
-
-	for rows.Next() {
-		problem, err := scanIntoProblem(rows)
-		if err != nil {
-			return nil, err
-		}
-		problems = append(problems, problem)
+	if err := bcrypt.CompareHashAndPassword([]byte(encryptedPassword), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
 	}
 
-	return problems, nil
+	return account, nil
 }
 
-// -- Problem Update --
-func (s *PostgresStore) UpdateProblem(*Problem) error {
-	return nil
-}
+// -- Refresh Token Create --
+func (s *PostgresStore) CreateRefreshToken(ctx context.Context, t *RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-// --  TestCase Create --
-func (s *PostgresStore) CreateTestCase(testcase *TestCase) (int, error) {
 	query := `
-			INSERT INTO TestCase (
-				problem_id, 
-				io,
-                is_sanity_check
-			) 
-			VALUES ($1, $2, $3, $4) RETURNING test_case_id;
+			INSERT INTO RefreshToken (
+				user_id,
+				family_id,
+				token_hash,
+				created_at,
+				expires_at,
+				ip,
+				user_agent
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING token_id;
 		`
 
-	var testCaseID int
-	err := s.db.QueryRow(query, testcase.ProblemID, testcase.IO, testcase.IsSanityCheck).Scan(&testCaseID)
-	if err != nil {
-		return -1, err
-	}
-
-	return testCaseID, nil
+	return s.conn.QueryRowContext(ctx, query, t.UserID, t.FamilyID, t.TokenHash, t.CreatedAt, t.ExpiresAt, t.IP, truncate(t.UserAgent, 255)).Scan(&t.TokenID)
 }
 
-// -- TestCase Read -- ID here is a PROBLEM id
-func (s *PostgresStore) GetTestCasesByProblemID(id int) ([]*TestCase, error) {
-	query := `SELECT * FROM TestCase WHERE problem_id=$1`
+/*
+ * Marks the token as used and returns it so a replacement can be issued in the same family.
+ * Presenting a token that was already rotated means it leaked, so the whole family is revoked.
+ */
+func (s *PostgresStore) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			UPDATE RefreshToken SET revoked_at=$2
+			WHERE token_hash=$1 AND revoked_at IS NULL AND expires_at > $2
+			RETURNING token_id, user_id, family_id, token_hash, created_at, expires_at, revoked_at
+		`
 
-	rows, err := s.db.Query(query, id)
+	t := new(RefreshToken)
+	err := s.conn.QueryRowContext(ctx, query, tokenHash, time.Now().UTC()).Scan(&t.TokenID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt, &t.RevokedAt)
+	if err == nil {
+		return t, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
 
+	var familyID string
+	var revokedAt *time.Time
+	err = s.conn.QueryRowContext(ctx, `SELECT family_id, revoked_at FROM RefreshToken WHERE token_hash=$1`, tokenHash).Scan(&familyID, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenInvalid
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	testCases := []*TestCase{}
+	if revokedAt == nil {
+		// Expired but never used
+		return nil, ErrRefreshTokenInvalid
+	}
 
-	for rows.Next() {
-		testCase, err := scanIntoTestCase(rows)
-		if err != nil {
-			return nil, err
-		}
-		testCases = append(testCases, testCase)
+	if _, err := s.conn.ExecContext(ctx, `UPDATE RefreshToken SET revoked_at=NOW() WHERE family_id=$1 AND revoked_at IS NULL`, familyID); err != nil {
+		return nil, err
 	}
 
-	return testCases, nil
+	return nil, ErrRefreshTokenReused
 }
 
-// -- TestCase Read -- ID here is a PROBLEM id
-func (s *PostgresStore) GetTestCaseSanityChecks(id int) ([]*TestCase, error) {
-	query := `SELECT * FROM TestCase WHERE problem_id=$1 AND is_sanity_check=TRUE`
+// -- Refresh Token Delete -- revokes every token in the same family, i.e. the whole session
+func (s *PostgresStore) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE RefreshToken SET revoked_at=NOW()
+		WHERE revoked_at IS NULL AND family_id=(SELECT family_id FROM RefreshToken WHERE token_hash=$1)
+	`
+
+	_, err := s.conn.ExecContext(ctx, query, tokenHash)
+	return err
+}
+
+/* Active sessions, newest activity first. IP and user agent are the ones last seen */
+func (s *PostgresStore) GetSessions(ctx context.Context, userID int) ([]*Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	rows, err := s.db.Query(query, id)
+	query := `
+		SELECT family_id,
+			(ARRAY_AGG(ip ORDER BY token_id DESC))[1],
+			(ARRAY_AGG(user_agent ORDER BY token_id DESC))[1],
+			MIN(created_at),
+			MAX(created_at),
+			MAX(expires_at)
+		FROM RefreshToken
+		WHERE user_id=$1
+		GROUP BY family_id
+		HAVING BOOL_OR(revoked_at IS NULL AND expires_at > NOW())
+		ORDER BY MAX(created_at) DESC
+	`
 
+	rows, err := s.conn.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	testCases := []*TestCase{}
-
+	sessions := []*Session{}
 	for rows.Next() {
-		testCase, err := scanIntoTestCase(rows)
-		if err != nil {
+		sess := new(Session)
+		if err := rows.Scan(&sess.SessionID, &sess.IP, &sess.UserAgent, &sess.CreatedAt, &sess.LastUsedAt, &sess.ExpiresAt); err != nil {
 			return nil, err
 		}
-		fmt.Println("test case in storage", testCase)
-		testCases = append(testCases, testCase)
+		sessions = append(sessions, sess)
 	}
 
-	return testCases, nil
+	return sessions, rows.Err()
 }
 
-func (s *PostgresStore) GetTestCases() ([]*TestCase, error) {
-	query := `SELECT * FROM TestCase`
+func (s *PostgresStore) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	rows, err := s.db.Query(query)
+	query := `UPDATE RefreshToken SET revoked_at=NOW() WHERE user_id=$1 AND family_id=$2 AND revoked_at IS NULL`
 
+	res, err := s.conn.ExecContext(ctx, query, userID, sessionID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	testCases := []*TestCase{}
-
-	for rows.Next() {
-		testCase, err := scanIntoTestCase(rows)
-		if err != nil {
-			return nil, err
-		}
-		testCases = append(testCases, testCase)
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrSessionNotFound
 	}
-
-	return testCases, nil
+	return err
 }
 
-// -- TestCase Update --
-func (s *PostgresStore) UpdateTestCase(*TestCase) error {
-	return nil
-}
+// -- Login Attempt Create --
+func (s *PostgresStore) RecordLoginAttempt(ctx context.Context, a *LoginAttempt) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-// --  Submission Create --
-func (s *PostgresStore) CreateSubmission(sub *Submission) (*Submission, error) {
 	query := `
-			INSERT INTO Submission (
-				user_id,
-				problem_id,
-				submitted_at,
-				code,
-				language,
-				exec_time_ms,
-				mem_usage_kb
-			) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			INSERT INTO LoginAttempt (user_id, username, ip, user_agent, success, attempted_at)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING attempt_id
 		`
 
-	rows, err := s.db.Query(query, sub.UserID, sub.ProblemID, time.Now().UTC(), sub.SourceCode, sub.Language)
-	defer rows.Close()
+	return s.conn.QueryRowContext(ctx, query, a.UserID, truncate(a.Username, 50), a.IP, truncate(a.UserAgent, 255), a.Success, a.AttemptedAt).Scan(&a.AttemptID)
+}
 
-	if err != nil {
-		return nil, err
-	}
+func (s *PostgresStore) GetLoginFailureStats(ctx context.Context, username, ip string, since time.Time) (*LoginFailureStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE username=$1 AND attempted_at > GREATEST($3, (
+				SELECT COALESCE(MAX(attempted_at), $3) FROM LoginAttempt WHERE username=$1 AND success
+			))),
+			COALESCE(MAX(attempted_at) FILTER (WHERE username=$1), $3),
+			COUNT(*) FILTER (WHERE ip=$2),
+			COALESCE(MAX(attempted_at) FILTER (WHERE ip=$2), $3)
+		FROM LoginAttempt
+		WHERE NOT success AND attempted_at > $3 AND (username=$1 OR ip=$2)
+	`
 
-	sub, err = scanIntoSubmission(rows)
+	stats := new(LoginFailureStats)
+	err := s.conn.QueryRowContext(ctx, query, strings.ToLower(username), ip, since).Scan(&stats.AccountFailures, &stats.AccountLastFailure, &stats.IPFailures, &stats.IPLastFailure)
 	if err != nil {
 		return nil, err
 	}
 
-	return sub, nil
+	return stats, nil
 }
 
-// -- Submission Read --
-func (s *PostgresStore) GetSubmissionByID(id int) (*Submission, error) {
-	query := `SELECT * FROM Submission WHERE submission_id=$1`
+// -- Account Token Create --
+func (s *PostgresStore) CreateAccountToken(ctx context.Context, t *AccountToken) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			INSERT INTO AccountToken (
+				user_id,
+				email,
+				purpose,
+				token_hash,
+				created_at,
+				expires_at
+			)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING token_id;
+		`
+
+	return s.conn.QueryRowContext(ctx, query, t.UserID, t.Email, t.Purpose, t.TokenHash, t.CreatedAt, t.ExpiresAt).Scan(&t.TokenID)
+}
+
+/*
+ * Marks an unused, unexpired token as used, as long as the account still has the email it was
+ * sent to. Returns ErrInvalidToken for anything else
+ */
+func (s *PostgresStore) ConsumeAccountToken(ctx context.Context, purpose, tokenHash string) (*AccountToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	rows, err := s.db.Query(query, id)
+	query := `
+			UPDATE AccountToken t SET used_at=$3
+			FROM Account a
+			WHERE t.token_hash=$1 AND t.purpose=$2 AND t.used_at IS NULL AND t.expires_at > $3
+				AND a.user_id=t.user_id AND a.deleted_at IS NULL AND LOWER(a.email)=LOWER(t.email)
+			RETURNING t.token_id, t.user_id, t.email, t.purpose, t.token_hash, t.created_at, t.expires_at, t.used_at
+		`
 
+	t := new(AccountToken)
+	err := s.conn.QueryRowContext(ctx, query, tokenHash, purpose, time.Now().UTC()).Scan(&t.TokenID, &t.UserID, &t.Email, &t.Purpose, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt, &t.UsedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	for rows.Next() {
-		return scanIntoSubmission(rows)
-	}
+	return t, nil
+}
+
+// -- OAuth --
+func (s *PostgresStore) CreateOAuthState(ctx context.Context, st *OAuthState) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			INSERT INTO OAuthState (state, provider, code_verifier, user_id, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`
 
-	return nil, fmt.Errorf("Submission %d not found", id)
+	_, err := s.conn.ExecContext(ctx, query, st.State, st.Provider, st.CodeVerifier, st.UserID, st.CreatedAt, st.ExpiresAt)
+	return err
 }
 
-func (s *PostgresStore) GetSubmissions() ([]*Submission, error) {
-	query := `SELECT * FROM Submission`
+/* States are single use, so they are deleted as they are read */
+func (s *PostgresStore) ConsumeOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	rows, err := s.db.Query(query)
+	query := `
+			DELETE FROM OAuthState WHERE state=$1 AND expires_at > $2
+			RETURNING state, provider, code_verifier, user_id, created_at, expires_at
+		`
 
+	st := new(OAuthState)
+	err := s.conn.QueryRowContext(ctx, query, state, time.Now().UTC()).Scan(&st.State, &st.Provider, &st.CodeVerifier, &st.UserID, &st.CreatedAt, &st.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	subs := []*Submission{}
+	return st, nil
+}
 
-	for rows.Next() {
-		sub, err := scanIntoSubmission(rows)
-		if err != nil {
-			return nil, err
-		}
-		subs = append(subs, sub)
+func (s *PostgresStore) CreateAccountIdentity(ctx context.Context, id *AccountIdentity) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			INSERT INTO account_identity (user_id, provider, subject, email, created_at)
+			VALUES ($1, $2, $3, $4, $5) RETURNING identity_id
+		`
+
+	err := s.conn.QueryRowContext(ctx, query, id.UserID, id.Provider, id.Subject, id.Email, id.CreatedAt).Scan(&id.IdentityID)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrIdentityLinked
 	}
 
-	return subs, nil
+	return err
 }
 
-// -- Problem Update --
-func (s *PostgresStore) UpdateSubmission(*Submission) error {
-	return nil
-}
+func (s *PostgresStore) GetAccountByIdentity(ctx context.Context, provider, subject string) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-func scanIntoAccount(rows *sql.Rows) (*Account, error) {
-	account := new(Account)
-	err := rows.Scan(&account.UserID, &account.FirstName, &account.LastName, &account.Username, &account.Email, &account.Password, &account.CreatedAt)
+	query := `
+		SELECT ` + accountColumns + ` FROM Account
+		WHERE user_id=(SELECT user_id FROM account_identity WHERE provider=$1 AND subject=$2)
+			AND deleted_at IS NULL
+	`
+
+	account, err := scanAccount(s.conn.QueryRowContext(ctx, query, provider, subject))
+	if err == sql.ErrNoRows {
+		return nil, ErrIdentityNotFound
+	}
 
 	return account, err
 }
 
-func scanIntoAccountResponse(rows *sql.Rows) (*CreateAccountResponse, error) {
+func (s *PostgresStore) GetAccountIdentities(ctx context.Context, userID int) ([]*AccountIdentity, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT identity_id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM account_identity WHERE user_id=$1 ORDER BY identity_id
+	`
+
+	rows, err := s.conn.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
-	acc := new(Account)
 
+	identities := []*AccountIdentity{}
 	for rows.Next() {
-		err := rows.Scan(&acc.UserID, &acc.FirstName, &acc.LastName, &acc.Username, &acc.Email, &acc.Password, &acc.CreatedAt)
-
-		if err != nil {
+		id := new(AccountIdentity)
+		if err := rows.Scan(&id.IdentityID, &id.UserID, &id.Provider, &id.Subject, &id.Email, &id.CreatedAt); err != nil {
 			return nil, err
 		}
+		identities = append(identities, id)
 	}
 
-	res := &CreateAccountResponse{
-		FirstName: acc.FirstName,
-		LastName:  acc.LastName,
-		Username:  acc.Username,
-		Email:     acc.Email,
-		Password:  acc.Password, // TODO: ENCRYPT THIS
-		CreatedAt: acc.CreatedAt,
-	}
+	return identities, rows.Err()
+}
 
-	return res, nil
+/*
+ * Refuses to remove the last way an account can sign in. The account row is locked first, so
+ * two unlinks at once can't both see the other's identity as the one that is left.
+ */
+func (s *PostgresStore) DeleteAccountIdentity(ctx context.Context, userID int, provider string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return s.inTx(ctx, func(tx *PostgresStore) error {
+		var hasPassword bool
+		err := tx.conn.QueryRowContext(ctx, `SELECT COALESCE(encrypted_password, '') <> '' FROM Account WHERE user_id=$1 FOR UPDATE`, userID).Scan(&hasPassword)
+		if err == sql.ErrNoRows {
+			return ErrIdentityNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var exists bool
+		var count int
+		query := `SELECT COUNT(*) FILTER (WHERE provider=$2) > 0, COUNT(*) FROM account_identity WHERE user_id=$1`
+		if err := tx.conn.QueryRowContext(ctx, query, userID, provider).Scan(&exists, &count); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrIdentityNotFound
+		}
+		if !hasPassword && count == 1 {
+			return ErrLastLoginMethod
+		}
+
+		_, err = tx.conn.ExecContext(ctx, `DELETE FROM account_identity WHERE user_id=$1 AND provider=$2`, userID, provider)
+		return err
+	})
 }
 
-func scanIntoSubmission(rows *sql.Rows) (*Submission, error) {
-	sub := new(Submission)
-	err := rows.Scan(&sub.UserID, &sub.ProblemID, &sub.SubmittedAt, &sub.SourceCode, &sub.Language)
+// --  Problem Create --
+func (s *PostgresStore) CreateProblem(ctx context.Context, prob *Problem) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			INSERT INTO Problem (
+				problem_name,
+				prompt,
+				starter_code,
+				difficulty,
+				function_name,
+				reveal_hidden_on_failure,
+				slug,
+				status,
+				author_id
+			) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING problem_id;
+		`
+	status := prob.Status
+	if status == "" {
+		status = ProblemDraft
+	}
+
+	var problemID int
+	err := s.inTx(ctx, func(tx *PostgresStore) error {
+		err := tx.conn.QueryRowContext(ctx, query, prob.ProblemName, prob.Prompt, prob.StarterCode, prob.Difficulty, prob.FunctionName, prob.RevealHiddenOnFailure, prob.Slug, status, prob.AuthorID).Scan(&problemID)
+		if isUniqueViolation(err, "problem_slug_key") {
+			return ErrSlugTaken
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.setProblemTags(ctx, problemID, prob.Tags); err != nil {
+			return err
+		}
+		return tx.addProblemRevision(ctx, problemID, prob.AuthorID)
+	})
+	if err != nil {
+		return -1, err // -1 signifies an error occurred
+	}
+
+	return problemID, nil
+}
+
+// -- Problem Read --
+func (s *PostgresStore) GetProblemByID(ctx context.Context, id int) (*Problem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + problemColumns + ` FROM Problem WHERE problem_id=$1`
+
+	problem, err := scanProblem(s.conn.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, NotFound("problem %d not found", id)
+	}
+
+	return problem, err
+}
+
+func (s *PostgresStore) GetProblemByName(ctx context.Context, name string) (*Problem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + problemColumns + ` FROM Problem WHERE problem_name=$1`
+
+	problem, err := scanProblem(s.conn.QueryRowContext(ctx, query, name))
+	if err == sql.ErrNoRows {
+		return nil, NotFound("Problem %s not found", name)
+	}
+
+	return problem, err
+}
+
+func (s *PostgresStore) GetProblemBySlug(ctx context.Context, slug string) (*Problem, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + problemColumns + ` FROM Problem WHERE slug=$1`
+
+	problem, err := scanProblem(s.conn.QueryRowContext(ctx, query, slug))
+	if err == sql.ErrNoRows {
+		return nil, NotFound("problem %s not found", slug)
+	}
+
+	return problem, err
+}
+
+func (s *PostgresStore) GetProblems(ctx context.Context, filter ProblemFilter) (*Page[*Problem], error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	f := &queryFilter{}
+	if filter.Difficulty != 0 {
+		f.add("difficulty = ?", filter.Difficulty)
+	}
+	if filter.Tag != "" {
+		f.add("problem_id IN (SELECT pt.problem_id FROM problem_tag pt JOIN Tag t ON t.tag_id = pt.tag_id WHERE t.slug = ?)", filter.Tag)
+	}
+	if filter.SolvedBy != 0 {
+		f.add("problem_id IN (SELECT problem_id FROM Submission WHERE user_id = ? AND verdict = ?)", filter.SolvedBy, VerdictAccepted)
+	}
+	if filter.UnsolvedBy != 0 {
+		f.add("problem_id NOT IN (SELECT problem_id FROM Submission WHERE user_id = ? AND verdict = ?)", filter.UnsolvedBy, VerdictAccepted)
+	}
+	if filter.Statuses != nil {
+		f.add("status = ANY(?)", pq.Array(filter.Statuses))
+	}
+	if filter.AuthorID != 0 {
+		f.add("author_id = ?", filter.AuthorID)
+	}
+	order, err := problemOrder.sql(f, filter.PageRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + problemColumns + ` FROM Problem` + f.where() + order
+
+	rows, err := s.conn.QueryContext(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	problems, err := scanRows(rows, scanProblem)
+	if err != nil {
+		return nil, err
+	}
+	return problemOrder.page(problems, filter.PageRequest)
+}
+
+/*
+ * Full-text search over the names and prompts of published problems, best matches first. Every
+ * word has to match.
+ */
+func (s *PostgresStore) SearchProblems(ctx context.Context, query string, limit int) ([]*ProblemSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	search := `
+			SELECT ` + problemColumns + `, ts_rank(search, q)
+			FROM Problem, plainto_tsquery('english', $1) q
+			WHERE search @@ q AND status = 'published'
+			ORDER BY ts_rank(search, q) DESC, problem_id
+			LIMIT $2
+		`
+
+	rows, err := s.conn.QueryContext(ctx, search, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRows(rows, scanProblemSearchResult)
+}
+
+// -- Problem Update --
+/* Fails with ErrStaleRevision unless prob.Revision is still the current one, and bumps it */
+func (s *PostgresStore) UpdateProblem(ctx context.Context, prob *Problem, editedBy *int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			UPDATE Problem SET
+				problem_name=$3,
+				prompt=$4,
+				starter_code=$5,
+				difficulty=$6,
+				function_name=$7,
+				reveal_hidden_on_failure=$8,
+				slug=$9,
+				revision=revision+1
+			WHERE problem_id=$1 AND revision=$2
+			RETURNING revision
+		`
+
+	return s.inTx(ctx, func(tx *PostgresStore) error {
+		var revision int
+		err := tx.conn.QueryRowContext(ctx, query, prob.ProblemID, prob.Revision, prob.ProblemName, prob.Prompt, prob.StarterCode, prob.Difficulty, prob.FunctionName, prob.RevealHiddenOnFailure, prob.Slug).Scan(&revision)
+		if isUniqueViolation(err, "problem_slug_key") {
+			return ErrSlugTaken
+		}
+		if err == sql.ErrNoRows {
+			if _, err := tx.GetProblemByID(ctx, prob.ProblemID); err != nil {
+				return err
+			}
+			return ErrStaleRevision
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.setProblemTags(ctx, prob.ProblemID, prob.Tags); err != nil {
+			return err
+		}
+		prob.Revision = revision
+		return tx.addProblemRevision(ctx, prob.ProblemID, editedBy)
+	})
+}
+
+/* Records the problem as it is now as its current revision. Has to run in a transaction */
+func (s *PostgresStore) addProblemRevision(ctx context.Context, problemID int, editedBy *int) error {
+	p, err := s.GetProblemByID(ctx, problemID)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.ExecContext(ctx, `
+			INSERT INTO problem_revision (problem_id, revision, edited_by, created_at, problem)
+			VALUES ($1, $2, $3, $4, $5)
+		`, problemID, p.Revision, editedBy, time.Now().UTC(), snapshot)
+	return err
+}
+
+/* Moves the problem from `from` to `to`, and fails if it isn't `from` anymore */
+func (s *PostgresStore) SetProblemStatus(ctx context.Context, problemID int, from, to string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			UPDATE Problem SET
+				status=$3,
+				published_at=CASE WHEN $3 = 'published' THEN COALESCE(published_at, $4) ELSE published_at END
+			WHERE problem_id=$1 AND status=$2
+		`
+
+	res, err := s.conn.ExecContext(ctx, query, problemID, from, to, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	p, err := s.GetProblemByID(ctx, problemID)
+	if err != nil {
+		return err
+	}
+	return Conflict("problem %d is %s, not %s", problemID, p.Status, from)
+}
+
+/* Newest first */
+func (s *PostgresStore) GetProblemRevisions(ctx context.Context, problemID int) ([]*ProblemRevision, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `SELECT revision, edited_by, created_at, problem FROM problem_revision WHERE problem_id=$1 ORDER BY revision DESC`
+
+	rows, err := s.conn.QueryContext(ctx, query, problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRows(rows, func(row scanner) (*ProblemRevision, error) {
+		rev := new(ProblemRevision)
+		var snapshot []byte
+		if err := row.Scan(&rev.Revision, &rev.EditedBy, &rev.CreatedAt, &snapshot); err != nil {
+			return nil, err
+		}
+		return rev, json.Unmarshal(snapshot, &rev.Problem)
+	})
+}
+
+func (s *PostgresStore) SetProblemTags(ctx context.Context, problemID int, tags []string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return s.inTx(ctx, func(tx *PostgresStore) error {
+		var exists bool
+		err := tx.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM Problem WHERE problem_id=$1)`, problemID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return NotFound("problem %d not found", problemID)
+		}
+
+		return tx.setProblemTags(ctx, problemID, tags)
+	})
+}
+
+/* Replaces the problem's tags. Has to run in a transaction */
+func (s *PostgresStore) setProblemTags(ctx context.Context, problemID int, tags []string) error {
+	if _, err := s.conn.ExecContext(ctx, `DELETE FROM problem_tag WHERE problem_id=$1`, problemID); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	rows, err := s.conn.QueryContext(ctx, `
+			INSERT INTO problem_tag (problem_id, tag_id)
+			SELECT $1, tag_id FROM Tag WHERE slug = ANY($2)
+			RETURNING (SELECT slug FROM Tag WHERE Tag.tag_id = problem_tag.tag_id)
+		`, problemID, pq.Array(tags))
+	if err != nil {
+		return err
+	}
+
+	inserted, err := scanRows(rows, func(row scanner) (*string, error) {
+		slug := new(string)
+		return slug, row.Scan(slug)
+	})
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, slug := range inserted {
+		known[*slug] = true
+	}
+
+	for _, tag := range tags {
+		if !known[tag] {
+			return unknownTag(tag)
+		}
+	}
+	return nil
+}
+
+// -- Tags --
+func (s *PostgresStore) CreateTag(ctx context.Context, tag *Tag) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, `INSERT INTO Tag (slug, name) VALUES ($1, $2)`, tag.Slug, tag.Name)
+	if isUniqueViolation(err, "tag_slug_key") {
+		return ErrTagTaken
+	}
+	return err
+}
+
+func (s *PostgresStore) GetTags(ctx context.Context) ([]*Tag, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	rows, err := s.conn.QueryContext(ctx, `SELECT slug, name FROM Tag ORDER BY slug`)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRows(rows, func(row scanner) (*Tag, error) {
+		tag := new(Tag)
+		return tag, row.Scan(&tag.Slug, &tag.Name)
+	})
+}
+
+// -- Reference solutions --
+func (s *PostgresStore) SetReferenceSolution(ctx context.Context, solution *ReferenceSolution) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			INSERT INTO reference_solution (problem_id, language_id, source_code, updated_by, updated_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (problem_id, language_id) DO UPDATE SET
+				source_code=EXCLUDED.source_code,
+				updated_by=EXCLUDED.updated_by,
+				updated_at=EXCLUDED.updated_at
+		`
+
+	solution.UpdatedAt = time.Now().UTC()
+	_, err := s.conn.ExecContext(ctx, query, solution.ProblemID, solution.LanguageID, solution.SourceCode, solution.UpdatedBy, solution.UpdatedAt)
+	if isForeignKeyViolation(err) {
+		return NotFound("problem %d not found", solution.ProblemID)
+	}
+	return err
+}
+
+/* Ordered by language */
+func (s *PostgresStore) GetReferenceSolutions(ctx context.Context, problemID int) ([]*ReferenceSolution, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			SELECT problem_id, language_id, source_code, updated_by, updated_at
+			FROM reference_solution WHERE problem_id=$1 ORDER BY language_id
+		`
+
+	rows, err := s.conn.QueryContext(ctx, query, problemID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRows(rows, func(row scanner) (*ReferenceSolution, error) {
+		rs := new(ReferenceSolution)
+		return rs, row.Scan(&rs.ProblemID, &rs.LanguageID, &rs.SourceCode, &rs.UpdatedBy, &rs.UpdatedAt)
+	})
+}
+
+func (s *PostgresStore) DeleteReferenceSolution(ctx context.Context, problemID, languageID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	res, err := s.conn.ExecContext(ctx, `DELETE FROM reference_solution WHERE problem_id=$1 AND language_id=$2`, problemID, languageID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	return NotFound("problem %d has no %s reference solution", problemID, languageName(languageID))
+}
+
+// --  TestCase Create --
+func (s *PostgresStore) CreateTestCase(ctx context.Context, testcase *TestCase) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	// is_sanity_check is kept up to date with kind for readers from before kinds
+	query := `
+			INSERT INTO TestCase (problem_id, io, is_sanity_check, kind)
+			VALUES ($1, $2, $3, $4) RETURNING test_case_id
+		`
+
+	io, err := json.Marshal(testcase.IO)
+	if err != nil {
+		return -1, err
+	}
+
+	var testCaseID int
+	err = s.conn.QueryRowContext(ctx, query, testcase.ProblemID, io, testcase.Kind == TestCaseSanity, testcase.Kind).Scan(&testCaseID)
+	if isForeignKeyViolation(err) {
+		return -1, NotFound("problem %d not found", testcase.ProblemID)
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	return testCaseID, nil
+}
+
+// -- TestCase Read -- ID here is a PROBLEM id
+/* Many test cases at once, with COPY. Their ids aren't returned */
+func (s *PostgresStore) CreateTestCases(ctx context.Context, testcases []*TestCase) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	return s.inTx(ctx, func(tx *PostgresStore) error {
+		stmt, err := tx.tx.PrepareContext(ctx, pq.CopyIn("testcase", "problem_id", "io", "is_sanity_check", "kind"))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, tc := range testcases {
+			io, err := json.Marshal(tc.IO)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.ExecContext(ctx, tc.ProblemID, string(io), tc.Kind == TestCaseSanity, tc.Kind); err != nil {
+				return err
+			}
+		}
+
+		// The rows are only sent, and foreign keys checked, when the COPY is flushed
+		_, err = stmt.ExecContext(ctx)
+		if isForeignKeyViolation(err) {
+			return NotFound("problem %d not found", testcases[0].ProblemID)
+		}
+		return err
+	})
+}
+
+func (s *PostgresStore) GetTestCasesByProblemID(ctx context.Context, id int) ([]*TestCase, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + testCaseColumns + ` FROM TestCase WHERE problem_id=$1 ORDER BY test_case_id`
+
+	rows, err := s.conn.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRows(rows, scanTestCase)
+}
+
+// -- TestCase Read -- ID here is a PROBLEM id
+func (s *PostgresStore) GetTestCaseSanityChecks(ctx context.Context, id int) ([]*TestCase, error) {
+	return s.GetTestCasesByKind(ctx, id, TestCaseSanity)
+}
+
+// -- TestCase Read -- ID here is a PROBLEM id
+func (s *PostgresStore) GetTestCasesByKind(ctx context.Context, id int, kinds ...string) ([]*TestCase, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + testCaseColumns + ` FROM TestCase WHERE problem_id=$1 AND kind=ANY($2) ORDER BY test_case_id`
+
+	rows, err := s.conn.QueryContext(ctx, query, id, pq.Array(kinds))
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRows(rows, scanTestCase)
+}
+
+func (s *PostgresStore) GetTestCases(ctx context.Context, filter TestCaseFilter) (*Page[*TestCase], error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	f := &queryFilter{}
+	if filter.ProblemID != 0 {
+		f.add("problem_id = ?", filter.ProblemID)
+	}
+	if filter.Kinds != nil {
+		f.add("kind = ANY(?)", pq.Array(filter.Kinds))
+	}
+	order, err := testCaseOrder.sql(f, filter.PageRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + testCaseColumns + ` FROM TestCase` + f.where() + order
+
+	rows, err := s.conn.QueryContext(ctx, query, f.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	testCases, err := scanRows(rows, scanTestCase)
+	if err != nil {
+		return nil, err
+	}
+	return testCaseOrder.page(testCases, filter.PageRequest)
+}
+
+// -- TestCase Update --
+func (s *PostgresStore) UpdateTestCase(context.Context, *TestCase) error {
+	return nil
+}
+
+func (s *PostgresStore) DeleteTestCases(ctx context.Context, problemID int) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, `DELETE FROM TestCase WHERE problem_id=$1`, problemID)
+	return err
+}
+
+/* Sets the flag of every test case of the problem, clearing those that aren't in `flags` */
+func (s *PostgresStore) SetTestCaseFlags(ctx context.Context, problemID int, flags map[int]string) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	byID, err := json.Marshal(flags)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.ExecContext(ctx, `UPDATE TestCase SET flag=COALESCE($2::jsonb ->> test_case_id::text, '') WHERE problem_id=$1`, problemID, byID)
+	return err
+}
+
+// --  Submission Create --
+func (s *PostgresStore) CreateSubmission(ctx context.Context, sub *Submission) (*Submission, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `
+			INSERT INTO Submission (
+				user_id,
+				problem_id,
+				submitted_at,
+				source_code,
+				language,
+				runtime_ms,
+				mem_usage_kb,
+				verdict
+			) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING ` + submissionColumns
+
+	verdict := sub.Verdict
+	if verdict == "" {
+		verdict = VerdictPending
+	}
+
+	created, err := scanSubmission(s.conn.QueryRowContext(ctx, query, sub.UserID, sub.ProblemID, time.Now().UTC(), sub.SourceCode, sub.Language, sub.RuntimeMs, sub.MemUsageKb, verdict))
+	if isForeignKeyViolation(err) {
+		return nil, NotFound("problem %d not found", sub.ProblemID)
+	}
+
+	return created, err
+}
+
+// -- Submission Read --
+func (s *PostgresStore) GetSubmissionByID(ctx context.Context, id int) (*Submission, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + submissionColumns + ` FROM Submission WHERE submission_id=$1`
+
+	sub, err := scanSubmission(s.conn.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, NotFound("Submission %d not found", id)
+	}
 
 	return sub, err
 }
 
-func scanIntoTestCase(rows *sql.Rows) (*TestCase, error) {
-	tc := new(TestCase)
-	var ioData []byte
+func (s *PostgresStore) GetSubmissions(ctx context.Context, filter SubmissionFilter) (*Page[*Submission], error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
 
-	err := rows.Scan(&tc.TestCaseID, &tc.ProblemID, &tc.IsSanityCheck, &ioData)
+	f := &queryFilter{}
+	if filter.UserID != 0 {
+		f.add("user_id = ?", filter.UserID)
+	}
+	if filter.ProblemID != 0 {
+		f.add("problem_id = ?", filter.ProblemID)
+	}
+	if filter.Language != 0 {
+		f.add("language = ?", filter.Language)
+	}
+	if filter.Verdict != "" {
+		f.add("verdict = ?", filter.Verdict)
+	}
+	if !filter.From.IsZero() {
+		f.add("submitted_at >= ?", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		f.add("submitted_at < ?", filter.To.UTC())
+	}
+	order, err := submissionOrder.sql(f, filter.PageRequest)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Println(tc)
+	query := `SELECT ` + submissionColumns + ` FROM Submission` + f.where() + order
 
-	err = json.Unmarshal(ioData, &tc.IO)
+	rows, err := s.conn.QueryContext(ctx, query, f.args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return tc, nil
+	subs, err := scanRows(rows, scanSubmission)
+	if err != nil {
+		return nil, err
+	}
+	return submissionOrder.page(subs, filter.PageRequest)
+}
+
+// -- Problem Update --
+func (s *PostgresStore) UpdateSubmission(context.Context, *Submission) error {
+	return nil
+}
+
+/* The first n characters of s. Counts runes like VARCHAR(n) does, so s stays valid UTF-8 */
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+/* Translates unique violations on the account indexes into ErrUsernameTaken / ErrEmailTaken */
+func accountConflict(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+
+	switch pqErr.Constraint {
+	case "account_username_key":
+		return ErrUsernameTaken
+	case "account_email_key":
+		return ErrEmailTaken
+	}
+	return err
+}
+
+/*
+ * Row mapping. Each entity has one column list and one scan function that reads it, in the same
+ * order, so every query goes through the same mapping. Legacy columns that may hold NULL are
+ * coalesced to the zero value the struct would have anyway.
+ */
+const (
+	accountColumns = `user_id, COALESCE(first_name, ''), COALESCE(last_name, ''), COALESCE(username, ''),
+		COALESCE(email, ''), COALESCE(encrypted_password, ''), COALESCE(created_at, NOW()), role, deleted_at, email_verified`
+
+	problemColumns = `problem_id, COALESCE(problem_name, ''), COALESCE(prompt, ''), COALESCE(starter_code, ''),
+		COALESCE(difficulty, 0), COALESCE(function_name, ''), reveal_hidden_on_failure, slug, status, author_id, revision, published_at,
+		ARRAY(SELECT t.slug FROM problem_tag pt JOIN Tag t ON t.tag_id = pt.tag_id WHERE pt.problem_id = Problem.problem_id ORDER BY t.slug)`
+
+	testCaseColumns = `test_case_id, problem_id, COALESCE(io, '{}'), kind, flag`
+
+	submissionColumns = `submission_id, user_id, problem_id, submitted_at, COALESCE(source_code, ''),
+		COALESCE(language, 0), COALESCE(runtime_ms, 0), COALESCE(mem_usage_kb, 0), verdict`
+)
+
+/* Satisfied by both *sql.Row and *sql.Rows */
+type scanner interface {
+	Scan(dest ...interface{}) error
 }
 
-func scanIntoProblem(rows *sql.Rows) (*Problem, error) {
+/* Maps every remaining row with `scan` and closes `rows` */
+func scanRows[T any](rows *sql.Rows, scan func(scanner) (*T, error)) ([]*T, error) {
+	defer rows.Close()
+
+	items := []*T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+/* The password hash is dropped here so it never makes it past the store */
+func scanAccount(row scanner) (*Account, error) {
+	account, _, err := scanAccountWithPassword(row)
+	return account, err
+}
+
+func scanAccountWithPassword(row scanner) (*Account, string, error) {
+	account := new(Account)
+	var encryptedPassword string
+	err := row.Scan(&account.UserID, &account.FirstName, &account.LastName, &account.Username, &account.Email, &encryptedPassword, &account.CreatedAt, &account.Role, &account.DeletedAt, &account.EmailVerified)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return account, encryptedPassword, nil
+}
+
+func scanProblem(row scanner) (*Problem, error) {
 	p := new(Problem)
-	err := rows.Scan(&p.ProblemID, &p.Prompt, &p.StarterCode, &p.Difficulty, &p.ProblemName, &p.FunctionName)
+	if err := row.Scan(problemFields(p)...); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
 
-	return p, err
+func scanProblemSearchResult(row scanner) (*ProblemSearchResult, error) {
+	result := new(ProblemSearchResult)
+	if err := row.Scan(append(problemFields(&result.Problem), &result.Rank)...); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+/* Scan destinations for problemColumns */
+func problemFields(p *Problem) []interface{} {
+	p.Tags = []string{} // pq leaves a nil slice nil for an empty array, which would encode as null
+	return []interface{}{&p.ProblemID, &p.ProblemName, &p.Prompt, &p.StarterCode, &p.Difficulty, &p.FunctionName, &p.RevealHiddenOnFailure, &p.Slug, &p.Status, &p.AuthorID, &p.Revision, &p.PublishedAt, (*pq.StringArray)(&p.Tags)}
+}
+
+func scanTestCase(row scanner) (*TestCase, error) {
+	tc := new(TestCase)
+	var ioData []byte
+
+	err := row.Scan(&tc.TestCaseID, &tc.ProblemID, &ioData, &tc.Kind, &tc.Flag)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(ioData, &tc.IO); err != nil {
+		return nil, err
+	}
+
+	return tc, nil
+}
+
+func scanSubmission(row scanner) (*Submission, error) {
+	sub := new(Submission)
+	err := row.Scan(&sub.SubmissionID, &sub.UserID, &sub.ProblemID, &sub.SubmittedAt, &sub.SourceCode, &sub.Language, &sub.RuntimeMs, &sub.MemUsageKb, &sub.Verdict)
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
 }
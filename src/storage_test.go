@@ -0,0 +1,1142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+ * Every Storage implementation has to pass these. The Postgres run needs an empty database
+ * it is allowed to wipe, e.g.
+ *
+ *	TEST_DATABASE_URL="postgres://localhost/algoduels_test?sslmode=disable" make test
+ */
+var storageConformance = []struct {
+	name string
+	test func(*testing.T, Storage)
+}{
+	{"CreateAndGetAccount", testCreateAndGetAccount},
+	{"AccountUniqueness", testAccountUniqueness},
+	{"UpdateAccount", testUpdateAccount},
+	{"DeleteAccount", testDeleteAccount},
+	{"AccountPasswords", testAccountPasswords},
+	{"UpdateAccountRole", testUpdateAccountRole},
+	{"RefreshTokenRotation", testRefreshTokenRotation},
+	{"Sessions", testSessions},
+	{"LoginFailureStats", testLoginFailureStats},
+	{"AccountTokens", testAccountTokens},
+	{"OAuthStates", testOAuthStates},
+	{"AccountIdentities", testAccountIdentities},
+	{"ProblemsAndTestCases", testProblemsAndTestCases},
+	{"Submissions", testSubmissions},
+	{"Pagination", testPagination},
+	{"TagsAndSearch", testTagsAndSearch},
+	{"ListFilters", testListFilters},
+	{"ProblemWorkflow", testProblemWorkflow},
+	{"ReferenceSolutions", testReferenceSolutions},
+	{"WithTx", testWithTx},
+}
+
+func runStorageConformance(t *testing.T, newStore func(*testing.T) Storage) {
+	for _, c := range storageConformance {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.test(t, newStore(t))
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	runStorageConformance(t, func(*testing.T) Storage {
+		return NewMemoryStore()
+	})
+}
+
+func TestPostgresStore(t *testing.T) {
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	store, err := OpenPostgresStore(connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+
+	runStorageConformance(t, func(t *testing.T) Storage {
+		_, err := store.db.Exec(`
+			TRUNCATE Account, RefreshToken, AccountToken, OAuthState, account_identity,
+				LoginAttempt, Problem, TestCase, Submission, Tag, problem_tag, problem_revision,
+				reference_solution
+			RESTART IDENTITY CASCADE
+		`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+const testPassword = "correct horse battery"
+
+func mustCreateAccount(t *testing.T, s Storage, username string) *Account {
+	t.Helper()
+	account, err := s.CreateAccount(context.Background(), NewAccountRequest(username, "First", "Last", username+"@example.com", testPassword))
+	if err != nil {
+		t.Fatalf("CreateAccount(%s): %v", username, err)
+	}
+	return account
+}
+
+func mustCreateProblem(t *testing.T, s Storage, name string) int {
+	t.Helper()
+	id, err := s.CreateProblem(context.Background(), NewProblem(name, "Prompt", "function f(a) {}", "f", 1))
+	if err != nil {
+		t.Fatalf("CreateProblem(%s): %v", name, err)
+	}
+	return id
+}
+
+func newRefreshToken(userID int, familyID string, expiresIn time.Duration) *RefreshToken {
+	now := time.Now().UTC()
+	return &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(randomToken(32)),
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiresIn),
+		IP:        "127.0.0.1",
+		UserAgent: "test",
+	}
+}
+
+func testCreateAndGetAccount(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	first := mustCreateAccount(t, s, "alice")
+	second := mustCreateAccount(t, s, "bob")
+
+	if first.UserID != 1 || second.UserID != 2 {
+		t.Fatalf("expected IDs 1 and 2, got %d and %d", first.UserID, second.UserID)
+	}
+	if first.Role != RolePlayer || first.EmailVerified || first.DeletedAt != nil {
+		t.Fatalf("unexpected defaults on new account: %+v", first)
+	}
+
+	got, err := s.GetAccountByID(ctx, first.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != "alice" || got.Email != "alice@example.com" || got.FirstName != "First" {
+		t.Fatalf("GetAccountByID returned %+v", got)
+	}
+
+	if got, err := s.GetAccountByUsername(ctx, "ALICE"); err != nil || got.UserID != first.UserID {
+		t.Fatalf("GetAccountByUsername is not case-insensitive: %v, %v", got, err)
+	}
+	if got, err := s.GetAccountByEmail(ctx, "Bob@Example.com"); err != nil || got.UserID != second.UserID {
+		t.Fatalf("GetAccountByEmail is not case-insensitive: %v, %v", got, err)
+	}
+
+	if _, err := s.GetAccountByID(ctx, 999); err == nil {
+		t.Fatal("expected an error for a missing account")
+	}
+	if _, err := s.GetAccountByUsername(ctx, "nobody"); err == nil {
+		t.Fatal("expected an error for a missing username")
+	}
+
+	accounts, err := s.GetAccounts(ctx, PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts.Items) != 2 || accounts.Items[0].UserID != first.UserID || accounts.Items[1].UserID != second.UserID {
+		t.Fatalf("GetAccounts returned %d accounts", len(accounts.Items))
+	}
+}
+
+func testAccountUniqueness(t *testing.T, s Storage) {
+	ctx := context.Background()
+	mustCreateAccount(t, s, "alice")
+
+	_, err := s.CreateAccount(ctx, NewAccountRequest("Alice", "", "", "other@example.com", testPassword))
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("expected ErrUsernameTaken, got %v", err)
+	}
+
+	_, err = s.CreateAccount(ctx, NewAccountRequest("other", "", "", "ALICE@example.com", testPassword))
+	if !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken, got %v", err)
+	}
+}
+
+func testUpdateAccount(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+	mustCreateAccount(t, s, "bob")
+
+	if err := s.MarkEmailVerified(ctx, alice.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	alice.FirstName = "Alicia"
+	alice.Email = "ALICE@example.com"
+	if err := s.UpdateAccount(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := s.GetAccountByID(ctx, alice.UserID)
+	if got.FirstName != "Alicia" || !got.EmailVerified {
+		t.Fatalf("changing only the case of the email should keep it verified: %+v", got)
+	}
+
+	alice.Email = "alicia@example.com"
+	if err := s.UpdateAccount(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetAccountByID(ctx, alice.UserID); got.EmailVerified {
+		t.Fatal("changing the email should clear email_verified")
+	}
+
+	alice.Username = "BOB"
+	if err := s.UpdateAccount(ctx, alice); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("expected ErrUsernameTaken, got %v", err)
+	}
+
+	if err := s.UpdateAccount(ctx, &Account{UserID: 999, Username: "ghost", Email: "ghost@example.com"}); err == nil {
+		t.Fatal("expected an error updating a missing account")
+	}
+}
+
+func testDeleteAccount(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+
+	token := newRefreshToken(alice.UserID, "family-a", time.Hour)
+	if err := s.CreateRefreshToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	identity := &AccountIdentity{UserID: alice.UserID, Provider: "github", Subject: "42", CreatedAt: time.Now().UTC()}
+	if err := s.CreateAccountIdentity(ctx, identity); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteAccount(ctx, alice.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteAccount(ctx, alice.UserID); err == nil {
+		t.Fatal("deleting twice should fail")
+	}
+
+	if _, err := s.GetAccountByID(ctx, alice.UserID); err == nil {
+		t.Fatal("deleted accounts should not be found")
+	}
+	if _, err := s.GetAccountByIdentity(ctx, "github", "42"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Fatalf("expected the identity to be unlinked, got %v", err)
+	}
+	if _, err := s.ConsumeRefreshToken(ctx, token.TokenHash); err == nil {
+		t.Fatal("refresh tokens of a deleted account should be revoked")
+	}
+	if _, err := s.VerifyAccountPassword(ctx, "alice", testPassword); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("deleted accounts should not be able to sign in, got %v", err)
+	}
+
+	// The username and email are free again
+	if again := mustCreateAccount(t, s, "alice"); again.UserID == alice.UserID {
+		t.Fatal("expected a new account")
+	}
+}
+
+func testAccountPasswords(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+
+	if got, err := s.VerifyAccountPassword(ctx, "Alice", testPassword); err != nil || got.UserID != alice.UserID {
+		t.Fatalf("VerifyAccountPassword: %v, %v", got, err)
+	}
+	if _, err := s.VerifyAccountPassword(ctx, "alice", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := s.VerifyAccountPassword(ctx, "nobody", testPassword); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+
+	if err := s.ChangeAccountPassword(ctx, alice.UserID, "wrong password", "new password 1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if err := s.ChangeAccountPassword(ctx, alice.UserID, testPassword, "new password 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyAccountPassword(ctx, "alice", "new password 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	token := newRefreshToken(alice.UserID, "family-a", time.Hour)
+	if err := s.CreateRefreshToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetAccountPassword(ctx, alice.UserID, "new password 2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyAccountPassword(ctx, "alice", "new password 2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConsumeRefreshToken(ctx, token.TokenHash); err == nil {
+		t.Fatal("a password reset should revoke every session")
+	}
+
+	// OAuth accounts have no password and can't sign in with one
+	oauth, err := s.CreateAccount(ctx, NewAccountRequest("carol", "", "", "carol@example.com", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyAccountPassword(ctx, oauth.Username, ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func testUpdateAccountRole(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+
+	if err := s.UpdateAccountRole(ctx, alice.UserID, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetAccountByID(ctx, alice.UserID); got.Role != RoleAdmin {
+		t.Fatalf("expected role %s, got %s", RoleAdmin, got.Role)
+	}
+	if err := s.UpdateAccountRole(ctx, 999, RoleAdmin); err == nil {
+		t.Fatal("expected an error for a missing account")
+	}
+}
+
+func testRefreshTokenRotation(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+
+	first := newRefreshToken(alice.UserID, "family-a", time.Hour)
+	if err := s.CreateRefreshToken(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if first.TokenID == 0 {
+		t.Fatal("CreateRefreshToken should set TokenID")
+	}
+
+	consumed, err := s.ConsumeRefreshToken(ctx, first.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consumed.UserID != alice.UserID || consumed.FamilyID != "family-a" || consumed.RevokedAt == nil {
+		t.Fatalf("ConsumeRefreshToken returned %+v", consumed)
+	}
+
+	second := newRefreshToken(alice.UserID, "family-a", time.Hour)
+	if err := s.CreateRefreshToken(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Presenting the rotated token again revokes the whole family
+	if _, err := s.ConsumeRefreshToken(ctx, first.TokenHash); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := s.ConsumeRefreshToken(ctx, second.TokenHash); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected the family to be revoked, got %v", err)
+	}
+
+	expired := newRefreshToken(alice.UserID, "family-b", -time.Minute)
+	if err := s.CreateRefreshToken(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConsumeRefreshToken(ctx, expired.TokenHash); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected ErrRefreshTokenInvalid for an expired token, got %v", err)
+	}
+	if _, err := s.ConsumeRefreshToken(ctx, hashToken("unknown")); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected ErrRefreshTokenInvalid for an unknown token, got %v", err)
+	}
+
+	logout := newRefreshToken(alice.UserID, "family-c", time.Hour)
+	if err := s.CreateRefreshToken(ctx, logout); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeRefreshToken(ctx, logout.TokenHash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConsumeRefreshToken(ctx, logout.TokenHash); err == nil {
+		t.Fatal("a revoked token should not be usable")
+	}
+}
+
+func testSessions(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+	bob := mustCreateAccount(t, s, "bob")
+
+	older := newRefreshToken(alice.UserID, "family-a", time.Hour)
+	older.CreatedAt = older.CreatedAt.Add(-time.Minute)
+	newer := newRefreshToken(alice.UserID, "family-b", time.Hour)
+	newer.IP = "10.0.0.1"
+	newer.UserAgent = strings.Repeat("Мозилла/5.0 ", 30)
+	for _, token := range []*RefreshToken{older, newer, newRefreshToken(bob.UserID, "family-c", time.Hour)} {
+		if err := s.CreateRefreshToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := s.GetSessions(ctx, alice.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].SessionID != "family-b" || sessions[0].IP != "10.0.0.1" {
+		t.Fatalf("expected both sessions newest first, got %+v", sessions)
+	}
+	if agent := sessions[0].UserAgent; utf8.RuneCountInString(agent) != 255 || !utf8.ValidString(agent) {
+		t.Fatalf("expected the user agent cut to 255 characters, got %q", agent)
+	}
+
+	if err := s.RevokeSession(ctx, alice.UserID, "family-c"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking another account's session should fail, got %v", err)
+	}
+	if err := s.RevokeSession(ctx, alice.UserID, "family-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeSession(ctx, alice.UserID, "family-a"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	sessions, _ = s.GetSessions(ctx, alice.UserID)
+	if len(sessions) != 1 || sessions[0].SessionID != "family-b" {
+		t.Fatalf("expected only family-b to remain, got %+v", sessions)
+	}
+}
+
+func testLoginFailureStats(t *testing.T, s Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	since := now.Add(-loginFailureWindow)
+
+	record := func(username, ip string, success bool, at time.Time) {
+		t.Helper()
+		if err := s.RecordLoginAttempt(ctx, &LoginAttempt{Username: username, IP: ip, UserAgent: "test", Success: success, AttemptedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	record("alice", "10.0.0.1", false, now.Add(-time.Hour)) // outside the window
+	record("alice", "10.0.0.1", false, now.Add(-5*time.Minute))
+	record("alice", "10.0.0.1", true, now.Add(-4*time.Minute))
+	record("alice", "10.0.0.2", false, now.Add(-3*time.Minute))
+	record("bob", "10.0.0.1", false, now.Add(-2*time.Minute))
+
+	stats, err := s.GetLoginFailureStats(ctx, "Alice", "10.0.0.1", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.AccountFailures != 1 {
+		t.Errorf("expected 1 account failure since the last success, got %d", stats.AccountFailures)
+	}
+	if stats.IPFailures != 2 {
+		t.Errorf("expected 2 failures from the IP, got %d", stats.IPFailures)
+	}
+	if d := stats.AccountLastFailure.Sub(now.Add(-3 * time.Minute)); d < -time.Millisecond || d > time.Millisecond {
+		t.Errorf("unexpected AccountLastFailure %v", stats.AccountLastFailure)
+	}
+
+	stats, _ = s.GetLoginFailureStats(ctx, "carol", "10.0.0.9", since)
+	if stats.AccountFailures != 0 || stats.IPFailures != 0 {
+		t.Errorf("expected no failures, got %+v", stats)
+	}
+}
+
+func testAccountTokens(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+	now := time.Now().UTC()
+
+	token := &AccountToken{UserID: alice.UserID, Email: alice.Email, Purpose: tokenPurposeVerifyEmail, TokenHash: hashToken("verify"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := s.CreateAccountToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ConsumeAccountToken(ctx, tokenPurposeResetPassword, token.TokenHash); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("a token should only work for its purpose, got %v", err)
+	}
+
+	got, err := s.ConsumeAccountToken(ctx, tokenPurposeVerifyEmail, token.TokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != alice.UserID || got.UsedAt == nil {
+		t.Fatalf("ConsumeAccountToken returned %+v", got)
+	}
+
+	if _, err := s.ConsumeAccountToken(ctx, tokenPurposeVerifyEmail, token.TokenHash); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("tokens are single use, got %v", err)
+	}
+
+	expired := &AccountToken{UserID: alice.UserID, Email: alice.Email, Purpose: tokenPurposeVerifyEmail, TokenHash: hashToken("expired"), CreatedAt: now, ExpiresAt: now.Add(-time.Minute)}
+	if err := s.CreateAccountToken(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConsumeAccountToken(ctx, tokenPurposeVerifyEmail, expired.TokenHash); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for an expired token, got %v", err)
+	}
+
+	reset := &AccountToken{UserID: alice.UserID, Email: alice.Email, Purpose: tokenPurposeResetPassword, TokenHash: hashToken("reset"), CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := s.CreateAccountToken(ctx, reset); err != nil {
+		t.Fatal(err)
+	}
+	alice.Email = "alice@example.org"
+	if err := s.UpdateAccount(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConsumeAccountToken(ctx, tokenPurposeResetPassword, reset.TokenHash); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("a token sent to the old email should stop working, got %v", err)
+	}
+}
+
+func testOAuthStates(t *testing.T, s Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	state := &OAuthState{State: "state-1", Provider: "github", CodeVerifier: "verifier", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+	if err := s.CreateOAuthState(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.ConsumeOAuthState(ctx, "state-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Provider != "github" || got.CodeVerifier != "verifier" || got.UserID != nil {
+		t.Fatalf("ConsumeOAuthState returned %+v", got)
+	}
+	if _, err := s.ConsumeOAuthState(ctx, "state-1"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("states are single use, got %v", err)
+	}
+
+	expired := &OAuthState{State: "state-2", Provider: "github", CodeVerifier: "verifier", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)}
+	if err := s.CreateOAuthState(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConsumeOAuthState(ctx, "state-2"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for an expired state, got %v", err)
+	}
+}
+
+func testAccountIdentities(t *testing.T, s Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	alice := mustCreateAccount(t, s, "alice")
+	carol, err := s.CreateAccount(ctx, NewAccountRequest("carol", "", "", "carol@example.com", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := func(userID int, provider, subject string) error {
+		return s.CreateAccountIdentity(ctx, &AccountIdentity{UserID: userID, Provider: provider, Subject: subject, Email: "x@example.com", CreatedAt: now})
+	}
+
+	if err := link(alice.UserID, "github", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := link(carol.UserID, "github", "1"); !errors.Is(err, ErrIdentityLinked) {
+		t.Fatalf("an identity can only belong to one account, got %v", err)
+	}
+	if err := link(alice.UserID, "github", "2"); !errors.Is(err, ErrIdentityLinked) {
+		t.Fatalf("one identity per provider and account, got %v", err)
+	}
+	if err := link(carol.UserID, "google", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetAccountByIdentity(ctx, "github", "1")
+	if err != nil || got.UserID != alice.UserID {
+		t.Fatalf("GetAccountByIdentity: %v, %v", got, err)
+	}
+	if _, err := s.GetAccountByIdentity(ctx, "google", "1"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Fatalf("expected ErrIdentityNotFound, got %v", err)
+	}
+
+	identities, err := s.GetAccountIdentities(ctx, alice.UserID)
+	if err != nil || len(identities) != 1 || identities[0].Provider != "github" {
+		t.Fatalf("GetAccountIdentities: %+v, %v", identities, err)
+	}
+
+	// carol has no password, so google is her only way in
+	if err := s.DeleteAccountIdentity(ctx, carol.UserID, "google"); !errors.Is(err, ErrLastLoginMethod) {
+		t.Fatalf("expected ErrLastLoginMethod, got %v", err)
+	}
+	if err := s.DeleteAccountIdentity(ctx, alice.UserID, "google"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Fatalf("expected ErrIdentityNotFound, got %v", err)
+	}
+	if err := s.DeleteAccountIdentity(ctx, alice.UserID, "github"); err != nil {
+		t.Fatal(err)
+	}
+	if identities, _ := s.GetAccountIdentities(ctx, alice.UserID); len(identities) != 0 {
+		t.Fatalf("expected no identities left, got %+v", identities)
+	}
+}
+
+func testProblemsAndTestCases(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	twoSum := mustCreateProblem(t, s, "two-sum")
+	other := mustCreateProblem(t, s, "other")
+
+	got, err := s.GetProblemByID(ctx, twoSum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ProblemName != "two-sum" || got.FunctionName != "f" || got.Difficulty != 1 || got.Prompt != "Prompt" {
+		t.Fatalf("GetProblemByID returned %+v", got)
+	}
+	if got, err := s.GetProblemByName(ctx, "other"); err != nil || got.ProblemID != other {
+		t.Fatalf("GetProblemByName: %v, %v", got, err)
+	}
+	if _, err := s.GetProblemByID(ctx, 999); err == nil {
+		t.Fatal("expected an error for a missing problem")
+	}
+	if problems, _ := s.GetProblems(ctx, ProblemFilter{}); len(problems.Items) != 2 {
+		t.Fatalf("expected 2 problems, got %d", len(problems.Items))
+	}
+
+	kinds := []string{TestCaseExample, TestCaseSanity, TestCaseHidden, TestCaseHidden}
+	for i, kind := range kinds {
+		tc := NewTestCase(twoSum, map[string]interface{}{"n": float64(i)}, float64(i*2), kind)
+		if _, err := s.CreateTestCase(ctx, tc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.CreateTestCase(ctx, NewTestCase(other, map[string]interface{}{}, nil, TestCaseHidden)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateTestCase(ctx, NewTestCase(999, map[string]interface{}{}, nil, TestCaseHidden)); err == nil {
+		t.Fatal("test cases need an existing problem")
+	}
+
+	all, err := s.GetTestCasesByProblemID(ctx, twoSum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("expected 4 test cases, got %d", len(all))
+	}
+	if all[1].Kind != TestCaseSanity || all[1].IO.Input["n"] != float64(1) || all[1].IO.Output != float64(2) {
+		t.Fatalf("test case did not round trip: %+v", all[1])
+	}
+
+	public, _ := s.GetTestCasesByKind(ctx, twoSum, publicTestCaseKinds...)
+	if len(public) != 2 {
+		t.Fatalf("expected 2 public test cases, got %d", len(public))
+	}
+	if sanity, _ := s.GetTestCaseSanityChecks(ctx, twoSum); len(sanity) != 1 || sanity[0].Kind != TestCaseSanity {
+		t.Fatalf("GetTestCaseSanityChecks returned %+v", sanity)
+	}
+	if every, _ := s.GetTestCases(ctx, TestCaseFilter{}); len(every.Items) != 5 {
+		t.Fatalf("expected 5 test cases in total, got %d", len(every.Items))
+	}
+	hidden, _ := s.GetTestCases(ctx, TestCaseFilter{ProblemID: twoSum, Kinds: []string{TestCaseHidden}})
+	if len(hidden.Items) != 2 || hidden.Items[0].Kind != TestCaseHidden {
+		t.Fatalf("expected 2 hidden test cases, got %+v", hidden.Items)
+	}
+
+	if err := s.DeleteTestCases(ctx, twoSum); err != nil {
+		t.Fatal(err)
+	}
+	if every, _ := s.GetTestCases(ctx, TestCaseFilter{}); len(every.Items) != 1 || every.Items[0].ProblemID != other {
+		t.Fatalf("expected only the other problem's test case left, got %+v", every.Items)
+	}
+
+	bulk := []*TestCase{
+		NewTestCase(twoSum, map[string]interface{}{"n": float64(1)}, float64(2), TestCaseSanity),
+		NewTestCase(twoSum, map[string]interface{}{"n": float64(2)}, float64(4), TestCaseHidden),
+	}
+	if err := s.CreateTestCases(ctx, bulk); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := s.GetTestCasesByProblemID(ctx, twoSum); len(all) != 2 || all[0].Kind != TestCaseSanity || all[1].IO.Output != float64(4) {
+		t.Fatalf("CreateTestCases stored %+v", all)
+	}
+	if err := s.CreateTestCases(ctx, []*TestCase{NewTestCase(999, map[string]interface{}{}, nil, TestCaseHidden)}); !hasCode(err, CodeNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func testSubmissions(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+	problemID := mustCreateProblem(t, s, "two-sum")
+
+	sub, err := s.CreateSubmission(ctx, &Submission{UserID: alice.UserID, ProblemID: problemID, SourceCode: "function f() {}", Language: 63, RuntimeMs: 12, MemUsageKb: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.SubmissionID != 1 || sub.SubmittedAt.IsZero() || sub.Verdict != VerdictPending {
+		t.Fatalf("CreateSubmission returned %+v", sub)
+	}
+
+	got, err := s.GetSubmissionByID(ctx, sub.SubmissionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != alice.UserID || got.ProblemID != problemID || got.SourceCode != "function f() {}" || got.Language != 63 || got.RuntimeMs != 12 || got.MemUsageKb != 256 {
+		t.Fatalf("GetSubmissionByID returned %+v", got)
+	}
+
+	if _, err := s.GetSubmissionByID(ctx, 999); err == nil {
+		t.Fatal("expected an error for a missing submission")
+	}
+	if _, err := s.CreateSubmission(ctx, &Submission{UserID: alice.UserID, ProblemID: 999}); err == nil {
+		t.Fatal("submissions need an existing problem")
+	}
+	if subs, _ := s.GetSubmissions(ctx, SubmissionFilter{}); len(subs.Items) != 1 {
+		t.Fatalf("expected 1 submission, got %d", len(subs.Items))
+	}
+}
+
+func testWithTx(t *testing.T, s Storage) {
+	ctx := context.Background()
+	errAbort := fmt.Errorf("abort")
+
+	err := s.WithTx(ctx, func(tx Storage) error {
+		id := mustCreateProblem(t, tx, "rolled-back")
+		if _, err := tx.CreateTestCase(ctx, NewTestCase(id, map[string]interface{}{}, nil, TestCaseHidden)); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx should return fn's error, got %v", err)
+	}
+	if problems, _ := s.GetProblems(ctx, ProblemFilter{}); len(problems.Items) != 0 {
+		t.Fatalf("expected the problem to be rolled back, got %d problems", len(problems.Items))
+	}
+	if testCases, _ := s.GetTestCases(ctx, TestCaseFilter{}); len(testCases.Items) != 0 {
+		t.Fatalf("expected the test case to be rolled back, got %d", len(testCases.Items))
+	}
+
+	var problemID int
+	err = s.WithTx(ctx, func(tx Storage) error {
+		problemID = mustCreateProblem(t, tx, "committed")
+
+		// Nested calls join the outer transaction
+		return tx.WithTx(ctx, func(inner Storage) error {
+			_, err := inner.CreateTestCase(ctx, NewTestCase(problemID, map[string]interface{}{}, nil, TestCaseHidden))
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetProblemByID(ctx, problemID); err != nil {
+		t.Fatal(err)
+	}
+	if testCases, _ := s.GetTestCasesByProblemID(ctx, problemID); len(testCases) != 1 {
+		t.Fatalf("expected the test case to be committed, got %d", len(testCases))
+	}
+}
+
+func testPagination(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	// Names and difficulties in an order of their own, with ties, so every sort key differs from id
+	names := []string{"d", "b", "e", "a", "c", "b2", "a2"}
+	for i, name := range names {
+		if _, err := s.CreateProblem(ctx, NewProblem(name, "Prompt", "", "f", uint8(i%3+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []string{"id", "name", "difficulty"} {
+		for _, desc := range []bool{false, true} {
+			all, err := s.GetProblems(ctx, ProblemFilter{PageRequest: PageRequest{Sort: sort, Desc: desc}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all.Items) != len(names) || all.NextCursor != nil {
+				t.Fatalf("%s: expected one page of %d, got %d", sort, len(names), len(all.Items))
+			}
+
+			// Walking pages of 3 has to visit the same problems in the same order
+			var walked []*Problem
+			page := PageRequest{Limit: 3, Sort: sort, Desc: desc}
+			for pages := 0; ; pages++ {
+				got, err := s.GetProblems(ctx, ProblemFilter{PageRequest: page})
+				if err != nil {
+					t.Fatal(err)
+				}
+				walked = append(walked, got.Items...)
+				if got.NextCursor == nil {
+					break
+				}
+				if pages > len(names) {
+					t.Fatalf("%s: paging does not end", sort)
+				}
+				if page.After, err = decodeCursor(*got.NextCursor); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if len(walked) != len(all.Items) {
+				t.Fatalf("%s desc=%v: walked %d problems, expected %d", sort, desc, len(walked), len(all.Items))
+			}
+			for i := range walked {
+				if walked[i].ProblemID != all.Items[i].ProblemID {
+					t.Fatalf("%s desc=%v: problem %d is %d when paging, %d otherwise", sort, desc, i, walked[i].ProblemID, all.Items[i].ProblemID)
+				}
+			}
+		}
+	}
+
+	byName, _ := s.GetProblems(ctx, ProblemFilter{PageRequest: PageRequest{Sort: "name", Limit: 2}})
+	if byName.Items[0].ProblemName != "a" || byName.Items[1].ProblemName != "a2" {
+		t.Fatalf("expected a, a2 first by name, got %s, %s", byName.Items[0].ProblemName, byName.Items[1].ProblemName)
+	}
+	if _, err := s.GetProblems(ctx, ProblemFilter{PageRequest: PageRequest{Sort: "prompt"}}); err == nil {
+		t.Fatal("expected an error for an unknown sort key")
+	}
+}
+
+func testTagsAndSearch(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	for _, tag := range []*Tag{{Slug: "graphs", Name: "Graphs"}, {Slug: "arrays", Name: "Arrays"}} {
+		if err := s.CreateTag(ctx, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CreateTag(ctx, &Tag{Slug: "graphs", Name: "Again"}); !errors.Is(err, ErrTagTaken) {
+		t.Fatalf("expected ErrTagTaken, got %v", err)
+	}
+	if tags, _ := s.GetTags(ctx); len(tags) != 2 || tags[0].Slug != "arrays" {
+		t.Fatalf("GetTags returned %+v", tags)
+	}
+
+	paths := NewProblem("Shortest Paths", "Find the shortest route between two cities in a graph", "", "f", 2)
+	paths.Tags = []string{"graphs", "arrays", "graphs"}
+	paths.Status = ProblemPublished
+	pathsID, err := s.CreateProblem(ctx, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := NewProblem("Two Sum", "Find two numbers in an array that add up to a target, the shortest way", "", "f", 1)
+	sum.Status = ProblemPublished
+	sumID, err := s.CreateProblem(ctx, sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetProblemBySlug(ctx, "shortest-paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ProblemID != pathsID || fmt.Sprint(got.Tags) != "[arrays graphs]" {
+		t.Fatalf("GetProblemBySlug returned %+v", got)
+	}
+	if _, err := s.CreateProblem(ctx, NewProblem("two sum", "", "", "f", 1)); !errors.Is(err, ErrSlugTaken) {
+		t.Fatalf("expected ErrSlugTaken, got %v", err)
+	}
+	unknown := NewProblem("Unknown", "", "", "f", 1)
+	unknown.Tags = []string{"dp"}
+	if _, err := s.CreateProblem(ctx, unknown); err == nil {
+		t.Fatal("expected an error for an unknown tag")
+	}
+
+	if err := s.SetProblemTags(ctx, sumID, []string{"arrays"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetProblemTags(ctx, 999, []string{"arrays"}); err == nil {
+		t.Fatal("expected an error for a missing problem")
+	}
+	if err := s.SetProblemTags(ctx, sumID, []string{"arrays", "dp"}); err == nil {
+		t.Fatal("expected an error for an unknown tag")
+	}
+	tagged, _ := s.GetProblems(ctx, ProblemFilter{Tag: "arrays"})
+	if len(tagged.Items) != 2 {
+		t.Fatalf("expected both problems to be tagged arrays, got %d", len(tagged.Items))
+	}
+	if graphs, _ := s.GetProblems(ctx, ProblemFilter{Tag: "graphs"}); len(graphs.Items) != 1 || graphs.Items[0].ProblemID != pathsID {
+		t.Fatalf("expected only shortest paths to be tagged graphs, got %+v", graphs.Items)
+	}
+
+	// A match in the name ranks above one in the prompt, and every word has to match
+	results, err := s.SearchProblems(ctx, "shortest", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].ProblemID != pathsID || results[0].Rank <= results[1].Rank {
+		t.Fatalf("expected shortest paths first, got %+v", results)
+	}
+	if results, _ := s.SearchProblems(ctx, "shortest target", 10); len(results) != 1 || results[0].ProblemID != sumID {
+		t.Fatalf("expected only two sum to match both words, got %+v", results)
+	}
+	if results, _ := s.SearchProblems(ctx, "shortest", 1); len(results) != 1 {
+		t.Fatalf("expected the limit to apply, got %d results", len(results))
+	}
+	if results, _ := s.SearchProblems(ctx, "knapsack", 10); len(results) != 0 {
+		t.Fatalf("expected no results, got %+v", results)
+	}
+}
+
+func testListFilters(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+	bob := mustCreateAccount(t, s, "bob")
+
+	easy := mustCreateProblem(t, s, "easy")
+	hard, err := s.CreateProblem(ctx, NewProblem("hard", "Prompt", "", "f", uint8(difficulties.Hard)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submissions := []Submission{
+		{UserID: alice.UserID, ProblemID: easy, Language: 71, Verdict: VerdictWrongAnswer},
+		{UserID: alice.UserID, ProblemID: easy, Language: 71, Verdict: VerdictAccepted},
+		{UserID: alice.UserID, ProblemID: hard, Language: 63},
+		{UserID: bob.UserID, ProblemID: hard, Language: 63, Verdict: VerdictAccepted},
+	}
+	for i := range submissions {
+		if _, err := s.CreateSubmission(ctx, &submissions[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	problemIDs := func(filter ProblemFilter) []int {
+		t.Helper()
+		page, err := s.GetProblems(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, p := range page.Items {
+			ids = append(ids, p.ProblemID)
+		}
+		return ids
+	}
+	problemCases := []struct {
+		filter ProblemFilter
+		want   []int
+	}{
+		{ProblemFilter{Difficulty: int(difficulties.Hard)}, []int{hard}},
+		{ProblemFilter{SolvedBy: alice.UserID}, []int{easy}},
+		{ProblemFilter{UnsolvedBy: alice.UserID}, []int{hard}},
+		{ProblemFilter{SolvedBy: bob.UserID, Difficulty: int(difficulties.Easy)}, []int{}},
+	}
+	for _, c := range problemCases {
+		if got := problemIDs(c.filter); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("GetProblems(%+v) = %v, want %v", c.filter, got, c.want)
+		}
+	}
+
+	count := func(filter SubmissionFilter) int {
+		t.Helper()
+		page, err := s.GetSubmissions(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(page.Items)
+	}
+	now := time.Now().UTC()
+	submissionCases := []struct {
+		filter SubmissionFilter
+		want   int
+	}{
+		{SubmissionFilter{UserID: alice.UserID}, 3},
+		{SubmissionFilter{ProblemID: hard}, 2},
+		{SubmissionFilter{Language: 71}, 2},
+		{SubmissionFilter{Verdict: VerdictAccepted}, 2},
+		{SubmissionFilter{Verdict: VerdictPending}, 1},
+		{SubmissionFilter{UserID: bob.UserID, Verdict: VerdictAccepted}, 1},
+		{SubmissionFilter{From: now.Add(-time.Hour), To: now.Add(time.Hour)}, 4},
+		{SubmissionFilter{From: now.Add(time.Hour)}, 0},
+		{SubmissionFilter{To: now.Add(-time.Hour)}, 0},
+	}
+	for _, c := range submissionCases {
+		if got := count(c.filter); got != c.want {
+			t.Errorf("GetSubmissions(%+v) returned %d, want %d", c.filter, got, c.want)
+		}
+	}
+
+	newest, _ := s.GetSubmissions(ctx, SubmissionFilter{PageRequest: PageRequest{Sort: "submitted_at", Desc: true, Limit: 1}})
+	if len(newest.Items) != 1 || newest.Items[0].UserID != bob.UserID || newest.NextCursor == nil {
+		t.Fatalf("expected bob's submission first, newest first, got %+v", newest.Items)
+	}
+}
+
+func testProblemWorkflow(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+	bob := mustCreateAccount(t, s, "bob")
+
+	draft := NewProblem("Draft", "A draft of a problem", "", "f", 1)
+	draft.AuthorID = &alice.UserID
+	id, err := s.CreateProblem(ctx, draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := mustCreateProblem(t, s, "Other")
+
+	got, err := s.GetProblemByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != ProblemDraft || got.Revision != 1 || got.AuthorID == nil || *got.AuthorID != alice.UserID || got.PublishedAt != nil {
+		t.Fatalf("GetProblemByID returned %+v", got)
+	}
+
+	// Edits bump the revision, and edits made on an older one are refused
+	stale := *got
+	got.ProblemName = "Draft v2"
+	if err := s.UpdateProblem(ctx, got, &bob.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if got.Revision != 2 {
+		t.Fatalf("expected revision 2, got %d", got.Revision)
+	}
+	if err := s.UpdateProblem(ctx, &stale, &bob.UserID); !errors.Is(err, ErrStaleRevision) {
+		t.Fatalf("expected ErrStaleRevision, got %v", err)
+	}
+	got.Slug = "other"
+	if err := s.UpdateProblem(ctx, got, &bob.UserID); !errors.Is(err, ErrSlugTaken) {
+		t.Fatalf("expected ErrSlugTaken, got %v", err)
+	}
+	if err := s.UpdateProblem(ctx, &Problem{ProblemID: 999, Revision: 1, Slug: "missing"}, &bob.UserID); !hasCode(err, CodeNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	revisions, err := s.GetProblemRevisions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Revision != 2 || revisions[0].Problem.ProblemName != "Draft v2" || revisions[1].Problem.ProblemName != "Draft" {
+		t.Fatalf("GetProblemRevisions returned %+v", revisions)
+	}
+	if *revisions[0].EditedBy != bob.UserID || *revisions[1].EditedBy != alice.UserID {
+		t.Fatalf("expected bob's edit on alice's problem, got %v and %v", *revisions[0].EditedBy, *revisions[1].EditedBy)
+	}
+
+	// Status changes only happen from the state the caller saw
+	if err := s.SetProblemStatus(ctx, id, ProblemDraft, ProblemInReview); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetProblemStatus(ctx, id, ProblemDraft, ProblemInReview); !hasCode(err, CodeConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if err := s.SetProblemStatus(ctx, 999, ProblemDraft, ProblemInReview); !hasCode(err, CodeNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := s.SetProblemStatus(ctx, id, ProblemInReview, ProblemPublished); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetProblemByID(ctx, id); got.Status != ProblemPublished || got.PublishedAt == nil {
+		t.Fatalf("expected a published problem, got %+v", got)
+	}
+
+	problemIDs := func(filter ProblemFilter) string {
+		t.Helper()
+		page, err := s.GetProblems(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, p := range page.Items {
+			ids = append(ids, p.ProblemID)
+		}
+		return fmt.Sprint(ids)
+	}
+	if got := problemIDs(ProblemFilter{Statuses: []string{ProblemPublished}}); got != fmt.Sprint([]int{id}) {
+		t.Errorf("expected only the published problem, got %s", got)
+	}
+	if got := problemIDs(ProblemFilter{Statuses: []string{ProblemDraft, ProblemInReview}}); got != fmt.Sprint([]int{other}) {
+		t.Errorf("expected only the draft, got %s", got)
+	}
+	if got := problemIDs(ProblemFilter{AuthorID: alice.UserID}); got != fmt.Sprint([]int{id}) {
+		t.Errorf("expected only alice's problem, got %s", got)
+	}
+
+	// Drafts can't be found by searching
+	if err := s.UpdateProblem(ctx, &Problem{ProblemID: other, Revision: 1, ProblemName: "Other draft", Slug: "other", FunctionName: "f", Tags: []string{}}, &alice.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := s.SearchProblems(ctx, "draft", 10); len(results) != 1 || results[0].ProblemID != id {
+		t.Fatalf("expected only the published problem, got %+v", results)
+	}
+}
+
+func testReferenceSolutions(t *testing.T, s Storage) {
+	ctx := context.Background()
+	alice := mustCreateAccount(t, s, "alice")
+	id := mustCreateProblem(t, s, "Add")
+
+	for _, rs := range []*ReferenceSolution{
+		{ProblemID: id, LanguageID: 71, SourceCode: "def f(a): return a * 2", UpdatedBy: &alice.UserID},
+		{ProblemID: id, LanguageID: 63, SourceCode: "function f(a) { return a }"},
+		{ProblemID: id, LanguageID: 63, SourceCode: "function f(a) { return a * 2 }"},
+	} {
+		if err := s.SetReferenceSolution(ctx, rs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetReferenceSolution(ctx, &ReferenceSolution{ProblemID: 999, LanguageID: 71}); !hasCode(err, CodeNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	solutions, err := s.GetReferenceSolutions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(solutions) != 2 || solutions[0].LanguageID != 63 || solutions[0].SourceCode != "function f(a) { return a * 2 }" || solutions[1].UpdatedBy == nil || *solutions[1].UpdatedBy != alice.UserID {
+		t.Fatalf("GetReferenceSolutions returned %+v", solutions)
+	}
+
+	if err := s.DeleteReferenceSolution(ctx, id, 63); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteReferenceSolution(ctx, id, 63); !hasCode(err, CodeNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// Flags replace the ones before them, test cases left out of them are cleared
+	var tests []int
+	for i := 0; i < 2; i++ {
+		tcID, err := s.CreateTestCase(ctx, NewTestCase(id, map[string]interface{}{"a": i}, i*2, TestCaseHidden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests = append(tests, tcID)
+	}
+	flags := func() []string {
+		t.Helper()
+		got, err := s.GetTestCasesByProblemID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var flags []string
+		for _, tc := range got {
+			flags = append(flags, tc.Flag)
+		}
+		return flags
+	}
+	if err := s.SetTestCaseFlags(ctx, id, map[int]string{tests[0]: "wrong"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := flags(); got[0] != "wrong" || got[1] != "" {
+		t.Fatalf("expected the first test case flagged, got %q", got)
+	}
+	if err := s.SetTestCaseFlags(ctx, id, map[int]string{tests[1]: "also wrong"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := flags(); got[0] != "" || got[1] != "also wrong" {
+		t.Fatalf("expected the second test case flagged, got %q", got)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*
+ * Creating test cases in bulk. The body is a JSON array of CreateTestCaseRequest, or the same
+ * objects as newline delimited JSON for clients that stream them out of a generator. Each test
+ * case's input has to have exactly the parameters of the problem's function. Test cases are told
+ * apart by a hash of their input: an input that is already in the suite, or earlier in the body,
+ * is left out when its output is the same and an error when it isn't.
+ */
+const (
+	maxBulkTestCases     = 1000
+	maxBulkTestCaseBytes = 16 << 20
+
+	ndjsonContentType = "application/x-ndjson"
+)
+
+/* Reads the body of a bulk creation, validating every test case like decodeJSON does */
+func decodeTestCases(w http.ResponseWriter, r *http.Request) ([]CreateTestCaseRequest, error) {
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBulkTestCaseBytes))
+	dec.DisallowUnknownFields()
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	ndjson := mediaType == ndjsonContentType
+	if !ndjson {
+		if token, err := dec.Token(); err != nil {
+			return nil, decodeError(err)
+		} else if token != json.Delim('[') {
+			return nil, BadRequest("request body must be a JSON array of test cases")
+		}
+	}
+
+	testCases := []CreateTestCaseRequest{}
+	errs := ValidationErrors{}
+	for i := 0; ndjson || dec.More(); i++ {
+		prefix := fmt.Sprintf("[%d].", i)
+		tc := CreateTestCaseRequest{}
+		if err := dec.Decode(&tc); ndjson && err == io.EOF {
+			break
+		} else if err != nil {
+			if err := errs.mergeAt(prefix, decodeError(err)); err != nil {
+				return nil, err
+			}
+			return nil, errs
+		}
+		if i == maxBulkTestCases {
+			return nil, BadRequest("at most %d test cases can be created at once", maxBulkTestCases)
+		}
+
+		if err := errs.mergeAt(prefix, checkRequest(&tc)); err != nil {
+			return nil, err
+		}
+		if tc.Kind == "" {
+			tc.Kind = TestCaseHidden
+		}
+		testCases = append(testCases, tc)
+	}
+
+	if !ndjson {
+		if _, err := dec.Token(); err != nil {
+			return nil, decodeError(err)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return nil, BadRequest("request body must be a single JSON array")
+		}
+	}
+
+	if len(testCases) == 0 && len(errs) == 0 {
+		return nil, BadRequest("request body has no test cases")
+	}
+	return testCases, errs.err()
+}
+
+/*
+ * The parameters of the problem's function, read from its starter code as that is all that
+ * names them. ok is false when the function is declared in a way this doesn't recognise.
+ */
+func functionParameters(problem *Problem) (params []string, ok bool) {
+	name := regexp.QuoteMeta(problem.FunctionName)
+	declarations := []*regexp.Regexp{
+		regexp.MustCompile(`\b(?:def|function)\s+` + name + `\s*\(([^)]*)\)`),               // def f(a, b): and function f(a, b) {
+		regexp.MustCompile(`\b` + name + `\s*=\s*(?:async\s+)?(?:function\s*)?\(([^)]*)\)`), // f = function (a, b) { and f = (a, b) =>
+	}
+
+	for _, declaration := range declarations {
+		match := declaration.FindStringSubmatch(problem.StarterCode)
+		if match == nil {
+			continue
+		}
+
+		params = []string{}
+		for _, param := range strings.Split(match[1], ",") {
+			// Without type annotations and defaults, "a: int = 0" -> "a"
+			param, _, _ = strings.Cut(param, "=")
+			param, _, _ = strings.Cut(param, ":")
+			if param = strings.TrimSpace(param); param != "" && param != "self" {
+				params = append(params, param)
+			}
+		}
+		return params, true
+	}
+	return nil, false
+}
+
+/* Why `input` doesn't fit a function taking `params`, or "" when it does */
+func checkInput(input map[string]interface{}, params []string, functionName string) string {
+	for _, param := range params {
+		if _, ok := input[param]; !ok {
+			return fmt.Sprintf("is missing %q", param)
+		}
+	}
+	names := make([]string, 0, len(input))
+	for name := range input {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !slices.Contains(params, name) {
+			return fmt.Sprintf("has %q, which %s doesn't take", name, functionName)
+		}
+	}
+	return ""
+}
+
+/* Tells test cases apart. encoding/json sorts map keys, so equal inputs hash the same */
+func inputHash(input map[string]interface{}) string {
+	b, _ := json.Marshal(input)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func sameOutput(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+/*
+ * Adds `reqs` to the problem's test cases, or replaces them with `reqs`, in one transaction.
+ * Every new test case is checked against the problem's reference solutions, like the ones
+ * created one at a time.
+ */
+func (s *APIServer) createTestCases(ctx context.Context, problem *Problem, reqs []CreateTestCaseRequest, replace bool) (*CreateTestCasesResult, error) {
+	existing := map[string]*TestCase{}
+	if !replace {
+		tests, err := s.store.GetTestCasesByProblemID(ctx, problem.ProblemID)
+		if err != nil {
+			return nil, err
+		}
+		for _, tc := range tests {
+			existing[inputHash(tc.IO.Input)] = tc
+		}
+	}
+
+	result := &CreateTestCasesResult{}
+	errs := ValidationErrors{}
+	params, checkParams := functionParameters(problem)
+	seen := map[string]int{}
+	testCases := []*TestCase{}
+	for i, req := range reqs {
+		field := fmt.Sprintf("[%d].io", i)
+		if checkParams {
+			errs.add(field+".input", checkInput(req.IO.Input, params, problem.FunctionName))
+		}
+
+		hash := inputHash(req.IO.Input)
+		if tc, ok := existing[hash]; ok {
+			if !sameOutput(tc.IO.Output, req.IO.Output) {
+				errs.add(field+".output", fmt.Sprintf("differs from test case %d, which has the same input", tc.TestCaseID))
+			}
+			result.Duplicates++
+			continue
+		}
+		if j, ok := seen[hash]; ok {
+			if !sameOutput(reqs[j].IO.Output, req.IO.Output) {
+				errs.add(field+".output", fmt.Sprintf("differs from [%d], which has the same input", j))
+			}
+			result.Duplicates++
+			continue
+		}
+		seen[hash] = i
+
+		// Numbered by their place in the body until the store gives them ids
+		testCase := NewTestCase(problem.ProblemID, req.IO.Input, req.IO.Output, req.Kind)
+		testCase.TestCaseID = i
+		testCases = append(testCases, testCase)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	solutions, err := s.store.GetReferenceSolutions(ctx, problem.ProblemID)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := s.verifyTestCases(ctx, problem, solutions, testCases)
+	if err != nil {
+		return nil, err
+	}
+	for i, flag := range flags {
+		errs.add(fmt.Sprintf("[%d].io.output", i), flag)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	err = s.store.WithTx(ctx, func(tx Storage) error {
+		result.Replaced = 0
+		if replace {
+			tests, err := tx.GetTestCasesByProblemID(ctx, problem.ProblemID)
+			if err != nil {
+				return err
+			}
+			if err := tx.DeleteTestCases(ctx, problem.ProblemID); err != nil {
+				return err
+			}
+			result.Replaced = len(tests)
+		}
+		return tx.CreateTestCases(ctx, testCases)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Created = len(testCases)
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFunctionParameters(t *testing.T) {
+	for starterCode, want := range map[string][]string{
+		"def add(a, b):": {"a", "b"},
+		"def add(self, a: int, b: int = 0) -> int": {"a", "b"},
+		"function add(a, b) {}":                    {"a", "b"},
+		"var add = function (nums, target) {":      {"nums", "target"},
+		"const add = async (a) => a":               {"a"},
+		"def add():":                               {},
+	} {
+		got, ok := functionParameters(&Problem{FunctionName: "add", StarterCode: starterCode})
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("functionParameters(%q) = %v, %v, want %v", starterCode, got, ok, want)
+		}
+	}
+
+	if _, ok := functionParameters(&Problem{FunctionName: "add", StarterCode: "def subtract_add(a, b):"}); ok {
+		t.Error("expected a function with a longer name not to count")
+	}
+}
+
+func TestCreateTestCasesInBulk(t *testing.T) {
+	c := newSpecClient(t)
+	author := signUp(c, "bob", RoleProblemSetter)
+
+	problem := c.call("POST", "/problems", author, CreateProblemRequest{
+		ProblemName:  "Add",
+		Prompt:       "Add a and b",
+		StarterCode:  "def add(a, b):",
+		FunctionName: "add",
+		Difficulty:   1,
+		TestCases:    []CreateTestCaseRequest{{IO: IO{Input: map[string]interface{}{"a": 1, "b": 2}, Output: 3}}},
+	}, http.StatusCreated)
+	id := int(problem["problem_id"].(float64))
+	path := fmt.Sprintf("/problems/%d/testcases:bulk", id)
+	sum := func(a, b, want int) CreateTestCaseRequest {
+		return CreateTestCaseRequest{IO: IO{Input: map[string]interface{}{"a": a, "b": b}, Output: want}}
+	}
+	countTestCases := func() int {
+		t.Helper()
+		return len(c.call("GET", fmt.Sprintf("/testcases/%d", id), author, nil, http.StatusOK)["items"].([]interface{}))
+	}
+
+	// Inputs that are already in the suite, or earlier in the body, are only created once
+	result := c.call("POST", path, author, []CreateTestCaseRequest{sum(1, 2, 3), sum(2, 2, 4), sum(3, 3, 6), sum(2, 2, 4)}, http.StatusOK)
+	if result["created"] != 2.0 || result["duplicates"] != 2.0 || result["replaced"] != 0.0 {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	// and refused with another output, as are inputs that don't fit add(a, b)
+	missing := CreateTestCaseRequest{IO: IO{Input: map[string]interface{}{"a": 1}, Output: 1}}
+	extra := CreateTestCaseRequest{IO: IO{Input: map[string]interface{}{"a": 1, "b": 1, "c": 1}, Output: 2}}
+	refused := c.call("POST", path, author, []CreateTestCaseRequest{sum(4, 4, 8), sum(4, 4, 9), sum(1, 2, 4), missing, extra}, http.StatusBadRequest)
+	fields := refused["fields"].(map[string]interface{})
+	for _, field := range []string{"[1].io.output", "[2].io.output", "[3].io.input", "[4].io.input"} {
+		if fields[field] == nil {
+			t.Errorf("expected an error for %s, got %v", field, fields)
+		}
+	}
+	if countTestCases() != 3 {
+		t.Fatal("expected a refused body to create nothing")
+	}
+
+	// Newline delimited JSON is read one test case per line
+	postNDJSON := func(query string, lines ...interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		ndjson := ""
+		for _, line := range lines {
+			b, _ := json.Marshal(line)
+			ndjson += string(b) + "\n"
+		}
+		r := httptest.NewRequest("POST", apiRoute+path+query, strings.NewReader(ndjson))
+		r.Header.Set("Authorization", "Bearer "+author)
+		r.Header.Set("Content-Type", ndjsonContentType)
+		w := httptest.NewRecorder()
+		c.router.ServeHTTP(w, r)
+		return w
+	}
+	if w := postNDJSON("", sum(5, 5, 10), map[string]string{"kind": "secret"}); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"[1].kind"`) {
+		t.Fatalf("expected the second line to be refused, got %d %s", w.Code, w.Body)
+	}
+	if w := postNDJSON("?replace=true", sum(5, 5, 10), sum(6, 6, 12)); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"created":2`) || !strings.Contains(w.Body.String(), `"replaced":3`) {
+		t.Fatalf("expected the suite to be replaced, got %d %s", w.Code, w.Body)
+	}
+	if countTestCases() != 2 {
+		t.Fatal("expected only the two new test cases")
+	}
+
+	// Reference solutions check every new test case
+	c.call("PUT", fmt.Sprintf("/problems/%d/solutions/71", id), author, SetReferenceSolutionRequest{SourceCode: "def add(a, b): return a + b"}, http.StatusOK)
+	refused = c.call("POST", path, author, []CreateTestCaseRequest{sum(7, 7, 14), sum(8, 8, 17)}, http.StatusBadRequest)
+	if flag := refused["fields"].(map[string]interface{})["[1].io.output"]; flag != "the python3 reference solution returned 16, expected 17" {
+		t.Fatalf("unexpected flag %v", flag)
+	}
+
+	c.call("POST", path, author, []CreateTestCaseRequest{}, http.StatusBadRequest)
+	c.call("POST", path, "", []CreateTestCaseRequest{sum(9, 9, 18)}, http.StatusUnauthorized)
+}
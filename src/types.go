@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -18,30 +19,186 @@ func newDifficultyRegistry() *DifficultyRegistry {
 	}
 }
 
+func (d *DifficultyRegistry) IsValid(difficulty int64) bool {
+	return difficulty == int64(d.Easy) || difficulty == int64(d.Medium) || difficulty == int64(d.Hard)
+}
+
+/* The validation message for anything else */
+func (d *DifficultyRegistry) describe() string {
+	return fmt.Sprintf("must be %d (easy), %d (medium) or %d (hard)", d.Easy, d.Medium, d.Hard)
+}
+
+const (
+	RolePlayer        = "player"
+	RoleProblemSetter = "problem-setter"
+	RoleAdmin         = "admin"
+)
+
+/* Higher ranks include every permission of the lower ones */
+var roleRanks = map[string]int{
+	RolePlayer:        1,
+	RoleProblemSetter: 2,
+	RoleAdmin:         3,
+}
+
 type Account struct {
+	UserID        int        `json:"user_id"`
+	FirstName     string     `json:"first_name"`
+	LastName      string     `json:"last_name"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"` // For some reason, need this json struct tag is needed to keep the formatting from giving me OCD...
+	CreatedAt     time.Time  `json:"created_at"`
+	Role          string     `json:"role"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	EmailVerified bool       `json:"email_verified"`
+}
+
+/*
+ * Accounts are never written to clients directly. Handlers pick one of these views
+ * depending on who is asking.
+ */
+
+/* What anyone can see about an account */
+type PublicAccount struct {
 	UserID    int       `json:"user_id"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
 	Username  string    `json:"username"`
-	Email     string    `json:"email"` // For some reason, need this json struct tag is needed to keep the formatting from giving me OCD...
-	Password  string    `json:"password"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+/* What the owner of an account sees */
+type SelfAccount struct {
+	UserID        int       `json:"user_id"`
+	Username      string    `json:"username"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+/* What admins see */
+type AdminAccount struct {
+	SelfAccount
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+func (a *Account) Public() *PublicAccount {
+	return &PublicAccount{
+		UserID:    a.UserID,
+		Username:  a.Username,
+		CreatedAt: a.CreatedAt,
+	}
+}
+
+func (a *Account) Self() *SelfAccount {
+	return &SelfAccount{
+		UserID:        a.UserID,
+		Username:      a.Username,
+		FirstName:     a.FirstName,
+		LastName:      a.LastName,
+		Email:         a.Email,
+		EmailVerified: a.EmailVerified,
+		Role:          a.Role,
+		CreatedAt:     a.CreatedAt,
+	}
+}
+
+func (a *Account) Admin() *AdminAccount {
+	return &AdminAccount{
+		SelfAccount: *a.Self(),
+		DeletedAt:   a.DeletedAt,
+	}
+}
+
+/* Picks the view of `a` that `viewer` is allowed to see. `viewer` may be nil */
+func (a *Account) ViewFor(viewer *Account) interface{} {
+	switch {
+	case viewer != nil && viewer.HasRole(RoleAdmin):
+		return a.Admin()
+	case viewer != nil && viewer.UserID == a.UserID:
+		return a.Self()
+	}
+	return a.Public()
+}
+
+func (a *Account) HasRole(role string) bool {
+	return roleRanks[a.Role] >= roleRanks[role]
+}
+
 type Problem struct {
-	ProblemID    int    `json:"problem_id"`
-	ProblemName  string `json:"problem_name"`
-	Prompt       string `json:"prompt"`
-	StarterCode  string `json:"starter_code"`
-	Difficulty   uint8  `json:"difficulty"`
-	FunctionName string `json:"function_name"`
+	ProblemID             int        `json:"problem_id"`
+	ProblemName           string     `json:"problem_name"`
+	Prompt                string     `json:"prompt"`
+	StarterCode           string     `json:"starter_code"`
+	Difficulty            uint8      `json:"difficulty"`
+	FunctionName          string     `json:"function_name"`
+	RevealHiddenOnFailure bool       `json:"reveal_hidden_on_failure"`
+	Slug                  string     `json:"slug"`
+	Tags                  []string   `json:"tags"`      // tag slugs, sorted
+	Status                string     `json:"status"`    // see ProblemDraft
+	AuthorID              *int       `json:"author_id"` // null for problems from before drafts
+	Revision              int        `json:"revision"`  // bumped by every UpdateProblem
+	PublishedAt           *time.Time `json:"published_at"`
+}
+
+/* A problem as it was after one edit. Revision 1 is the problem as it was created */
+type ProblemRevision struct {
+	Revision  int       `json:"revision"`
+	EditedBy  *int      `json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"`
+	Problem   Problem   `json:"problem"`
+}
+
+/* A search hit. Ranks only order the results of one search, their scale means nothing */
+type ProblemSearchResult struct {
+	Problem
+	Rank float64 `json:"rank"`
+}
+
+/* A topic problems are filed under, e.g. graphs or dynamic programming */
+type Tag struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type CreateTagRequest struct {
+	Slug string `json:"slug" validate:"required,max=50"`
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type SearchProblemsQuery struct {
+	Q     string `json:"q" validate:"required,max=200"`
+	Limit int    `json:"limit,omitempty" validate:"min=1,max=50"`
+}
+
+type SetProblemTagsRequest struct {
+	Tags []string `json:"tags" validate:"max=10"`
+}
+
+/*
+ * Examples are shown with the prompt, sanity checks are what the "run" button tests against,
+ * and hidden cases are only ever run on submission.
+ */
+const (
+	TestCaseExample = "example"
+	TestCaseSanity  = "sanity"
+	TestCaseHidden  = "hidden"
+)
+
+/* Kinds that players are allowed to read */
+var publicTestCaseKinds = []string{TestCaseExample, TestCaseSanity}
+
+func isValidTestCaseKind(kind string) bool {
+	return kind == TestCaseExample || kind == TestCaseSanity || kind == TestCaseHidden
 }
 
 type TestCase struct {
-	TestCaseID    int  `json:"test_case_id"`
-	ProblemID     int  `json:"problem_id"`
-	IO            IO   `json:"io"`
-	IsSanityCheck bool `json:"is_sanity_check"`
+	TestCaseID int    `json:"test_case_id"`
+	ProblemID  int    `json:"problem_id"`
+	IO         IO     `json:"io"`
+	Kind       string `json:"kind"`
+	Flag       string `json:"flag,omitempty"` // why a reference solution disagrees with IO.Output, see verifyTestCases
 }
 
 type IO struct {
@@ -58,56 +215,148 @@ type Submission struct {
 	Language     int       `json:"language"`
 	RuntimeMs    int       `json:"runtime_ms"`
 	MemUsageKb   int       `json:"mem_usage_kb"`
+	Verdict      string    `json:"verdict"`
 }
 
+/* How a submission was judged. Submissions made before they were judged stay pending */
+const (
+	VerdictPending           = "pending"
+	VerdictAccepted          = "accepted"
+	VerdictWrongAnswer       = "wrong_answer"
+	VerdictRuntimeError      = "runtime_error"
+	VerdictCompileError      = "compile_error"
+	VerdictTimeLimitExceeded = "time_limit_exceeded"
+)
+
 type CreateAccountRequest struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
+	Username  string `json:"username" validate:"required"`
+	Email     string `json:"email" validate:"required"`
+	Password  string `json:"password" validate:"required"`
 }
 
-type CreateAccountResponse struct {
-	Username  string    `json:"username"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at"`
+/* Only the fields that are set are changed */
+type UpdateAccountRequest struct {
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Username  *string `json:"username"`
+	Email     *string `json:"email"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=player problem-setter admin"`
 }
+
 type CreateProblemRequest struct {
-	ProblemName  string `json:"problem_name"`
-	Prompt       string
-	StarterCode  string `json:"starter_code"`
-	Difficulty   int
-	FunctionName string `json:"function_name"`
+	ProblemName           string `json:"problem_name" validate:"required,max=100"`
+	Prompt                string `json:"prompt" validate:"required"`
+	StarterCode           string `json:"starter_code" validate:"required"`
+	Difficulty            int    `json:"difficulty" validate:"required,difficulty"`
+	FunctionName          string `json:"function_name" validate:"required,max=100"`
+	RevealHiddenOnFailure bool   `json:"reveal_hidden_on_failure,omitempty"`
+
+	// Made from the name when left out
+	Slug string   `json:"slug,omitempty" validate:"max=100"`
+	Tags []string `json:"tags,omitempty" validate:"max=10"`
+
+	// Optional, created together with the problem. ProblemID is ignored
+	TestCases []CreateTestCaseRequest `json:"test_cases,omitempty"`
 }
 
-type CreateTestCaseRequest struct {
-	ProblemID     int  `json:"problem_id"`
-	IO            IO   `json:"io"`
-	IsSanityCheck bool `json:"is_sanity_check"`
+/*
+ * Replaces everything an author writes. Revision is the one the edit was made on, so that two
+ * people editing at once don't silently undo each other, see ErrStaleRevision.
+ */
+type UpdateProblemRequest struct {
+	Revision              int      `json:"revision" validate:"required"`
+	ProblemName           string   `json:"problem_name" validate:"required,max=100"`
+	Prompt                string   `json:"prompt" validate:"required"`
+	StarterCode           string   `json:"starter_code" validate:"required"`
+	Difficulty            int      `json:"difficulty" validate:"required,difficulty"`
+	FunctionName          string   `json:"function_name" validate:"required,max=100"`
+	RevealHiddenOnFailure bool     `json:"reveal_hidden_on_failure,omitempty"`
+	Slug                  string   `json:"slug,omitempty" validate:"max=100"` // kept when left out
+	Tags                  []string `json:"tags,omitempty" validate:"max=10"`
 }
 
-type CreateSubmissionRequest struct {
-	UserID     int    `json:"user_id"`
-	ProblemID  int    `json:"problem_id"`
+/* A known good program for a problem, at most one per language. Only authors and admins see them */
+type ReferenceSolution struct {
+	ProblemID  int       `json:"problem_id"`
+	LanguageID int       `json:"language_id"`
+	SourceCode string    `json:"source_code"`
+	UpdatedBy  *int      `json:"updated_by"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type SetReferenceSolutionRequest struct {
 	SourceCode string `json:"source_code"`
-	Language   int    `json:"language"`
-	RuntimeMs  int32  `json:"runtime_ms"`
-	MemUsageKb int32  `json:"mem_usage_kb"`
 }
 
-func NewAccountResponse(username, firstName, lastName, email, password string) *CreateAccountResponse {
-	return &CreateAccountResponse{
-		Username:  username,
-		FirstName: firstName,
-		LastName:  lastName,
-		Email:     email,
-		Password:  password,
-		CreatedAt: time.Now().UTC(),
-	}
+/*
+ * A problem with everything it takes to judge it, in the format `format` of package.go. This
+ * is a package as the import endpoint takes it, with the files of one on disk read in.
+ */
+type ProblemPackage struct {
+	Format                int    `json:"format" validate:"required"`
+	Slug                  string `json:"slug" validate:"required,max=100"` // what imports match problems by
+	ProblemName           string `json:"problem_name" validate:"required,max=100"`
+	Prompt                string `json:"prompt" validate:"required"`
+	StarterCode           string `json:"starter_code" validate:"required"`
+	Difficulty            int    `json:"difficulty" validate:"required,difficulty"`
+	FunctionName          string `json:"function_name" validate:"required,max=100"`
+	RevealHiddenOnFailure bool   `json:"reveal_hidden_on_failure,omitempty"`
+
+	Tags               []string          `json:"tags,omitempty" validate:"max=10"`
+	TestCases          []PackageTestCase `json:"test_cases" validate:"required"`
+	ReferenceSolutions map[string]string `json:"reference_solutions,omitempty"` // language name -> source code
+}
+
+type PackageTestCase struct {
+	IO   IO     `json:"io" validate:"required"`
+	Kind string `json:"kind,omitempty" validate:"oneof=example sanity hidden"` // hidden when empty
+}
+
+type ImportProblemsRequest struct {
+	Packages []ProblemPackage `json:"packages" validate:"required,max=50"`
+}
+
+/* What importing one package changed. Importing the same package again changes nothing */
+type ImportResult struct {
+	Slug      string `json:"slug"`
+	ProblemID int    `json:"problem_id"`
+
+	// Any of "created", "problem", "test_cases" and "reference_solutions"
+	Changes []string `json:"changes"`
+}
+
+type CreateTestCaseRequest struct {
+	ProblemID int    `json:"problem_id,omitempty"`
+	IO        IO     `json:"io" validate:"required"`
+	Kind      string `json:"kind,omitempty" validate:"oneof=example sanity hidden"` // hidden when empty
+}
+
+type CreateTestCasesQuery struct {
+	Replace bool `json:"replace,omitempty"` // the problem's test cases with the new ones, all at once
+}
+
+/* What a bulk creation did. Duplicates are test cases whose input was already in the suite */
+type CreateTestCasesResult struct {
+	Created    int `json:"created"`
+	Duplicates int `json:"duplicates"`
+	Replaced   int `json:"replaced"` // test cases that were removed, when replacing the suite
+}
+
+/* Who submits is taken from the access token, runtime and memory from the judge */
+type CreateSubmissionRequest struct {
+	ProblemID  int    `json:"problem_id" validate:"required"`
+	SourceCode string `json:"source_code"`
+	Language   int    `json:"language" validate:"required"`
 }
 
 func NewAccountRequest(username, firstName, lastName, email, password string) *CreateAccountRequest {
@@ -127,14 +376,17 @@ func NewProblem(problemName, prompt, starterCode, functionName string, difficult
 		StarterCode:  starterCode,
 		Difficulty:   difficulty,
 		FunctionName: functionName,
+		Slug:         slugify(problemName),
+		Tags:         []string{},
+		Status:       ProblemDraft,
 	}
 }
 
-func NewTestCase(problemID int, input map[string]interface{}, output interface{}, isSanityCheck bool) *TestCase {
+func NewTestCase(problemID int, input map[string]interface{}, output interface{}, kind string) *TestCase {
 	return &TestCase{
-		ProblemID:     problemID,
-		IO:            IO{Input: input, Output: output},
-		IsSanityCheck: isSanityCheck,
+		ProblemID: problemID,
+		IO:        IO{Input: input, Output: output},
+		Kind:      kind,
 	}
 }
 
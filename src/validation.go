@@ -0,0 +1,485 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrUsernameTaken = Conflict("username is already taken")
+	ErrEmailTaken    = Conflict("email is already registered")
+	ErrSlugTaken     = Conflict("another problem already has this slug")
+	ErrTagTaken      = Conflict("a tag with this slug already exists")
+	ErrStaleRevision = Conflict("the problem has been edited since this revision, reload it and try again")
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+/* Lower case words joined by single dashes, e.g. two-sum */
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+/* Names that would be confusing or could be used to impersonate staff */
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"algoduels":     true,
+	"api":           true,
+	"me":            true,
+	"moderator":     true,
+	"null":          true,
+	"root":          true,
+	"support":       true,
+	"system":        true,
+}
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 30
+	maxNameLength     = 50
+	maxEmailLength    = 254
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything past 72 bytes
+
+	maxSourceCodeBytes = 64 << 10
+
+	maxRequestBodyBytes = 2 << 20 // a full batch of source code, with room for JSON escaping
+)
+
+/* Field name -> message. Returned as a whole so clients can show every problem at once */
+type ValidationErrors map[string]string
+
+func (v ValidationErrors) Error() string {
+	fields := make([]string, 0, len(v))
+	for field, msg := range v {
+		fields = append(fields, field+": "+msg)
+	}
+	sort.Strings(fields)
+	return "validation failed: " + strings.Join(fields, ", ")
+}
+
+/* Records `msg` for `field` unless it is empty, keeping the first message per field */
+func (v ValidationErrors) add(field, msg string) {
+	if msg == "" {
+		return
+	}
+	if _, ok := v[field]; !ok {
+		v[field] = msg
+	}
+}
+
+/* Returns nil when there are no errors so the result can be returned as an error directly */
+func (v ValidationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+/* Adds every error in `err`, which is either nil or ValidationErrors, keeping existing messages */
+func (v ValidationErrors) merge(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	other, ok := err.(ValidationErrors)
+	if !ok {
+		return err
+	}
+	for field, msg := range other {
+		v.add(field, msg)
+	}
+	return nil
+}
+
+/* Like merge, with `prefix` in front of every field, e.g. "packages[0]." */
+func (v ValidationErrors) mergeAt(prefix string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	other, ok := err.(ValidationErrors)
+	if !ok {
+		return err
+	}
+	for field, msg := range other {
+		v.add(prefix+field, msg)
+	}
+	return nil
+}
+
+/*
+ * Request types declare their simple rules in `validate` tags, comma separated:
+ *
+ *	required     not the zero value, non-empty for strings, slices and maps
+ *	min=N, max=N bounds on numbers, on the length of strings in characters, or of slices
+ *	oneof=a b c  one of the space separated values
+ *	difficulty   one of the levels in DifficultyRegistry
+ *
+ * Every rule but required passes zero values, so optional fields only need checking when they
+ * are set. Pointers are checked by what they point to. Nested structs and slices of structs
+ * are checked too, their errors keyed like "test_cases[0].kind". Anything more involved goes
+ * in the type's Validate method, which decodeJSON calls after the tags.
+ */
+func validateTags(v interface{}) ValidationErrors {
+	errs := ValidationErrors{}
+	validateFields(errs, reflect.ValueOf(v), "")
+	return errs
+}
+
+func validateFields(errs ValidationErrors, v reflect.Value, prefix string) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		// Embedded structs without a name of their own are flattened, as encoding/json does
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			validateFields(errs, v.Field(i), prefix)
+			continue
+		}
+
+		name := jsonFieldName(f)
+		if name == "-" {
+			continue
+		}
+		field := prefix + name
+		value := v.Field(i)
+
+		if rules := f.Tag.Get("validate"); rules != "" {
+			for _, rule := range strings.Split(rules, ",") {
+				errs.add(field, checkRule(value, rule))
+			}
+		}
+
+		switch elem := indirectType(f.Type); elem.Kind() {
+		case reflect.Struct:
+			validateFields(errs, value, field+".")
+		case reflect.Slice:
+			if indirectType(elem.Elem()).Kind() == reflect.Struct {
+				for j := 0; j < value.Len(); j++ {
+					validateFields(errs, value.Index(j), fmt.Sprintf("%s[%d].", field, j))
+				}
+			}
+		}
+	}
+}
+
+/* The field's name in JSON, "-" when it isn't serialised */
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+var difficulties = newDifficultyRegistry()
+
+/* The message for `v` breaking `rule`, "" when it doesn't */
+func checkRule(v reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	empty := v.IsZero() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0)
+
+	if name == "required" {
+		if empty {
+			return "is required"
+		}
+		return ""
+	}
+	if empty {
+		return ""
+	}
+
+	switch name {
+	case "min", "max":
+		bound, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad bound in %q", rule))
+		}
+		return checkBound(v, name, bound)
+	case "oneof":
+		options := strings.Fields(arg)
+		got := fmt.Sprint(v.Interface())
+		for _, option := range options {
+			if got == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	case "difficulty":
+		if !v.CanInt() || !difficulties.IsValid(v.Int()) {
+			return difficulties.describe()
+		}
+		return ""
+	}
+
+	panic(fmt.Sprintf("validate: unknown rule %q", rule))
+}
+
+func checkBound(v reflect.Value, name string, bound int) string {
+	var n int64
+	var unit string
+	switch {
+	case v.CanInt():
+		n = v.Int()
+	case v.CanUint():
+		n = int64(v.Uint())
+	case v.Kind() == reflect.String:
+		n, unit = int64(utf8.RuneCountInString(v.String())), " characters"
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Map:
+		n, unit = int64(v.Len()), " items"
+	default:
+		panic(fmt.Sprintf("validate: %s on a %s", name, v.Kind()))
+	}
+
+	switch {
+	case name == "min" && n < int64(bound):
+		return fmt.Sprintf("must be at least %d%s", bound, unit)
+	case name == "max" && n > int64(bound):
+		return fmt.Sprintf("must be at most %d%s", bound, unit)
+	}
+	return ""
+}
+
+func validateUsername(username string) string {
+	n := utf8.RuneCountInString(username)
+	switch {
+	case n < minUsernameLength || n > maxUsernameLength:
+		return "must be between 3 and 30 characters"
+	case !usernamePattern.MatchString(username):
+		return "may only contain letters, numbers, '_' and '-'"
+	case reservedUsernames[strings.ToLower(username)] || strings.HasPrefix(strings.ToLower(username), "deleted-"):
+		return "is reserved"
+	}
+	return ""
+}
+
+func validateEmail(email string) string {
+	if len(email) > maxEmailLength {
+		return "is too long"
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "is not a valid email address"
+	}
+	return ""
+}
+
+func validatePassword(password, username string) string {
+	switch {
+	case len(password) < minPasswordLength:
+		return "must be at least 8 characters"
+	case len(password) > maxPasswordLength:
+		return "must be at most 72 bytes"
+	case username != "" && strings.EqualFold(password, username):
+		return "must not be the same as the username"
+	}
+	return ""
+}
+
+func validateName(name string) string {
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return "must be at most 50 characters"
+	}
+	return ""
+}
+
+func (req *CreateAccountRequest) normalize() {
+	req.Email = strings.TrimSpace(req.Email)
+}
+
+func (req *CreateAccountRequest) Validate() error {
+	v := ValidationErrors{}
+	v.add("username", validateUsername(req.Username))
+	v.add("email", validateEmail(req.Email))
+	v.add("password", validatePassword(req.Password, req.Username))
+	v.add("first_name", validateName(req.FirstName))
+	v.add("last_name", validateName(req.LastName))
+	return v.err()
+}
+
+func (req *UpdateAccountRequest) normalize() {
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		req.Email = &email
+	}
+}
+
+func (req *UpdateAccountRequest) Validate() error {
+	v := ValidationErrors{}
+	if req.Username != nil {
+		v.add("username", validateUsername(*req.Username))
+	}
+	if req.Email != nil {
+		v.add("email", validateEmail(*req.Email))
+	}
+	if req.FirstName != nil {
+		v.add("first_name", validateName(*req.FirstName))
+	}
+	if req.LastName != nil {
+		v.add("last_name", validateName(*req.LastName))
+	}
+	return v.err()
+}
+
+func (req *ChangePasswordRequest) Validate() error {
+	v := ValidationErrors{}
+	v.add("new_password", validatePassword(req.NewPassword, ""))
+	return v.err()
+}
+
+func (req *ResetPasswordRequest) Validate() error {
+	v := ValidationErrors{}
+	v.add("new_password", validatePassword(req.NewPassword, ""))
+	return v.err()
+}
+
+func validateSourceCode(source string) string {
+	switch {
+	case source == "":
+		return "is required"
+	case len(source) > maxSourceCodeBytes:
+		return "must be at most 64KB"
+	}
+	return ""
+}
+
+func (req *ExecReq) Validate() error {
+	v := ValidationErrors{}
+	v.add("source_code", validateSourceCode(req.SourceCode))
+	return v.err()
+}
+
+func (req *ExecBatchReq) Validate() error {
+	v := ValidationErrors{}
+	for i := range req.Submissions {
+		v.add(fmt.Sprintf("submissions[%d].source_code", i), validateSourceCode(req.Submissions[i].SourceCode))
+	}
+	return v.err()
+}
+
+/* A slug for `name`, "Two Sum!" -> "two-sum". Empty when it has no ASCII letters or digits */
+func slugify(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	return truncate(strings.Join(words, "-"), 100)
+}
+
+func validateSlug(slug string) string {
+	if !slugPattern.MatchString(slug) {
+		return "may only contain lower case letters and digits, separated by single dashes"
+	}
+	return ""
+}
+
+/* Problems can only be tagged with tags that exist, see CreateTag */
+func unknownTag(slug string) error {
+	return ValidationErrors{"tags": fmt.Sprintf("%q is not a known tag", slug)}
+}
+
+func (req *CreateProblemRequest) normalize() {
+	if req.Slug == "" {
+		req.Slug = slugify(req.ProblemName)
+	}
+}
+
+func (req *CreateProblemRequest) Validate() error {
+	v := ValidationErrors{}
+	if req.ProblemName != "" {
+		v.add("slug", validateSlug(req.Slug))
+	}
+	return v.err()
+}
+
+func (req *UpdateProblemRequest) Validate() error {
+	v := ValidationErrors{}
+	if req.Slug != "" {
+		v.add("slug", validateSlug(req.Slug))
+	}
+	return v.err()
+}
+
+func (req *SetReferenceSolutionRequest) Validate() error {
+	v := ValidationErrors{}
+	v.add("source_code", validateSourceCode(req.SourceCode))
+	return v.err()
+}
+
+func (pkg *ProblemPackage) Validate() error {
+	v := ValidationErrors{}
+	if pkg.Format > packageFormat {
+		v.add("format", fmt.Sprintf("must be at most %d, packages in format %d need a newer server", packageFormat, pkg.Format))
+	}
+	if pkg.Slug != "" {
+		v.add("slug", validateSlug(pkg.Slug))
+	}
+	for language, source := range pkg.ReferenceSolutions {
+		field := "reference_solutions." + language
+		if _, ok := harnesses[languageIDs[language]]; !ok {
+			v.add(field, "is not a supported language")
+		}
+		v.add(field, validateSourceCode(source))
+	}
+	return v.err()
+}
+
+func (req *ImportProblemsRequest) Validate() error {
+	v := ValidationErrors{}
+	slugs := map[string]bool{}
+	for i := range req.Packages {
+		prefix := fmt.Sprintf("packages[%d].", i)
+		if slug := req.Packages[i].Slug; slugs[slug] {
+			v.add(prefix+"slug", "is in more than one package")
+		} else {
+			slugs[slug] = true
+		}
+
+		v.mergeAt(prefix, req.Packages[i].Validate())
+	}
+	return v.err()
+}
+
+func (req *CreateTagRequest) Validate() error {
+	v := ValidationErrors{}
+	if req.Slug != "" {
+		v.add("slug", validateSlug(req.Slug))
+	}
+	return v.err()
+}
+
+func (req *CreateSubmissionRequest) Validate() error {
+	v := ValidationErrors{}
+	v.add("source_code", validateSourceCode(req.SourceCode))
+	return v.err()
+}
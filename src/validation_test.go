@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeBody(body string, v interface{}) error {
+	r := httptest.NewRequest(http.MethodPost, "/api/test", strings.NewReader(body))
+	return decodeJSON(httptest.NewRecorder(), r, v)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		fields ValidationErrors // expected field errors, nil when another code is expected
+		code   string
+	}{
+		{
+			name:   "unknown field",
+			body:   `{"problem_name":"a","difficulty":1,"promt":"typo"}`,
+			fields: ValidationErrors{"promt": "is not a known field"},
+		},
+		{
+			name:   "wrong type",
+			body:   `{"difficulty":"hard"}`,
+			fields: ValidationErrors{"difficulty": "must be an integer"},
+		},
+		{
+			name: "tags, aggregated",
+			body: `{"problem_name":"two-sum","difficulty":7,"test_cases":[{"io":{"input":{"a":1},"output":1}},{"kind":"secret"}]}`,
+			fields: ValidationErrors{
+				"prompt":             "is required",
+				"starter_code":       "is required",
+				"function_name":      "is required",
+				"difficulty":         "must be 1 (easy), 2 (medium) or 3 (hard)",
+				"test_cases[1].io":   "is required",
+				"test_cases[1].kind": "must be one of example, sanity, hidden",
+			},
+		},
+		{name: "empty body", body: ``, code: CodeBadRequest},
+		{name: "malformed", body: `{"problem_name":`, code: CodeBadRequest},
+		{name: "trailing data", body: `{} {}`, code: CodeBadRequest},
+		{name: "too large", body: `{"prompt":"` + strings.Repeat("a", maxRequestBodyBytes) + `"}`, code: CodePayloadTooLarge},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := decodeBody(c.body, new(CreateProblemRequest))
+
+			if c.fields != nil {
+				if got, ok := err.(ValidationErrors); !ok || !reflect.DeepEqual(got, c.fields) {
+					t.Errorf("expected %v, got %v", c.fields, err)
+				}
+				return
+			}
+			if !hasCode(err, c.code) {
+				t.Errorf("expected %s, got %v", c.code, err)
+			}
+		})
+	}
+}
+
+/* Tag errors and the ones from Validate come back together, tags first */
+func TestDecodeJSONRunsValidate(t *testing.T) {
+	req := new(CreateAccountRequest)
+	err := decodeBody(`{"username":"alice","email":"  not-an-email ","first_name":"`+strings.Repeat("a", 51)+`"}`, req)
+
+	want := ValidationErrors{
+		"password":   "is required",
+		"email":      "is not a valid email address",
+		"first_name": "must be at most 50 characters",
+	}
+	if got, ok := err.(ValidationErrors); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, err)
+	}
+	if req.Email != "not-an-email" {
+		t.Errorf("expected the email to be trimmed before validation, got %q", req.Email)
+	}
+}
+
+func TestValidDecodedRequest(t *testing.T) {
+	req := new(CreateProblemRequest)
+	err := decodeBody(`{"problem_name":"two-sum","prompt":"p","starter_code":"s","function_name":"f","difficulty":2}`, req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Difficulty != 2 || req.Prompt != "p" {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+/* Emails are compared as stored, so a change has to be trimmed like a sign up is */
+func TestEmailIsTrimmedLikeOnSignUp(t *testing.T) {
+	create := new(CreateAccountRequest)
+	if err := decodeBody(`{"username":"alice","email":" alice@example.com ","password":"`+testPassword+`"}`, create); err != nil {
+		t.Fatal(err)
+	}
+	update := new(UpdateAccountRequest)
+	if err := decodeBody(`{"email":" alice@example.com "}`, update); err != nil {
+		t.Fatal(err)
+	}
+	if *update.Email != create.Email || create.Email != "alice@example.com" {
+		t.Errorf("expected both emails trimmed, got %q and %q", create.Email, *update.Email)
+	}
+}
+
+func TestDecodeQuery(t *testing.T) {
+	cases := []struct {
+		query  string
+		fields ValidationErrors
+	}{
+		{"limit=0&sort=id", nil},
+		{"limit=ten", ValidationErrors{"limit": "must be an integer"}},
+		{"limit=101&order=sideways", ValidationErrors{"limit": "must be at most 100", "order": "must be one of asc, desc"}},
+		{"verdict=ok&page=2", ValidationErrors{"page": "is not a known parameter"}},
+		{"verdict=ok", ValidationErrors{"verdict": "must be one of pending, accepted, wrong_answer, runtime_error, compile_error, time_limit_exceeded"}},
+		{"from=yesterday", ValidationErrors{"from": "must be a time in RFC 3339 format"}},
+		{"from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z", ValidationErrors{"to": "must be after from"}},
+		{"user_id=1&user_id=2", ValidationErrors{"user_id": "may only be given once"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/submissions?"+c.query, nil)
+			err := decodeQuery(r, new(ListSubmissionsQuery))
+
+			if c.fields == nil {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if got, ok := err.(ValidationErrors); !ok || !reflect.DeepEqual(got, c.fields) {
+				t.Errorf("expected %v, got %v", c.fields, err)
+			}
+		})
+	}
+}
+
+func TestCursorOnlyContinuesItsOwnOrder(t *testing.T) {
+	cursor := encodeCursor(&Cursor{Sort: "name", Key: "two-sum", ID: 3})
+
+	if page, err := (PageQuery{Cursor: cursor}).page("name", problemOrder); err != nil || page.After == nil || page.After.ID != 3 {
+		t.Fatalf("expected the cursor to continue the name order, got %+v, %v", page, err)
+	}
+	for _, q := range []PageQuery{{Cursor: cursor}, {Cursor: cursor, Order: "desc"}, {Cursor: "garbage"}} {
+		sort := "name"
+		if q.Order == "" {
+			sort = "difficulty"
+		}
+		if _, err := q.page(sort, problemOrder); err == nil {
+			t.Errorf("expected %+v sorted by %s to be rejected", q, sort)
+		}
+	}
+}
+
+/* A cursor's key reaches Postgres as the sort key's type, so one that doesn't parse is the caller's mistake */
+func TestCursorKeyHasTheSortKeysType(t *testing.T) {
+	cases := []struct {
+		cursor Cursor
+		order  listOrder
+		ok     bool
+	}{
+		{Cursor{Sort: "difficulty", Key: "2", ID: 1}, problemOrder, true},
+		{Cursor{Sort: "difficulty", Key: "hard", ID: 1}, problemOrder, false},
+		{Cursor{Sort: "difficulty", Key: "40000", ID: 1}, problemOrder, false},
+		{Cursor{Sort: "id", Key: "9223372036854775807", ID: 1}, problemOrder, true},
+		{Cursor{Sort: "id", Key: "1e3", ID: 1}, problemOrder, false},
+		{Cursor{Sort: "submitted_at", Key: "2024-01-01T00:00:00Z", ID: 1}, submissionOrder, true},
+		{Cursor{Sort: "submitted_at", Key: "yesterday", ID: 1}, submissionOrder, false},
+		{Cursor{Sort: "runtime_ms", Key: "4294967296", ID: 1}, submissionOrder, false},
+	}
+
+	for _, c := range cases {
+		_, err := (PageQuery{Cursor: encodeCursor(&c.cursor)}).page(c.cursor.Sort, c.order)
+		if c.ok && err != nil {
+			t.Errorf("expected %+v to be accepted, got %v", c.cursor, err)
+		}
+		if fields, _ := err.(ValidationErrors); !c.ok && fields["cursor"] == "" {
+			t.Errorf("expected %+v to be rejected, got %v", c.cursor, err)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Two Sum":                "two-sum",
+		"  LRU   cache (hard) ":  "lru-cache-hard",
+		"3Sum -- closest":        "3sum-closest",
+		"!!!":                    "",
+		strings.Repeat("a", 150): strings.Repeat("a", 100),
+	}
+	for name, want := range cases {
+		if got := slugify(name); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"slices"
+)
+
+/*
+ * Problems start out as drafts that only their author and admins can see. The author submits a
+ * draft for review, and an admin publishes it once its reference solutions agree with every
+ * test case, see reference.go, or sends it back to draft. Published problems are retired rather
+ * than deleted, so submissions keep pointing at something, and can be published again.
+ */
+const (
+	ProblemDraft     = "draft"
+	ProblemInReview  = "in_review"
+	ProblemPublished = "published"
+	ProblemRetired   = "retired"
+)
+
+/* The states a problem can move to from each state */
+var problemTransitions = map[string][]string{
+	ProblemDraft:     {ProblemInReview},
+	ProblemInReview:  {ProblemDraft, ProblemPublished},
+	ProblemPublished: {ProblemRetired},
+	ProblemRetired:   {ProblemPublished},
+}
+
+func (p *Problem) canMoveTo(status string) error {
+	if !slices.Contains(problemTransitions[p.Status], status) {
+		return Conflict("problem %d is %s and can't become %s", p.ProblemID, p.Status, status)
+	}
+	return nil
+}
+
+func (p *Problem) authoredBy(account *Account) bool {
+	return account != nil && p.AuthorID != nil && *p.AuthorID == account.UserID
+}
+
+/*
+ * Published and retired problems are public. Drafts and problems in review are not found for
+ * anyone but their author and admins, so that their existence doesn't leak.
+ */
+func (p *Problem) checkVisibleTo(account *Account) error {
+	switch {
+	case p.Status == ProblemPublished || p.Status == ProblemRetired:
+	case p.authoredBy(account):
+	case account != nil && account.HasRole(RoleAdmin):
+	default:
+		return NotFound("problem %d not found", p.ProblemID)
+	}
+	return nil
+}
+
+/* Authors edit their drafts. Admins edit anything that isn't retired, published problems included */
+func (p *Problem) checkEditableBy(account *Account) error {
+	switch {
+	case account != nil && account.HasRole(RoleAdmin):
+		if p.Status == ProblemRetired {
+			return Conflict("problem %d is retired", p.ProblemID)
+		}
+	case p.authoredBy(account):
+		if p.Status != ProblemDraft {
+			return Conflict("problem %d is %s, only drafts can be edited", p.ProblemID, p.Status)
+		}
+	default:
+		return errForbidden
+	}
+	return nil
+}
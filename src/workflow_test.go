@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+/* Signs up `username` with `role` and returns an access token */
+func signUp(c *specClient, username, role string) string {
+	c.t.Helper()
+	account := c.call("POST", "/accounts", "", CreateAccountRequest{Username: username, Email: username + "@example.com", Password: testPassword}, http.StatusCreated)
+	if err := c.server.store.UpdateAccountRole(context.Background(), int(account["user_id"].(float64)), role); err != nil {
+		c.t.Fatal(err)
+	}
+	tokens := c.call("POST", "/auth/login", "", LoginRequest{Username: username, Password: testPassword}, http.StatusOK)
+	return tokens["access_token"].(string)
+}
+
+func TestProblemWorkflowPermissions(t *testing.T) {
+	c := newSpecClient(t)
+	admin := signUp(c, "alice", RoleAdmin)
+	author := signUp(c, "bob", RoleProblemSetter)
+	setter := signUp(c, "carol", RoleProblemSetter)
+	player := signUp(c, "dave", RolePlayer)
+
+	problem := c.call("POST", "/problems", author, CreateProblemRequest{
+		ProblemName:  "Add",
+		Prompt:       "Add a and b",
+		StarterCode:  "def add(a, b):",
+		FunctionName: "add",
+		Difficulty:   1,
+		TestCases:    []CreateTestCaseRequest{{IO: IO{Input: map[string]interface{}{"a": 1, "b": 2}, Output: 3}}},
+	}, http.StatusCreated)
+	id := int(problem["problem_id"].(float64))
+	path := fmt.Sprintf("/problems/%d", id)
+	update := UpdateProblemRequest{Revision: 1, ProblemName: "Add", Prompt: "Add up a and b", StarterCode: "def add(a, b):", FunctionName: "add", Difficulty: 1}
+	reference := SetReferenceSolutionRequest{SourceCode: "def add(a, b): return a + b"}
+	hidden := CreateTestCaseRequest{ProblemID: id, IO: IO{Input: map[string]interface{}{"a": 2, "b": 2}, Output: 4}}
+
+	// Drafts don't exist for anyone but their author and admins
+	for _, token := range []string{"", player, setter} {
+		c.call("GET", path, token, nil, http.StatusNotFound)
+	}
+	c.call("GET", path, author, nil, http.StatusOK)
+	c.call("GET", path, admin, nil, http.StatusOK)
+	c.call("PUT", path, setter, update, http.StatusNotFound)
+	c.call("PUT", path+"/solutions/71", setter, reference, http.StatusNotFound)
+	c.call("POST", "/testcases", setter, hidden, http.StatusNotFound)
+	c.call("POST", "/submissions", player, CreateSubmissionRequest{ProblemID: id, SourceCode: reference.SourceCode, Language: 71}, http.StatusNotFound)
+	if page := c.call("GET", "/problems/drafts", setter, nil, http.StatusOK); len(page["items"].([]interface{})) != 0 {
+		t.Fatalf("expected no drafts for carol, got %v", page["items"])
+	}
+	if page := c.call("GET", "/problems/drafts", admin, nil, http.StatusOK); len(page["items"].([]interface{})) != 1 {
+		t.Fatalf("expected bob's draft for the admin, got %v", page["items"])
+	}
+
+	// The author edits the draft until it is submitted for review
+	c.call("PUT", path, author, update, http.StatusOK)
+	c.call("POST", "/testcases", author, hidden, http.StatusCreated)
+	c.call("PUT", path+"/solutions/71", author, reference, http.StatusOK)
+	c.call("POST", path+"/review", author, nil, http.StatusOK)
+	update.Revision = 2
+	c.call("PUT", path, author, update, http.StatusConflict)
+	c.call("PUT", path+"/solutions/71", author, reference, http.StatusConflict)
+	c.call("POST", path+"/publish", author, nil, http.StatusForbidden)
+
+	published := c.call("POST", path+"/publish", admin, nil, http.StatusOK)
+	if published["status"] != ProblemPublished || published["published_at"] == nil {
+		t.Fatalf("expected a published problem, got %v", published)
+	}
+	c.call("GET", path, player, nil, http.StatusOK)
+	c.call("POST", "/submissions", player, CreateSubmissionRequest{ProblemID: id, SourceCode: reference.SourceCode, Language: 71}, http.StatusCreated)
+	c.call("GET", path+"/revisions", setter, nil, http.StatusForbidden)
+	c.call("GET", path+"/solutions", setter, nil, http.StatusForbidden)
+	c.call("PUT", path+"/tags", setter, SetProblemTagsRequest{Tags: []string{}}, http.StatusForbidden)
+	c.call("PUT", path+"/tags", author, SetProblemTagsRequest{Tags: []string{}}, http.StatusOK)
+
+	// Only admins touch a published problem
+	c.call("PUT", path, author, update, http.StatusConflict)
+	c.call("PUT", path, admin, update, http.StatusOK)
+	if revisions := c.callList("GET", path+"/revisions", author, nil, http.StatusOK); len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %d", len(revisions))
+	}
+	c.call("POST", path+"/retire", author, nil, http.StatusForbidden)
+	c.call("POST", path+"/retire", admin, nil, http.StatusOK)
+	c.call("GET", path, player, nil, http.StatusOK)
+	if page := c.call("GET", "/problems", "", nil, http.StatusOK); len(page["items"].([]interface{})) != 0 {
+		t.Fatalf("expected retired problems to be left out, got %v", page["items"])
+	}
+}